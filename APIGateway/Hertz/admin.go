@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/cloudwego/kitex/pkg/generic"
+
+	"hertz_demo/idl"
+)
+
+/**
+ * adminConfig enables the admin API on its own address, away from the public routes.
+ * The API refuses to start without an auth secret. AuditLog is the file every change is
+ * appended to as one JSON object per line.
+ */
+type adminConfig struct {
+	Enabled  bool       `json:"enabled"`
+	Addr     string     `json:"addr"`
+	Auth     authConfig `json:"auth"`
+	AuditLog string     `json:"auditLog"`
+}
+
+/**
+ * auditEntry records one change made, or attempted, through the admin API.
+ */
+type auditEntry struct {
+	Time   string      `json:"time"`
+	Actor  string      `json:"actor"`
+	Remote string      `json:"remote"`
+	Action string      `json:"action"`
+	Target string      `json:"target"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+/**
+ * auditLog appends entries as JSON lines to a writer.
+ */
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+/**
+ * Opens the audit log for appending, creating it and its directory if needed.
+ */
+func openAuditLog(path string) (*auditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %w", err)
+	}
+	return &auditLog{w: f}, nil
+}
+
+func (a *auditLog) record(e auditEntry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		fmt.Println("Error encoding audit entry:", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(append(line, '\n')); err != nil {
+		fmt.Println("Error writing audit entry:", err)
+	}
+}
+
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+/**
+ * adminServer serves the admin API. Its dependencies are fields so tests can point it at a
+ * temporary IDL directory and a fake registry.
+ */
+type adminServer struct {
+	auth   authConfig
+	audit  *auditLog
+	idlDir string
+	// routes lists the public gateway's routes.
+	routes   func() route.RoutesInfo
+	policies *policyStore
+	drains   *drainSet
+	// hosts returns a service's instance list from the registry, nil when it cannot be reached.
+	hosts func(service string) map[string]interface{}
+	// shadow holds the sampled shadow diffs, nil when no service is mirrored.
+	shadow *shadowMirror
+	// cache is the response cache, nil when caching is off.
+	cache *responseCache
+}
+
+// adminActor is the request context key holding the authenticated caller.
+const adminActor = "adminActor"
+
+/**
+ * Registers the admin routes under /admin. Every route requires a valid token.
+ */
+func (s *adminServer) register(r *route.Engine) {
+	g := r.Group("/admin", s.authenticate)
+	g.GET("/routes", s.listRoutes)
+	g.GET("/idls", s.listIDLs)
+	g.PUT("/idls/:serviceName", s.putIDL)
+	g.GET("/policies", s.listPolicies)
+	g.PUT("/policies/:serviceName", s.putPolicy)
+	g.GET("/services/:serviceName/instances", s.listInstances)
+	g.GET("/breakers", s.listBreakers)
+	g.GET("/drains", s.listDrains)
+	g.POST("/drains", s.drain)
+	g.DELETE("/drains/:address", s.undrain)
+	g.GET("/shadow/diffs", s.listShadowDiffs)
+	g.DELETE("/cache", s.purgeCache)
+	g.DELETE("/cache/:serviceName/:methodName", s.purgeCache)
+}
+
+// authenticate checks the token and remembers its sub claim as the actor for the audit log.
+func (s *adminServer) authenticate(ctx context.Context, c *app.RequestContext) {
+	claims, err := authenticateRequest(s.auth, c)
+	if err != nil {
+		if string(c.Method()) != consts.MethodGet {
+			s.audit.record(s.entry(c, "auth", string(c.Path()), nil, nil, err))
+		}
+		c.AbortWithStatusJSON(consts.StatusUnauthorized, utils.H{"error": err.Error()})
+		return
+	}
+	actor, _ := claims["sub"].(string)
+	if actor == "" {
+		actor = "unknown"
+	}
+	c.Set(adminActor, actor)
+}
+
+func (s *adminServer) entry(c *app.RequestContext, action string, target string, before interface{}, after interface{}, err error) auditEntry {
+	e := auditEntry{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Actor:  c.GetString(adminActor),
+		Remote: c.ClientIP(),
+		Action: action,
+		Target: target,
+		Before: before,
+		After:  after,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+func (s *adminServer) listRoutes(ctx context.Context, c *app.RequestContext) {
+	out := []utils.H{}
+	for _, r := range s.routes() {
+		out = append(out, utils.H{"method": r.Method, "path": r.Path, "handler": r.Handler})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i]["path"] != out[j]["path"] {
+			return out[i]["path"].(string) < out[j]["path"].(string)
+		}
+		return out[i]["method"].(string) < out[j]["method"].(string)
+	})
+	c.JSON(consts.StatusOK, out)
+}
+
+// idlInfo describes one IDL file: its hash, and the services and methods it declares.
+func idlInfo(path string) utils.H {
+	info := utils.H{"file": filepath.Base(path)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		info["error"] = err.Error()
+		return info
+	}
+	sum := sha256.Sum256(data)
+	info["sha256"] = hex.EncodeToString(sum[:])
+	info["size"] = len(data)
+	doc, err := idl.ParseFile(path)
+	if err != nil {
+		info["error"] = err.Error()
+		return info
+	}
+	services := utils.H{}
+	for _, svc := range doc.Services {
+		methods := []string{}
+		for _, m := range svc.Methods {
+			methods = append(methods, m.Name)
+		}
+		services[svc.Name] = methods
+	}
+	info["services"] = services
+	return info
+}
+
+func (s *adminServer) listIDLs(ctx context.Context, c *app.RequestContext) {
+	paths, err := filepath.Glob(filepath.Join(s.idlDir, "*.thrift"))
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	sort.Strings(paths)
+	out := []utils.H{}
+	for _, path := range paths {
+		out = append(out, idlInfo(path))
+	}
+	c.JSON(consts.StatusOK, out)
+}
+
+/**
+ * Uploads or replaces the IDL of a service.
+ *
+ * The IDL is written next to the others under a temporary name and only renamed into place
+ * once it parses, declares the service and builds a generic codec, so calls never see a
+ * half-written or broken file. Calls resolve the IDL per request and pick it up immediately.
+ */
+func (s *adminServer) putIDL(ctx context.Context, c *app.RequestContext) {
+	service := c.Param("serviceName")
+	fail := func(status int, before interface{}, err error) {
+		s.audit.record(s.entry(c, "idl.put", service, before, nil, err))
+		c.JSON(status, utils.H{"error": err.Error()})
+	}
+	if !serviceNamePattern.MatchString(service) {
+		fail(consts.StatusBadRequest, nil, fmt.Errorf("invalid service name %q", service))
+		return
+	}
+
+	path := filepath.Join(s.idlDir, service+".thrift")
+	var before interface{}
+	if _, err := os.Stat(path); err == nil {
+		before = idlInfo(path)["sha256"]
+	}
+
+	tmp, err := os.CreateTemp(s.idlDir, "."+service+"-*.upload")
+	if err != nil {
+		fail(consts.StatusInternalServerError, before, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(c.GetRawData())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fail(consts.StatusInternalServerError, before, err)
+		return
+	}
+
+	doc, err := idl.ParseFile(tmp.Name())
+	if err != nil {
+		fail(consts.StatusBadRequest, before, fmt.Errorf("invalid IDL: %w", err))
+		return
+	}
+	declared := false
+	for _, svc := range doc.Services {
+		declared = declared || svc.Name == service
+	}
+	if !declared {
+		fail(consts.StatusBadRequest, before, fmt.Errorf("IDL does not declare service %s", service))
+		return
+	}
+	if _, err := generic.NewThriftFileProvider(tmp.Name()); err != nil {
+		fail(consts.StatusBadRequest, before, fmt.Errorf("IDL cannot be used for generic calls: %w", err))
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		fail(consts.StatusInternalServerError, before, err)
+		return
+	}
+
+	info := idlInfo(path)
+	s.audit.record(s.entry(c, "idl.put", service, before, info["sha256"], nil))
+	c.JSON(consts.StatusOK, info)
+}
+
+func (s *adminServer) listPolicies(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, s.policies.all())
+}
+
+func (s *adminServer) putPolicy(ctx context.Context, c *app.RequestContext) {
+	service := c.Param("serviceName")
+	var p servicePolicy
+	if err := json.Unmarshal(c.GetRawData(), &p); err != nil {
+		s.audit.record(s.entry(c, "policy.put", service, nil, nil, err))
+		c.JSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	before, err := s.policies.set(service, p)
+	if err != nil {
+		s.audit.record(s.entry(c, "policy.put", service, nil, p, err))
+		c.JSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	after := s.policies.get(service)
+	s.audit.record(s.entry(c, "policy.put", service, before, after, nil))
+	c.JSON(consts.StatusOK, after)
+}
+
+/**
+ * Lists a service's instances as the registry reports them, marking the drained ones.
+ */
+func (s *adminServer) listInstances(ctx context.Context, c *app.RequestContext) {
+	service := c.Param("serviceName")
+	hostList, ok := s.hosts(service)["hosts"].([]interface{})
+	if !ok {
+		c.JSON(consts.StatusServiceUnavailable, utils.H{"error": errRegistryUnavailable.Error()})
+		return
+	}
+	instances := []utils.H{}
+	for _, h := range hostList {
+		host, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		address := fmt.Sprintf("%v:%v", host["ip"], host["port"])
+		instances = append(instances, utils.H{
+			"address":  address,
+			"weight":   host["weight"],
+			"healthy":  host["healthy"],
+			"enabled":  host["enabled"],
+			"drained":  s.drains.has(address),
+			"metadata": host["metadata"],
+		})
+	}
+	c.JSON(consts.StatusOK, utils.H{"service": service, "instances": instances})
+}
+
+func (s *adminServer) listBreakers(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, breakerStates())
+}
+
+func (s *adminServer) listDrains(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, s.drains.list())
+}
+
+/**
+ * Drains an instance: the gateway stops sending it new calls, while calls in flight finish.
+ */
+func (s *adminServer) drain(ctx context.Context, c *app.RequestContext) {
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(c.GetRawData(), &req); err != nil || !strings.Contains(req.Address, ":") {
+		err = fmt.Errorf("body must be {\"address\": \"host:port\"}")
+		s.audit.record(s.entry(c, "instance.drain", req.Address, nil, nil, err))
+		c.JSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	added := s.drains.add(req.Address)
+	s.audit.record(s.entry(c, "instance.drain", req.Address, !added, true, nil))
+	c.JSON(consts.StatusOK, utils.H{"address": req.Address, "drained": true})
+}
+
+func (s *adminServer) undrain(ctx context.Context, c *app.RequestContext) {
+	address := c.Param("address")
+	if !s.drains.remove(address) {
+		err := fmt.Errorf("%s is not drained", address)
+		s.audit.record(s.entry(c, "instance.undrain", address, nil, nil, err))
+		c.JSON(consts.StatusNotFound, utils.H{"error": err.Error()})
+		return
+	}
+	s.audit.record(s.entry(c, "instance.undrain", address, true, false, nil))
+	c.JSON(consts.StatusOK, utils.H{"address": address, "drained": false})
+}
+
+/**
+ * Lists the sampled differences between shadow and primary responses, optionally only those
+ * of ?service= and ?method=.
+ */
+func (s *adminServer) listShadowDiffs(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, s.shadow.sampledDiffs(c.Query("service"), c.Query("method")))
+}
+
+/**
+ * Purges the whole response cache, or only the entries of one method.
+ */
+func (s *adminServer) purgeCache(ctx context.Context, c *app.RequestContext) {
+	target := "*"
+	if service := c.Param("serviceName"); service != "" {
+		target = service + "/" + c.Param("methodName")
+	}
+	removed, err := s.cache.Purge(ctx, c.Param("serviceName"), c.Param("methodName"))
+	s.audit.record(s.entry(c, "cache.purge", target, nil, removed, err))
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	c.JSON(consts.StatusOK, utils.H{"purged": removed})
+}
+
+/**
+ * Starts the admin API on its own address in the background.
+ *
+ * @param cfg The admin section of the config.
+ * @param public The public gateway, whose routes the API lists.
+ * @param serverTLS The gateway's TLS config, reused for the admin port; nil serves plain HTTP.
+ *
+ * @return An error if auth is off or the audit log cannot be opened.
+ */
+func startAdminServer(cfg adminConfig, public *server.Hertz, serverTLS *tls.Config) error {
+	if cfg.Auth.Secret == "" {
+		return errors.New("the admin API requires admin.auth.secret")
+	}
+	audit, err := openAuditLog(cfg.AuditLog)
+	if err != nil {
+		return err
+	}
+
+	opts := []config.Option{server.WithHostPorts(cfg.Addr)}
+	if serverTLS != nil {
+		opts = append(opts, server.WithTLS(serverTLS))
+	}
+	h := server.Default(opts...)
+	admin := &adminServer{
+		auth:     cfg.Auth,
+		audit:    audit,
+		idlDir:   thriftDirectory,
+		routes:   public.Routes,
+		policies: gatewayPolicies,
+		drains:   drainedInstances,
+		hosts:    func(service string) map[string]interface{} { return getServiceHosts(service, serviceRegistryIP) },
+		shadow:   gatewayShadow,
+		cache:    gatewayCache,
+	}
+	admin.register(h.Engine)
+
+	fmt.Println("Admin API listening on", cfg.Addr)
+	go h.Spin()
+	return nil
+}
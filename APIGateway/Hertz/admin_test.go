@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/cloudwego/kitex/pkg/discovery"
+)
+
+func newAdminEngine(t *testing.T) (*route.Engine, *adminServer, *bytes.Buffer) {
+	dir := t.TempDir()
+	for _, name := range []string{"base.thrift", "ReviewService.thrift"} {
+		data, err := os.ReadFile(filepath.Join(thriftDirectory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	policies, _ := newPolicyStore(nil)
+	audit := &bytes.Buffer{}
+	engine := route.NewEngine(config.NewOptions(nil))
+	s := &adminServer{
+		auth:     authConfig{Secret: "admin-secret"},
+		audit:    &auditLog{w: audit},
+		idlDir:   dir,
+		routes:   engine.Routes,
+		policies: policies,
+		drains:   &drainSet{addresses: map[string]time.Time{}},
+		hosts: func(service string) map[string]interface{} {
+			return map[string]interface{}{"hosts": []interface{}{
+				map[string]interface{}{"ip": "10.0.0.1", "port": 8888.0, "healthy": true},
+				map[string]interface{}{"ip": "10.0.0.2", "port": 8888.0, "healthy": true},
+			}}
+		},
+	}
+	s.register(engine)
+	return engine, s, audit
+}
+
+func adminRequest(t *testing.T, engine *route.Engine, method string, url string, body string) (int, interface{}) {
+	token := ut.Header{Key: "Authorization", Value: "Bearer " + signHS256(t, `{"sub":"ops"}`, "admin-secret")}
+	resp := ut.PerformRequest(engine, method, url, &ut.Body{Body: strings.NewReader(body), Len: len(body)}, token)
+	var out interface{}
+	json.Unmarshal(resp.Body.Bytes(), &out)
+	return resp.Code, out
+}
+
+func auditEntries(t *testing.T, audit *bytes.Buffer) []auditEntry {
+	entries := []auditEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(audit.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Audit line is not JSON: %s", line)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAdminRequiresAToken(t *testing.T) {
+	engine, _, audit := newAdminEngine(t)
+	resp := ut.PerformRequest(engine, consts.MethodPut, "/admin/policies/ReviewService", &ut.Body{Body: strings.NewReader(`{}`), Len: 2})
+	if resp.Code != consts.StatusUnauthorized {
+		t.Fatalf("Expected 401, got %d", resp.Code)
+	}
+	if entries := auditEntries(t, audit); len(entries) != 1 || entries[0].Action != "auth" {
+		t.Fatalf("Expected the rejected change to be audited, got %v", entries)
+	}
+}
+
+func TestAdminPoliciesAreValidatedAndAudited(t *testing.T) {
+	engine, s, audit := newAdminEngine(t)
+
+	code, out := adminRequest(t, engine, consts.MethodPut, "/admin/policies/ReviewService",
+		`{"timeout":"500ms","loadBalancer":"weighted_random","rateLimit":{"qps":1,"burst":1}}`)
+	if code != consts.StatusOK || out.(map[string]interface{})["timeout"] != "500ms" {
+		t.Fatalf("Unexpected response %d %v", code, out)
+	}
+	if p := s.policies.get("ReviewService"); time.Duration(p.Timeout) != 500*time.Millisecond || p.LoadBalancer != lbWeightedRandom {
+		t.Fatalf("Policy not applied: %+v", p)
+	}
+	now := time.Now()
+	if !s.policies.admit("ReviewService", now) || s.policies.admit("ReviewService", now) {
+		t.Fatalf("Expected the new rate limit to apply")
+	}
+
+	if code, _ := adminRequest(t, engine, consts.MethodPut, "/admin/policies/ReviewService", `{"loadBalancer":"fastest"}`); code != consts.StatusBadRequest {
+		t.Fatalf("Expected 400 for an unknown balancer, got %d", code)
+	}
+
+	entries := auditEntries(t, audit)
+	if len(entries) != 2 || entries[0].Actor != "ops" || entries[0].Error != "" || entries[1].Error == "" {
+		t.Fatalf("Unexpected audit entries %+v", entries)
+	}
+	if before := entries[0].Before.(map[string]interface{}); before["timeout"] != "3s" {
+		t.Fatalf("Expected the default policy as before, got %v", before)
+	}
+}
+
+func TestAdminIDLUpload(t *testing.T) {
+	engine, s, audit := newAdminEngine(t)
+	path := filepath.Join(s.idlDir, "ReviewService.thrift")
+	original, _ := os.ReadFile(path)
+
+	code, _ := adminRequest(t, engine, consts.MethodPut, "/admin/idls/ReviewService", "service ReviewService {")
+	if code != consts.StatusBadRequest {
+		t.Fatalf("Expected 400 for a broken IDL, got %d", code)
+	}
+	code, _ = adminRequest(t, engine, consts.MethodPut, "/admin/idls/ReviewService", "service OtherService {}")
+	if current, _ := os.ReadFile(path); code != consts.StatusBadRequest || !bytes.Equal(current, original) {
+		t.Fatalf("A rejected upload must leave the IDL alone, got %d", code)
+	}
+
+	updated := string(original) + "\nservice PingService { string ping(1: string msg) }\n"
+	code, out := adminRequest(t, engine, consts.MethodPut, "/admin/idls/ReviewService", updated)
+	if code != consts.StatusOK {
+		t.Fatalf("Expected the upload to succeed, got %d %v", code, out)
+	}
+	if current, _ := os.ReadFile(path); string(current) != updated {
+		t.Fatalf("IDL was not replaced")
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(s.idlDir, ".*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("Temporary files left behind: %v", leftovers)
+	}
+
+	_, out = adminRequest(t, engine, consts.MethodGet, "/admin/idls", "")
+	found := false
+	for _, info := range out.([]interface{}) {
+		services, _ := info.(map[string]interface{})["services"].(map[string]interface{})
+		found = found || services["PingService"] != nil
+	}
+	if !found {
+		t.Fatalf("Uploaded service not listed: %v", out)
+	}
+
+	entries := auditEntries(t, audit)
+	last := entries[len(entries)-1]
+	if len(entries) != 3 || last.Action != "idl.put" || last.Before == nil || last.After == last.Before {
+		t.Fatalf("Unexpected audit entries %+v", entries)
+	}
+}
+
+func TestAdminDrainMarksInstances(t *testing.T) {
+	engine, s, audit := newAdminEngine(t)
+
+	if code, _ := adminRequest(t, engine, consts.MethodPost, "/admin/drains", `{"address":"10.0.0.2:8888"}`); code != consts.StatusOK {
+		t.Fatalf("Expected drain to succeed, got %d", code)
+	}
+	_, out := adminRequest(t, engine, consts.MethodGet, "/admin/services/ReviewService/instances", "")
+	instances := out.(map[string]interface{})["instances"].([]interface{})
+	if instances[0].(map[string]interface{})["drained"] != false || instances[1].(map[string]interface{})["drained"] != true {
+		t.Fatalf("Unexpected instances %v", instances)
+	}
+	if !s.drains.has("10.0.0.2:8888") {
+		t.Fatalf("Instance not drained")
+	}
+
+	if code, _ := adminRequest(t, engine, consts.MethodDelete, "/admin/drains/10.0.0.2:8888", ""); code != consts.StatusOK {
+		t.Fatalf("Expected undrain to succeed, got %d", code)
+	}
+	if code, _ := adminRequest(t, engine, consts.MethodDelete, "/admin/drains/10.0.0.2:8888", ""); code != consts.StatusNotFound {
+		t.Fatalf("Expected 404 for an instance that is not drained, got %d", code)
+	}
+	if entries := auditEntries(t, audit); len(entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %+v", entries)
+	}
+}
+
+func TestAdminListsRoutes(t *testing.T) {
+	engine, _, _ := newAdminEngine(t)
+	_, out := adminRequest(t, engine, consts.MethodGet, "/admin/routes", "")
+	if routes := out.([]interface{}); len(routes) != 13 {
+		t.Fatalf("Expected the 13 admin routes, got %v", routes)
+	}
+}
+
+func TestAdminListsNoShadowDiffsWhenMirroringIsOff(t *testing.T) {
+	engine, _, _ := newAdminEngine(t)
+	code, out := adminRequest(t, engine, consts.MethodGet, "/admin/shadow/diffs?service=ReviewService", "")
+	if diffs, ok := out.([]interface{}); code != consts.StatusOK || !ok || len(diffs) != 0 {
+		t.Fatalf("Expected an empty list, got %d %v", code, out)
+	}
+}
+
+func TestDrainSetFiltersResolverResults(t *testing.T) {
+	d := &drainSet{addresses: map[string]time.Time{}}
+	d.add("10.0.0.2:8888")
+	res := d.filter(discovery.Result{Instances: []discovery.Instance{
+		discovery.NewInstance("tcp", "10.0.0.1:8888", 10, nil),
+		discovery.NewInstance("tcp", "10.0.0.2:8888", 10, nil),
+	}})
+	if len(res.Instances) != 1 || res.Instances[0].Address().String() != "10.0.0.1:8888" {
+		t.Fatalf("Expected only the undrained instance, got %v", res.Instances)
+	}
+}
+
+func TestAdminPurgesTheCache(t *testing.T) {
+	engine, s, audit := newAdminEngine(t)
+	rc, err := newResponseCache(cacheConfig{Methods: map[string]cachePolicy{
+		"TravelService/GetAllTravelDestinations": {TTL: duration(time.Minute)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.cache = rc
+	calls := 0
+	rc.Do(context.Background(), "TravelService", "GetAllTravelDestinations", map[string]interface{}{"userID": 1.0}, noHeaders, countingFetch(&calls))
+
+	if resp := ut.PerformRequest(engine, consts.MethodDelete, "/admin/cache", nil); resp.Code != consts.StatusUnauthorized {
+		t.Fatalf("Purging without a token should give 401, got %d", resp.Code)
+	}
+	code, out := adminRequest(t, engine, consts.MethodDelete, "/admin/cache/TravelService/GetAllTravelDestinations", "")
+	if code != consts.StatusOK || out.(map[string]interface{})["purged"] != 1.0 {
+		t.Fatalf("Expected 1 purged entry, got %d %v", code, out)
+	}
+	if !strings.Contains(audit.String(), `"action":"cache.purge"`) {
+		t.Fatalf("The purge should be audited, got %s", audit.String())
+	}
+}
+
+func TestPolicyStoreSetReturnsThePolicyItReplaced(t *testing.T) {
+	store, _ := newPolicyStore(nil)
+	var mu sync.Mutex
+	replaced := map[time.Duration]int{}
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			previous, err := store.set("ReviewService", servicePolicy{Timeout: duration(time.Duration(100+i) * time.Second)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			replaced[time.Duration(previous.Timeout)]++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	// Every policy but the last one set is replaced exactly once, as is the starting one.
+	replaced[time.Duration(store.get("ReviewService").Timeout)]++
+	if len(replaced) != 51 {
+		t.Fatalf("Expected 51 distinct policies, got %v", replaced)
+	}
+	for timeout, n := range replaced {
+		if n != 1 {
+			t.Fatalf("Policy with timeout %s was reported replaced %d times", timeout, n)
+		}
+	}
+}
+
+func TestConsistentHashUsesThePolicyHashKey(t *testing.T) {
+	if _, err := (servicePolicy{LoadBalancer: lbConsistentHash}).normalise(); err == nil {
+		t.Fatal("consistent_hash without a hashKey should be rejected")
+	}
+	byField, err := servicePolicy{LoadBalancer: lbConsistentHash, HashKey: "userID"}.normalise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byHeader := servicePolicy{LoadBalancer: lbConsistentHash, HashKey: "header:X-User-Id"}
+	ctx := context.WithValue(context.Background(), ctxHeadersKey, map[string]string{"x-user-id": "u7"})
+	if key := byField.hashKeyOf(ctx, map[string]interface{}{"userID": float64(42)}); key != "42" {
+		t.Fatalf("Expected the body field as key, got %q", key)
+	}
+	if key := byHeader.hashKeyOf(ctx, nil); key != "u7" {
+		t.Fatalf("Expected the header as key, got %q", key)
+	}
+
+	var instances []discovery.Instance
+	for _, addr := range []string{"10.0.0.1:8888", "10.0.0.2:8888", "10.0.0.3:8888", "10.0.0.4:8888"} {
+		instances = append(instances, discovery.NewInstance("tcp", addr, 10, nil))
+	}
+	lb := byField.balancer()
+	pick := func(body map[string]interface{}) string {
+		ctx := context.WithValue(context.Background(), ctxConsistentKey, byField.hashKeyOf(context.Background(), body))
+		picker := lb.GetPicker(discovery.Result{CacheKey: "ReviewService", Instances: instances})
+		return picker.Next(ctx, nil).Address().String()
+	}
+	picked := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		first := pick(map[string]interface{}{"userID": float64(i)})
+		if again := pick(map[string]interface{}{"userID": float64(i)}); again != first {
+			t.Fatalf("User %d moved from %s to %s", i, first, again)
+		}
+		picked[first] = true
+	}
+	if len(picked) < 2 {
+		t.Fatalf("Different keys should spread over the instances, got %v", picked)
+	}
+	if pick(map[string]interface{}{}) == "" {
+		t.Fatal("A call without the key should still get an instance")
+	}
+}
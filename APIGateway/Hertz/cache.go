@@ -0,0 +1,363 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache statuses reported to clients in the X-Cache header.
+const (
+	cacheHit    = "HIT"
+	cacheStale  = "STALE"
+	cacheMiss   = "MISS"
+	cacheBypass = "BYPASS"
+)
+
+/**
+ * cachePolicy is the caching behaviour of one method.
+ *
+ * A response is served from the cache for TTL. For a further StaleWhileRevalidate it is still
+ * served, while a background call refreshes it. VaryHeaders are request headers that take part
+ * in the cache key, e.g. Authorization for per-user data.
+ */
+type cachePolicy struct {
+	TTL                  duration `json:"ttl"`
+	StaleWhileRevalidate duration `json:"staleWhileRevalidate"`
+	VaryHeaders          []string `json:"varyHeaders"`
+}
+
+/**
+ * cacheConfig enables response caching for the methods listed in Methods, keyed "Service/method".
+ * Backend is "memory" (an LRU bounded by MaxEntries) or "redis".
+ */
+type cacheConfig struct {
+	Backend    string                 `json:"backend"`
+	MaxEntries int                    `json:"maxEntries"`
+	Redis      redisConfig            `json:"redis"`
+	Methods    map[string]cachePolicy `json:"methods"`
+}
+
+type redisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+/**
+ * cachedResponse is what the cache stores for one key.
+ */
+type cachedResponse struct {
+	Body     json.RawMessage `json:"body"`
+	StoredAt time.Time       `json:"storedAt"`
+}
+
+/**
+ * responseStore is a key/value store for cached responses.
+ */
+type responseStore interface {
+	Get(ctx context.Context, key string) (*cachedResponse, bool, error)
+	// Set stores an entry that the store may drop after expiry.
+	Set(ctx context.Context, key string, entry *cachedResponse, expiry time.Duration) error
+	// Purge removes every key starting with prefix and returns how many were removed.
+	Purge(ctx context.Context, prefix string) (int, error)
+}
+
+type lruEntry struct {
+	key       string
+	entry     *cachedResponse
+	expiresAt time.Time
+}
+
+/**
+ * memoryStore is an in-process LRU cache.
+ */
+type memoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+func newMemoryStore(maxEntries int) *memoryStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &memoryStore{maxEntries: maxEntries, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) (*cachedResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if time.Now().After(e.expiresAt) {
+		m.order.Remove(el)
+		delete(m.items, key)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return e.entry, true, nil
+}
+
+func (m *memoryStore) Set(ctx context.Context, key string, entry *cachedResponse, expiry time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		el.Value = &lruEntry{key: key, entry: entry, expiresAt: time.Now().Add(expiry)}
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.items[key] = m.order.PushFront(&lruEntry{key: key, entry: entry, expiresAt: time.Now().Add(expiry)})
+	for m.order.Len() > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+func (m *memoryStore) Purge(ctx context.Context, prefix string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for key, el := range m.items {
+		if strings.HasPrefix(key, prefix) {
+			m.order.Remove(el)
+			delete(m.items, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+/**
+ * redisStore keeps cached responses in any server speaking the Redis protocol.
+ */
+type redisStore struct {
+	client *redis.Client
+}
+
+func newRedisStore(cfg redisConfig) *redisStore {
+	return &redisStore{client: redis.NewClient(&redis.Options{Addr: cfg.Addr, Password: cfg.Password, DB: cfg.DB})}
+}
+
+func (r *redisStore) Get(ctx context.Context, key string) (*cachedResponse, bool, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
+
+func (r *redisStore) Set(ctx context.Context, key string, entry *cachedResponse, expiry time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, key, data, expiry).Err()
+}
+
+// escapeScanPattern escapes the glob characters SCAN MATCH interprets, so a prefix only
+// matches itself.
+func escapeScanPattern(prefix string) string {
+	var b strings.Builder
+	for _, ch := range prefix {
+		if strings.ContainsRune(`*?[]\`, ch) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+func (r *redisStore) Purge(ctx context.Context, prefix string) (int, error) {
+	removed := 0
+	iter := r.client.Scan(ctx, 0, escapeScanPattern(prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := r.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, iter.Err()
+}
+
+/**
+ * responseCache applies per-method cache policies in front of a generic call.
+ */
+type responseCache struct {
+	store        responseStore
+	policies     map[string]cachePolicy
+	revalidating sync.Map
+}
+
+const cacheKeyPrefix = "gw:cache:"
+
+/**
+ * Creates the response cache described by the config.
+ *
+ * @return nil when no method opts in to caching, or an error for an unknown backend.
+ */
+func newResponseCache(cfg cacheConfig) (*responseCache, error) {
+	if len(cfg.Methods) == 0 {
+		return nil, nil
+	}
+	var store responseStore
+	switch cfg.Backend {
+	case "", "memory":
+		store = newMemoryStore(cfg.MaxEntries)
+	case "redis":
+		store = newRedisStore(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Backend)
+	}
+	return &responseCache{store: store, policies: cfg.Methods}, nil
+}
+
+/**
+ * Builds the cache key from the service, method, canonical request body and varied headers.
+ * encoding/json sorts map keys, so bodies that differ only in key order share a key.
+ */
+func cacheKey(service string, method string, body map[string]interface{}, policy cachePolicy, header func(string) string) string {
+	canonical, _ := json.Marshal(body)
+	h := sha256.New()
+	h.Write(canonical)
+	for _, name := range policy.VaryHeaders {
+		h.Write([]byte("\n" + strings.ToLower(name) + ":" + header(name)))
+	}
+	return fmt.Sprintf("%s%s/%s:%s", cacheKeyPrefix, service, method, hex.EncodeToString(h.Sum(nil)))
+}
+
+/**
+ * Returns the response for a call, consulting the cache when the method has a policy.
+ *
+ * @param ctx The request context.
+ * @param service The service name.
+ * @param method The method name.
+ * @param body The decoded request body.
+ * @param header Looks up a request header.
+ * @param fetch Performs the real call on a miss or a revalidation.
+ *
+ * @return The response, the cache status for the X-Cache header (empty when the method is not
+ *         cached) and the error from fetch on a miss.
+ */
+func (rc *responseCache) Do(ctx context.Context, service string, method string, body map[string]interface{}, header func(string) string, fetch genericCaller) (interface{}, string, error) {
+	if rc == nil {
+		resp, err := fetch(ctx, service, method, body)
+		return resp, "", err
+	}
+	policy, ok := rc.policies[service+"/"+method]
+	// Calls routed to another namespace by header are test traffic and never share the cache.
+	if !ok || routedByHeader(ctx) {
+		resp, err := fetch(ctx, service, method, body)
+		return resp, "", err
+	}
+
+	cacheControl := strings.ToLower(header("Cache-Control"))
+	noStore := strings.Contains(cacheControl, "no-store")
+	noCache := noStore || strings.Contains(cacheControl, "no-cache")
+	key := cacheKey(service, method, body, policy, header)
+
+	if !noCache {
+		entry, found, err := rc.store.Get(ctx, key)
+		if err != nil {
+			log.Println("cache lookup failed:", err)
+		}
+		if found {
+			var resp interface{}
+			if err := json.Unmarshal(entry.Body, &resp); err == nil {
+				age := time.Since(entry.StoredAt)
+				if age < time.Duration(policy.TTL) {
+					return resp, cacheHit, nil
+				}
+				if age < time.Duration(policy.TTL+policy.StaleWhileRevalidate) {
+					rc.revalidate(key, service, method, body, policy, fetch)
+					return resp, cacheStale, nil
+				}
+			}
+		}
+	}
+
+	resp, err := fetch(ctx, service, method, body)
+	if err != nil {
+		return nil, cacheMiss, err
+	}
+	if !noStore {
+		rc.save(ctx, key, resp, policy)
+	}
+	if noCache {
+		return resp, cacheBypass, nil
+	}
+	return resp, cacheMiss, nil
+}
+
+func (rc *responseCache) save(ctx context.Context, key string, resp interface{}, policy cachePolicy) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	entry := &cachedResponse{Body: data, StoredAt: time.Now()}
+	if err := rc.store.Set(ctx, key, entry, time.Duration(policy.TTL+policy.StaleWhileRevalidate)); err != nil {
+		log.Println("cache store failed:", err)
+	}
+}
+
+// revalidate refreshes a stale entry in the background; concurrent stale hits share one refresh.
+func (rc *responseCache) revalidate(key string, service string, method string, body map[string]interface{}, policy cachePolicy, fetch genericCaller) {
+	if _, running := rc.revalidating.LoadOrStore(key, true); running {
+		return
+	}
+	go func() {
+		defer rc.revalidating.Delete(key)
+		ctx := context.Background()
+		resp, err := fetch(ctx, service, method, body)
+		if err != nil {
+			log.Println("cache revalidation failed:", err)
+			return
+		}
+		rc.save(ctx, key, resp, policy)
+	}()
+}
+
+/**
+ * Removes cached responses, either all of them or those of a single method.
+ *
+ * @param service The service name, or "" to purge everything.
+ * @param method The method name; ignored when service is "".
+ *
+ * @return The number of entries removed.
+ */
+func (rc *responseCache) Purge(ctx context.Context, service string, method string) (int, error) {
+	if rc == nil {
+		return 0, nil
+	}
+	prefix := cacheKeyPrefix
+	if service != "" {
+		prefix = fmt.Sprintf("%s%s/%s:", cacheKeyPrefix, service, method)
+	}
+	return rc.store.Purge(ctx, prefix)
+}
+
+// gatewayCache is the response cache built from the config, nil when caching is off.
+var gatewayCache *responseCache
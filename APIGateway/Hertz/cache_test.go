@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func countingFetch(calls *int) genericCaller {
+	return func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		*calls++
+		return map[string]interface{}{"Destinations": []interface{}{"Japan"}, "call": float64(*calls)}, nil
+	}
+}
+
+func noHeaders(string) string { return "" }
+
+func TestCacheServesHitsAndHonoursNoCache(t *testing.T) {
+	rc, err := newResponseCache(cacheConfig{Methods: map[string]cachePolicy{
+		"TravelService/GetAllTravelDestinations": {TTL: duration(time.Minute)},
+	}})
+	if err != nil {
+		t.Fatalf("Should create the cache: %v", err)
+	}
+	calls := 0
+	fetch := countingFetch(&calls)
+	ctx := context.Background()
+
+	_, status, _ := rc.Do(ctx, "TravelService", "GetAllTravelDestinations", map[string]interface{}{"userID": 1.0}, noHeaders, fetch)
+	if status != cacheMiss {
+		t.Fatalf("First call should miss, got %s", status)
+	}
+	_, status, _ = rc.Do(ctx, "TravelService", "GetAllTravelDestinations", map[string]interface{}{"userID": 1.0}, noHeaders, fetch)
+	if status != cacheHit || calls != 1 {
+		t.Fatalf("Second call should hit, got %s after %d calls", status, calls)
+	}
+
+	noCache := func(name string) string {
+		if name == "Cache-Control" {
+			return "no-cache"
+		}
+		return ""
+	}
+	_, status, _ = rc.Do(ctx, "TravelService", "GetAllTravelDestinations", map[string]interface{}{"userID": 1.0}, noCache, fetch)
+	if status != cacheBypass || calls != 2 {
+		t.Fatalf("no-cache should go to the backend, got %s after %d calls", status, calls)
+	}
+
+	_, status, _ = rc.Do(ctx, "TravelService", "RetrieveClientData", map[string]interface{}{"userID": 1.0}, noHeaders, fetch)
+	if status != "" {
+		t.Fatalf("Methods without a policy should not be cached, got %s", status)
+	}
+
+	purged, _ := rc.Purge(ctx, "TravelService", "GetAllTravelDestinations")
+	if purged != 1 {
+		t.Fatalf("Purge should remove 1 entry, got %d", purged)
+	}
+}
+
+func TestCacheServesStaleWhileRevalidating(t *testing.T) {
+	rc, _ := newResponseCache(cacheConfig{Methods: map[string]cachePolicy{
+		"TravelService/GetAllTravelDestinations": {TTL: duration(time.Millisecond), StaleWhileRevalidate: duration(time.Minute)},
+	}})
+	calls := 0
+	fetch := countingFetch(&calls)
+	ctx := context.Background()
+
+	rc.Do(ctx, "TravelService", "GetAllTravelDestinations", map[string]interface{}{}, noHeaders, fetch)
+	time.Sleep(5 * time.Millisecond)
+
+	resp, status, _ := rc.Do(ctx, "TravelService", "GetAllTravelDestinations", map[string]interface{}{}, noHeaders, fetch)
+	if status != cacheStale {
+		t.Fatalf("Expired entry within the stale window should be served stale, got %s", status)
+	}
+	if resp.(map[string]interface{})["call"] != 1.0 {
+		t.Fatalf("Stale response should be the first one, got %v", resp)
+	}
+}
+
+func TestCacheKeyIgnoresKeyOrderButVariesOnHeaders(t *testing.T) {
+	policy := cachePolicy{VaryHeaders: []string{"Authorization"}}
+	a := cacheKey("S", "m", map[string]interface{}{"a": 1.0, "b": 2.0}, policy, noHeaders)
+	b := cacheKey("S", "m", map[string]interface{}{"b": 2.0, "a": 1.0}, policy, noHeaders)
+	if a != b {
+		t.Fatalf("Key order should not change the cache key")
+	}
+	c := cacheKey("S", "m", map[string]interface{}{"a": 1.0, "b": 2.0}, policy, func(string) string { return "Bearer x" })
+	if a == c {
+		t.Fatalf("Varied headers should change the cache key")
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := newMemoryStore(2)
+	ctx := context.Background()
+	store.Set(ctx, "a", &cachedResponse{}, time.Minute)
+	store.Set(ctx, "b", &cachedResponse{}, time.Minute)
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", &cachedResponse{}, time.Minute)
+
+	if _, ok, _ := store.Get(ctx, "b"); ok {
+		t.Fatalf("b should have been evicted")
+	}
+	if _, ok, _ := store.Get(ctx, "a"); !ok {
+		t.Fatalf("a should still be cached")
+	}
+}
+
+func TestEscapeScanPattern(t *testing.T) {
+	if got := escapeScanPattern(`gw:cache:Svc*/m?[x]\`); got != `gw:cache:Svc\*/m\?\[x\]\\` {
+		t.Fatalf("Unexpected pattern %s", got)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"hertz_demo/idl"
+)
+
+type catalogField struct {
+	ID           int32  `json:"id"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Requiredness string `json:"requiredness"`
+}
+
+type catalogStruct struct {
+	Type   string         `json:"type"`
+	Fields []catalogField `json:"fields,omitempty"`
+}
+
+type catalogMethod struct {
+	Name     string         `json:"name"`
+	Oneway   bool           `json:"oneway,omitempty"`
+	Request  *catalogStruct `json:"request"`
+	Response *catalogStruct `json:"response"`
+}
+
+type catalogService struct {
+	Name          string                    `json:"name"`
+	IDL           string                    `json:"idl"`
+	Instances     *int                      `json:"instances"`
+	RegistryError string                    `json:"registryError,omitempty"`
+	Methods       []catalogMethod           `json:"methods"`
+	Structs       map[string][]catalogField `json:"structs"`
+}
+
+func toCatalogFields(fields []*idl.Field) []catalogField {
+	out := []catalogField{}
+	for _, f := range fields {
+		out = append(out, catalogField{ID: f.ID, Name: f.Name, Type: f.Type.String(), Requiredness: f.Requiredness})
+	}
+	return out
+}
+
+func toCatalogStruct(t *idl.Type) *catalogStruct {
+	if t == nil {
+		return nil
+	}
+	cs := &catalogStruct{Type: t.String()}
+	if t.Category == idl.Struct {
+		cs.Fields = toCatalogFields(t.Struct.Fields)
+	}
+	return cs
+}
+
+/**
+ * Builds the service catalog from the IDLs in a directory.
+ *
+ * @param dir The directory holding the .thrift files.
+ * @param registryIP The address of the service registry used for live instance counts.
+ *
+ * @return One entry per service found, with its methods, the fields of the request and
+ *         response structs, every struct the IDL references and the healthy instance count.
+ * @return An error if any IDL fails to parse.
+ */
+func buildCatalog(dir string, registryIP string) ([]catalogService, error) {
+	docs, err := idl.ParseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []catalogService{}
+	for _, doc := range docs {
+		structs := map[string][]catalogField{}
+		for _, name := range doc.StructNames() {
+			structs[name] = toCatalogFields(doc.Structs[name].Fields)
+		}
+
+		for _, svc := range doc.Services {
+			entry := catalogService{Name: svc.Name, IDL: doc.File, Structs: structs}
+
+			for _, m := range svc.Methods {
+				entry.Methods = append(entry.Methods, catalogMethod{
+					Name:     m.Name,
+					Oneway:   m.Oneway,
+					Request:  toCatalogStruct(m.Request()),
+					Response: toCatalogStruct(m.Response),
+				})
+			}
+
+			if hostInfo := getServiceHosts(svc.Name, registryIP); hostInfo != nil {
+				hostList, _ := hostInfo["hosts"].([]interface{})
+				healthy := countHealthyHosts(hostList)
+				entry.Instances = &healthy
+			} else {
+				entry.RegistryError = "registry unreachable"
+			}
+
+			services = append(services, entry)
+		}
+	}
+	return services, nil
+}
+
+/**
+ * Serves /catalog, listing every service in the thriftFiles directory with its methods and schemas.
+ */
+func catalogHandler(ctx context.Context, c *app.RequestContext) {
+	services, err := buildCatalog(thriftDirectory, serviceRegistryIP)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	c.JSON(consts.StatusOK, utils.H{"services": services})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCatalogListsServicesAndMethods(t *testing.T) {
+	services, err := buildCatalog(thriftDirectory, "http://127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("Should build the catalog: %v", err)
+	}
+
+	byName := map[string]catalogService{}
+	for _, s := range services {
+		byName[s.Name] = s
+	}
+	review, ok := byName["ReviewService"]
+	if !ok {
+		t.Fatalf("ReviewService missing from catalog")
+	}
+	if _, ok := byName["TravelService"]; !ok {
+		t.Fatalf("TravelService missing from catalog")
+	}
+	if len(review.Methods) != 3 {
+		t.Fatalf("ReviewService should have 3 methods, got %d", len(review.Methods))
+	}
+
+	send := review.Methods[0]
+	if send.Name != "sendReview" || send.Request.Type != "ReviewRequest" {
+		t.Fatalf("Unexpected first method %+v", send)
+	}
+	if send.Request.Fields[1].Name != "userID" || send.Request.Fields[1].Type != "i64" {
+		t.Fatalf("Unexpected request field %+v", send.Request.Fields[1])
+	}
+	if review.Instances != nil || review.RegistryError == "" {
+		t.Fatalf("Instance count should be unknown when the registry is unreachable")
+	}
+}
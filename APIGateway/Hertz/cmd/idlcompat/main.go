@@ -0,0 +1,44 @@
+// Command idlcompat compares two sets of Thrift IDLs, such as the gateway's and the backend's
+// copies, and classifies every difference as compatible or breaking.
+//
+//	go run ./cmd/idlcompat ./thriftFiles ../../RPCBackend/server/thriftFiles
+//
+// It exits with status 1 when a breaking change is found and 2 when the IDLs cannot be parsed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"hertz_demo/idl"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: idlcompat <old-dir> <new-dir>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	changes, err := idl.CompareDirs(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error comparing IDLs:", err)
+		os.Exit(2)
+	}
+	breaking := 0
+	for _, c := range changes {
+		fmt.Println(c)
+		if c.Breaking {
+			breaking++
+		}
+	}
+	fmt.Printf("%d changes, %d breaking\n", len(changes), breaking)
+	if breaking > 0 {
+		os.Exit(1)
+	}
+}
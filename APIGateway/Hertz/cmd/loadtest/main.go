@@ -0,0 +1,138 @@
+// Command loadtest generates load from a scenario file, against the gateway or straight against
+// the backends, and reports throughput, latency percentiles and errors by kind.
+//
+//	go run ./cmd/loadtest -scenario ./cmd/loadtest/scenario.json -target http://127.0.0.1:8881
+//	go run ./cmd/loadtest -scenario ./cmd/loadtest/scenario.json -target http://127.0.0.1:8881 \
+//	    -header "Authorization: Bearer $TOKEN"
+//	go run ./cmd/loadtest -scenario ./cmd/loadtest/scenario.json \
+//	    -backend TravelService=127.0.0.1:8888,TravelService=127.0.0.1:8889,ReviewService=127.0.0.1:8887
+//
+// It exits with status 1 when a call failed and 2 when the scenario is invalid.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"hertz_demo/loadtest"
+)
+
+func main() {
+	scenarioPath := flag.String("scenario", "", "path of the scenario file")
+	target := flag.String("target", "", "base URL of the gateway, e.g. http://127.0.0.1:8881")
+	backend := flag.String("backend", "", "comma-separated Service=host:port backends to call directly instead of a gateway")
+	idlDir := flag.String("idl-dir", "./thriftFiles", "directory of the IDLs, for -backend")
+	headers := headerFlag{}
+	flag.Var(headers, "header", `"Name: value" header to send with every call to -target, e.g. an Authorization token; repeatable`)
+	flag.Parse()
+	if *scenarioPath == "" || (*target == "") == (*backend == "") {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: loadtest -scenario FILE (-target URL | -backend Service=host:port,...)")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	scenario, err := loadtest.Load(*scenarioPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading scenario:", err)
+		os.Exit(2)
+	}
+
+	var call loadtest.Caller
+	if *target != "" {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = scenario.Concurrency
+		call = loadtest.GatewayCaller(strings.TrimRight(*target, "/"), &http.Client{Transport: transport}, http.Header(headers))
+	} else {
+		addrs, err := parseBackends(*backend)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		call = loadtest.BackendCaller(addrs, *idlDir)
+	}
+
+	// Ctrl-C ends the test early and still prints the report.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := loadtest.Run(ctx, scenario, call)
+	printReport(report)
+	if report.Total.Failed() > 0 {
+		os.Exit(1)
+	}
+}
+
+// headerFlag collects repeated -header "Name: value" flags.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headerFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("want \"Name: value\", got %q", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
+
+// parseBackends reads "Service=host:port" pairs; a service may be listed once per instance.
+func parseBackends(s string) (map[string][]string, error) {
+	addrs := map[string][]string{}
+	for _, pair := range strings.Split(s, ",") {
+		service, addr, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || service == "" || addr == "" {
+			return nil, fmt.Errorf("-backend wants Service=host:port pairs, got %q", pair)
+		}
+		addrs[service] = append(addrs[service], addr)
+	}
+	return addrs, nil
+}
+
+func printReport(r *loadtest.Report) {
+	fmt.Printf("%d calls in %s, %.1f calls/s, %d failed\n\n", r.Total.Calls, r.Elapsed.Round(time.Millisecond), r.Throughput(), r.Total.Failed())
+
+	methods := make([]string, 0, len(r.ByMethod))
+	for m := range r.ByMethod {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	fmt.Printf("%-40s %8s %8s %10s %10s %10s %10s\n", "method", "calls", "failed", "p50", "p90", "p99", "max")
+	for _, m := range methods {
+		printStats(m, r.ByMethod[m])
+	}
+	printStats("total", r.Total)
+
+	if r.Total.Failed() == 0 {
+		return
+	}
+	fmt.Println("\nerrors by kind:")
+	for _, m := range append(methods, "total") {
+		stats := r.Total
+		if m != "total" {
+			stats = r.ByMethod[m]
+		}
+		kinds := make([]string, 0, len(stats.Errors))
+		for k := range stats.Errors {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		for _, k := range kinds {
+			fmt.Printf("%-40s %-20s %8d\n", m, k, stats.Errors[k])
+		}
+	}
+}
+
+func printStats(name string, s *loadtest.Stats) {
+	round := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+	fmt.Printf("%-40s %8d %8d %10s %10s %10s %10s\n", name, s.Calls, s.Failed(),
+		round(s.Percentile(50)), round(s.Percentile(90)), round(s.Percentile(99)), round(s.Percentile(100)))
+}
@@ -0,0 +1,216 @@
+// Command replay sends calls recorded by the gateway again, to a gateway or straight to a
+// backend, and reports where the answers differ from the recorded ones.
+//
+//	go run ./cmd/replay -target http://127.0.0.1:8881 ./log/traffic
+//	go run ./cmd/replay -target http://127.0.0.1:8881 -header "Authorization: Bearer $TOKEN" ./log/traffic
+//	go run ./cmd/replay -backend 127.0.0.1:8887 -ignore BaseResp ./log/traffic
+//
+// It exits with status 1 when an answer differs or a call fails, and 2 when the recordings
+// cannot be read.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/kitex/client"
+	"github.com/cloudwego/kitex/client/genericclient"
+	"github.com/cloudwego/kitex/pkg/generic"
+	"github.com/cloudwego/kitex/transport"
+
+	"hertz_demo/traffic"
+)
+
+func main() {
+	target := flag.String("target", "", "base URL of the gateway to send the calls to, e.g. http://127.0.0.1:8881")
+	backend := flag.String("backend", "", "host:port of a backend to call directly instead of a gateway")
+	idlDir := flag.String("idl-dir", "./thriftFiles", "directory of the IDLs, for -backend")
+	ignore := flag.String("ignore", "", "comma-separated response fields to leave out of the comparison")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout of each call")
+	show := flag.Int("show", 20, "number of differing calls to print")
+	headers := headerFlag{}
+	flag.Var(headers, "header", `"Name: value" header to send with every call to -target, e.g. an Authorization token; repeatable`)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: replay (-target URL | -backend host:port) <recording file or dir>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || (*target == "") == (*backend == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	records, err := traffic.Read(flag.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading recordings:", err)
+		os.Exit(2)
+	}
+
+	var send traffic.Sender
+	if *target != "" {
+		send = gatewaySender(strings.TrimRight(*target, "/"), &http.Client{Timeout: *timeout}, http.Header(headers))
+	} else {
+		send = backendSender(*backend, *idlDir, *timeout)
+	}
+	var ignored []string
+	if *ignore != "" {
+		ignored = strings.Split(*ignore, ",")
+	}
+
+	report := traffic.Replay(context.Background(), records, send, ignored)
+	printReport(report, *show)
+	if report.Differed() {
+		os.Exit(1)
+	}
+}
+
+// headerFlag collects repeated -header "Name: value" flags.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headerFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("want \"Name: value\", got %q", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
+
+// gatewaySender posts each request to the gateway's /{service}/{method} route, with headers
+// such as the caller's token, since recordings keep none.
+func gatewaySender(base string, httpClient *http.Client, headers http.Header) traffic.Sender {
+	return func(ctx context.Context, r traffic.Record) (int, interface{}, error) {
+		body, err := json.Marshal(r.Request)
+		if err != nil {
+			return 0, nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/"+r.Service+"/"+r.Method, bytes.NewReader(body))
+		if err != nil {
+			return 0, nil, err
+		}
+		for name, values := range headers {
+			req.Header[name] = values
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, string(data), nil
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return 0, nil, fmt.Errorf("response is not JSON: %w", err)
+		}
+		return resp.StatusCode, decoded, nil
+	}
+}
+
+// backendSender makes a generic call to the backend for each request, with one client per
+// service built from its IDL. Errors the backend returns are reported as a 400, as the gateway
+// answers them.
+func backendSender(addr string, idlDir string, timeout time.Duration) traffic.Sender {
+	var mu sync.Mutex
+	clients := map[string]genericclient.Client{}
+	clientFor := func(service string) (genericclient.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cli, ok := clients[service]; ok {
+			return cli, nil
+		}
+		p, err := generic.NewThriftFileProvider(filepath.Join(idlDir, service+".thrift"))
+		if err != nil {
+			return nil, err
+		}
+		g, err := generic.JSONThriftGeneric(p)
+		if err != nil {
+			return nil, err
+		}
+		cli, err := genericclient.NewClient(service, g,
+			client.WithHostPorts(addr),
+			client.WithRPCTimeout(timeout),
+			client.WithTransportProtocol(transport.TTHeader))
+		if err != nil {
+			return nil, err
+		}
+		clients[service] = cli
+		return cli, nil
+	}
+
+	return func(ctx context.Context, r traffic.Record) (int, interface{}, error) {
+		cli, err := clientFor(r.Service)
+		if err != nil {
+			return 0, nil, err
+		}
+		body, err := json.Marshal(r.Request)
+		if err != nil {
+			return 0, nil, err
+		}
+		resp, err := cli.GenericCall(ctx, r.Method, string(body))
+		if err != nil {
+			return http.StatusBadRequest, err.Error(), nil
+		}
+		str, ok := resp.(string)
+		if !ok {
+			return 0, nil, fmt.Errorf("unexpected response type %T", resp)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(str), &decoded); err != nil {
+			return 0, nil, fmt.Errorf("response is not JSON: %w", err)
+		}
+		return http.StatusOK, decoded, nil
+	}
+}
+
+func printReport(report *traffic.Report, show int) {
+	shown := 0
+	for _, res := range report.Results {
+		if res.Skipped || (res.Err == nil && len(res.Diffs) == 0) {
+			continue
+		}
+		if shown == show {
+			fmt.Println("...")
+			break
+		}
+		shown++
+		fmt.Printf("%s.%s recorded %s\n", res.Record.Service, res.Record.Method, res.Record.Time.Format(time.RFC3339))
+		if res.Err != nil {
+			fmt.Println("    failed:", res.Err)
+		}
+		for _, d := range res.Diffs {
+			fmt.Println("   ", d)
+		}
+	}
+
+	methods := make([]string, 0, len(report.ByMethod))
+	for m := range report.ByMethod {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	fmt.Printf("\n%-40s %8s %8s %8s %8s %8s\n", "method", "total", "matched", "differed", "failed", "skipped")
+	for _, m := range methods {
+		r := report.ByMethod[m]
+		fmt.Printf("%-40s %8d %8d %8d %8d %8d\n", m, r.Total, r.Matched, r.Differed, r.Failed, r.Skipped)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"golang.org/x/sync/singleflight"
+)
+
+/**
+ * coalesceConfig lists the methods, as "Service/method", whose identical concurrent calls
+ * share a single backend call.
+ */
+type coalesceConfig struct {
+	Methods []string `json:"methods"`
+}
+
+var (
+	coalesceRequests = newCounterVec("gateway_coalesce_requests_total",
+		"Calls to coalescing-enabled methods.", "service", "method")
+	coalescedCalls = newCounterVec("gateway_coalesced_calls_total",
+		"Calls that were answered by another identical in-flight call instead of reaching the backend.", "service", "method")
+)
+
+/**
+ * coalescer collapses identical in-flight calls, matched on service, method and canonical body.
+ */
+type coalescer struct {
+	group   singleflight.Group
+	methods map[string]bool
+}
+
+/**
+ * Creates the coalescer for the configured methods.
+ *
+ * @return nil when no method opts in.
+ */
+func newCoalescer(cfg coalesceConfig) *coalescer {
+	if len(cfg.Methods) == 0 {
+		return nil
+	}
+	co := &coalescer{methods: map[string]bool{}}
+	for _, m := range cfg.Methods {
+		co.methods[m] = true
+	}
+	return co
+}
+
+/**
+ * Wraps a caller so opted-in methods go through the singleflight group.
+ *
+ * @param fetch The caller that reaches the backend.
+ *
+ * @return A caller with the same signature. Every caller collapsed onto an in-flight call gets
+ *         the leader's response and error, and the leader's context is the one used for the call.
+ */
+func (co *coalescer) Wrap(fetch genericCaller) genericCaller {
+	if co == nil {
+		return fetch
+	}
+	return func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		if !co.methods[service+"/"+method] {
+			return fetch(ctx, service, method, body)
+		}
+
+		// encoding/json sorts map keys, giving a canonical form of the body.
+		canonical, err := json.Marshal(body)
+		if err != nil {
+			return fetch(ctx, service, method, body)
+		}
+
+		coalesceRequests.Inc(service, method)
+		ran := false
+		key := requestNamespace(ctx) + "/" + service + "/" + method + ":" + string(canonical)
+		resp, err, _ := co.group.Do(key, func() (interface{}, error) {
+			ran = true
+			return fetch(ctx, service, method, body)
+		})
+		if !ran {
+			coalescedCalls.Inc(service, method)
+		}
+		return resp, err
+	}
+}
+
+// gatewayCoalescer is built from the config, nil when coalescing is off.
+var gatewayCoalescer *coalescer
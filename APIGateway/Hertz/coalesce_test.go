@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescerSharesIdenticalInFlightCalls(t *testing.T) {
+	co := newCoalescer(coalesceConfig{Methods: []string{"TravelService/RetrieveClientData"}})
+
+	var backendCalls int32
+	release := make(chan struct{})
+	fetch := co.Wrap(func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		atomic.AddInt32(&backendCalls, 1)
+		<-release
+		return map[string]interface{}{"Name": "Ryan"}, nil
+	})
+
+	before := coalescedCalls.Value("TravelService", "RetrieveClientData")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := fetch(context.Background(), "TravelService", "RetrieveClientData", map[string]interface{}{"userID": 1.0})
+			if err != nil || resp.(map[string]interface{})["Name"] != "Ryan" {
+				t.Errorf("Unexpected response %v %v", resp, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if backendCalls != 1 {
+		t.Fatalf("Identical calls should share one backend call, got %d", backendCalls)
+	}
+	if got := coalescedCalls.Value("TravelService", "RetrieveClientData") - before; got != 4 {
+		t.Fatalf("4 calls should be reported as collapsed, got %v", got)
+	}
+}
+
+func TestCoalescerSkipsMethodsWithoutOptIn(t *testing.T) {
+	co := newCoalescer(coalesceConfig{Methods: []string{"TravelService/RetrieveClientData"}})
+
+	var backendCalls int32
+	release := make(chan struct{})
+	fetch := co.Wrap(func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		atomic.AddInt32(&backendCalls, 1)
+		<-release
+		return nil, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetch(context.Background(), "ReviewService", "sendReview", map[string]interface{}{"userID": 1.0})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if backendCalls != 3 {
+		t.Fatalf("Methods without opt-in should not be coalesced, got %d backend calls", backendCalls)
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	c := newCounterVec("test_exposition_total", "Test counter.", "service")
+	c.Inc("TravelService")
+	var b strings.Builder
+	c.write(&b)
+	if !strings.Contains(b.String(), `test_exposition_total{service="TravelService"} 1`) {
+		t.Fatalf("Unexpected exposition:\n%s", b.String())
+	}
+}
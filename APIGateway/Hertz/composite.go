@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+/**
+ * compositeCall is one generic call made by a composite route.
+ *
+ * Body is the request sent to the method. String values may reference the incoming request
+ * or the response of an earlier call with ${request.userID} or ${client.Name}; a value that
+ * is exactly one reference keeps the referenced JSON type. A call that references another call,
+ * or lists it in DependsOn, waits for it to finish; all other calls run in parallel.
+ */
+type compositeCall struct {
+	Name      string                 `json:"name"`
+	Service   string                 `json:"service"`
+	Method    string                 `json:"method"`
+	Body      map[string]interface{} `json:"body"`
+	DependsOn []string               `json:"dependsOn"`
+}
+
+/**
+ * compositeRoute maps one HTTP route onto several generic calls whose results are merged.
+ */
+type compositeRoute struct {
+	Path  string          `json:"path"`
+	Calls []compositeCall `json:"calls"`
+}
+
+var templateRef = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)((?:\.[A-Za-z0-9_]+)*)\}`)
+
+/**
+ * Returns the names of the calls a call waits for: DependsOn plus every call its body references.
+ */
+func (cc compositeCall) dependencies() []string {
+	deps := map[string]bool{}
+	for _, d := range cc.DependsOn {
+		deps[d] = true
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case string:
+			for _, m := range templateRef.FindAllStringSubmatch(val, -1) {
+				if m[1] != "request" {
+					deps[m[1]] = true
+				}
+			}
+		case map[string]interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		}
+	}
+	walk(cc.Body)
+
+	out := []string{}
+	for d := range deps {
+		out = append(out, d)
+	}
+	return out
+}
+
+/**
+ * Checks that call names are unique, dependencies exist and there is no dependency cycle.
+ *
+ * @return An error describing the first problem found.
+ */
+func (r compositeRoute) validate() error {
+	if r.Path == "" || len(r.Calls) == 0 {
+		return fmt.Errorf("composite route %q needs a path and at least one call", r.Path)
+	}
+
+	deps := map[string][]string{}
+	for _, call := range r.Calls {
+		if call.Name == "" || call.Name == "request" {
+			return fmt.Errorf("composite route %s: invalid call name %q", r.Path, call.Name)
+		}
+		if _, dup := deps[call.Name]; dup {
+			return fmt.Errorf("composite route %s: duplicate call name %q", r.Path, call.Name)
+		}
+		deps[call.Name] = call.dependencies()
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("composite route %s: dependency cycle through %q", r.Path, name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, d := range deps[name] {
+			if _, ok := deps[d]; !ok {
+				return fmt.Errorf("composite route %s: call %q depends on unknown call %q", r.Path, name, d)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for name := range deps {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Looks up a dotted path such as "VisitedCountries.0" inside a decoded JSON value.
+ */
+func lookupPath(v interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			v = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+/**
+ * Replaces ${name.path} references in a call body with values from the request or earlier results.
+ *
+ * @param v The body, or a part of it, to expand.
+ * @param scope The incoming request under "request" and every finished call under its name.
+ *
+ * @return The expanded value, or an error naming the first reference that could not be resolved.
+ */
+func expandTemplate(v interface{}, scope map[string]interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		// A value that is exactly one reference keeps the type of what it refers to.
+		if m := templateRef.FindStringSubmatch(val); m != nil && m[0] == val {
+			return resolveRef(m, scope)
+		}
+		var firstErr error
+		out := templateRef.ReplaceAllStringFunc(val, func(ref string) string {
+			resolved, err := resolveRef(templateRef.FindStringSubmatch(ref), scope)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return ref
+			}
+			if s, ok := resolved.(string); ok {
+				return s
+			}
+			b, _ := json.Marshal(resolved)
+			return string(b)
+		})
+		return out, firstErr
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			expanded, err := expandTemplate(item, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = expanded
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			expanded, err := expandTemplate(item, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func resolveRef(match []string, scope map[string]interface{}) (interface{}, error) {
+	root, ok := scope[match[1]]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference %s", match[0])
+	}
+	path := []string{}
+	if match[2] != "" {
+		path = strings.Split(strings.TrimPrefix(match[2], "."), ".")
+	}
+	v, ok := lookupPath(root, path)
+	if !ok {
+		return nil, fmt.Errorf("reference %s not found", match[0])
+	}
+	return v, nil
+}
+
+/**
+ * Runs every call of a composite route, honouring dependencies, and merges the results.
+ *
+ * @param ctx The request context.
+ * @param route The composite route being served.
+ * @param request The decoded JSON body of the incoming request.
+ * @param call Performs a single generic call.
+ *
+ * @return The successful results and the errors, both keyed by call name. A call whose
+ *         dependency failed is skipped and reported as an error.
+ */
+func runComposite(ctx context.Context, route compositeRoute, request map[string]interface{}, call genericCaller) (map[string]interface{}, map[string]string) {
+	var mu sync.Mutex
+	results := map[string]interface{}{}
+	errs := map[string]string{}
+	finished := map[string]chan struct{}{}
+	for _, c := range route.Calls {
+		finished[c.Name] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range route.Calls {
+		wg.Add(1)
+		go func(c compositeCall) {
+			defer wg.Done()
+			defer close(finished[c.Name])
+
+			for _, dep := range c.dependencies() {
+				<-finished[dep]
+				mu.Lock()
+				_, failed := errs[dep]
+				mu.Unlock()
+				if failed {
+					mu.Lock()
+					errs[c.Name] = fmt.Sprintf("skipped: dependency %s failed", dep)
+					mu.Unlock()
+					return
+				}
+			}
+
+			mu.Lock()
+			scope := map[string]interface{}{"request": request}
+			for name, res := range results {
+				scope[name] = res
+			}
+			mu.Unlock()
+
+			body, err := expandTemplate(c.Body, scope)
+			var resp interface{}
+			if err == nil {
+				bodyMap, _ := body.(map[string]interface{})
+				resp, err = call(ctx, c.Service, c.Method, bodyMap)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[c.Name] = err.Error()
+				return
+			}
+			results[c.Name] = resp
+		}(c)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+/**
+ * Registers a POST handler for every configured composite route.
+ *
+ * @param h The Hertz server.
+ * @param routes The composite routes from the gateway config.
+ * @param call Performs each generic call of a route.
+ *
+ * @return An error if any route is invalid; nothing is registered in that case.
+ */
+func registerCompositeRoutes(h *server.Hertz, routes []compositeRoute, call genericCaller) error {
+	for _, route := range routes {
+		if err := route.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range routes {
+		route := route
+		h.POST(route.Path, func(ctx context.Context, c *app.RequestContext) {
+			request := map[string]interface{}{}
+			if body := c.GetRawData(); len(body) > 0 {
+				if err := json.Unmarshal(body, &request); err != nil {
+					c.String(consts.StatusBadRequest, "bad post request")
+					return
+				}
+			}
+
+			results, errs := runComposite(ctx, route, request, call)
+
+			status := consts.StatusOK
+			if len(results) == 0 {
+				status = consts.StatusBadGateway
+			}
+			c.JSON(status, utils.H{
+				"results": results,
+				"errors":  errs,
+				"partial": len(errs) > 0,
+			})
+		})
+	}
+	return nil
+}
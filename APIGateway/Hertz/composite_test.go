@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompositeRunsDependentCallsWithEarlierResults(t *testing.T) {
+	route := compositeRoute{Path: "/composite/home", Calls: []compositeCall{
+		{Name: "client", Service: "TravelService", Method: "RetrieveClientData", Body: map[string]interface{}{"userID": "${request.userID}"}},
+		{Name: "destinations", Service: "TravelService", Method: "GetAllTravelDestinations", Body: map[string]interface{}{"userID": "${request.userID}"}},
+		{Name: "review", Service: "ReviewService", Method: "sendReview", Body: map[string]interface{}{"data": "hello ${client.Name}", "userID": "${client.userID}"}},
+	}}
+	if err := route.validate(); err != nil {
+		t.Fatalf("Route should be valid: %v", err)
+	}
+
+	var reviewBody map[string]interface{}
+	results, errs := runComposite(context.Background(), route, map[string]interface{}{"userID": 7.0},
+		func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+			switch method {
+			case "RetrieveClientData":
+				return map[string]interface{}{"Name": "Ryan", "userID": body["userID"]}, nil
+			case "sendReview":
+				reviewBody = body
+			}
+			return map[string]interface{}{"ok": true}, nil
+		})
+
+	if len(errs) != 0 {
+		t.Fatalf("Should not report errors, got %v", errs)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Should merge 3 results, got %v", results)
+	}
+	if reviewBody["data"] != "hello Ryan" || reviewBody["userID"] != 7.0 {
+		t.Fatalf("sendReview body should use the client result, got %v", reviewBody)
+	}
+}
+
+func TestCompositeReportsPartialFailures(t *testing.T) {
+	route := compositeRoute{Path: "/composite/home", Calls: []compositeCall{
+		{Name: "client", Service: "TravelService", Method: "RetrieveClientData"},
+		{Name: "destinations", Service: "TravelService", Method: "GetAllTravelDestinations"},
+		{Name: "review", Service: "ReviewService", Method: "sendReview", DependsOn: []string{"client"}},
+	}}
+
+	results, errs := runComposite(context.Background(), route, map[string]interface{}{},
+		func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+			if method == "RetrieveClientData" {
+				return nil, errors.New("service name not found")
+			}
+			return map[string]interface{}{}, nil
+		})
+
+	if _, ok := results["destinations"]; !ok {
+		t.Fatalf("Independent call should still succeed")
+	}
+	if errs["client"] != "service name not found" {
+		t.Fatalf("Failed call should report its error, got %v", errs)
+	}
+	if errs["review"] != "skipped: dependency client failed" {
+		t.Fatalf("Dependent call should be skipped, got %v", errs)
+	}
+}
+
+func TestCompositeRejectsCycles(t *testing.T) {
+	route := compositeRoute{Path: "/cycle", Calls: []compositeCall{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", Body: map[string]interface{}{"x": "${a.y}"}},
+	}}
+	if err := route.validate(); err == nil {
+		t.Fatalf("Should reject a dependency cycle")
+	}
+
+	route = compositeRoute{Path: "/unknown", Calls: []compositeCall{{Name: "a", DependsOn: []string{"missing"}}}}
+	if err := route.validate(); err == nil {
+		t.Fatalf("Should reject an unknown dependency")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+/**
+ * gatewayConfig holds the settings the gateway reads at startup from its JSON config file.
+ * Every section is optional; anything left out keeps the value from defaultGatewayConfig.
+ */
+type gatewayConfig struct {
+	// Services lists the backend services the gateway routes to; readiness checks each of them.
+	Services []string      `json:"services"`
+	Tracing  tracingConfig `json:"tracing"`
+	// CompositeRoutes fan one HTTP request out to several generic calls.
+	CompositeRoutes []compositeRoute  `json:"compositeRoutes"`
+	GraphQL         graphQLConfig     `json:"graphql"`
+	Cache           cacheConfig       `json:"cache"`
+	Coalesce        coalesceConfig    `json:"coalesce"`
+	Idempotency     idempotencyConfig `json:"idempotency"`
+	Security        securityConfig    `json:"security"`
+	JSONRPC         jsonRPCConfig     `json:"jsonrpc"`
+	// TLS serves the gateway over HTTPS; BackendTLS secures generic calls with mutual TLS.
+	TLS        tlsConfig        `json:"tls"`
+	BackendTLS backendTLSConfig `json:"backendTLS"`
+	// Streams relay backend changes to WebSocket and Server-Sent Events clients.
+	Streams streamConfig `json:"streams"`
+	// Passthrough forwards raw Thrift binary from internal callers.
+	Passthrough passthroughConfig `json:"passthrough"`
+	// Policies sets the timeout, load balancer and rate limit per backend service.
+	Policies map[string]servicePolicy `json:"policies"`
+	Admin    adminConfig              `json:"admin"`
+	// Routes and Auth control POST /{service}/{method}; with DynamicConfig they, and Policies,
+	// follow a Nacos dataId instead.
+	Routes        []routeRule         `json:"routes"`
+	Auth          authConfig          `json:"auth"`
+	DynamicConfig dynamicConfigSource `json:"dynamicConfig"`
+	Nacos         nacosConfig         `json:"nacos"`
+	// InstanceCheck compares the IDL hash backend instances publish with the gateway's own.
+	InstanceCheck instanceCheckConfig `json:"instanceCheck"`
+	// IDLCompat compares the gateway's IDLs with the backend's copy at startup.
+	IDLCompat idlCompatConfig `json:"idlCompat"`
+	// Recording writes sampled calls to files that cmd/replay can send again.
+	Recording recordingConfig `json:"recording"`
+	// Shadow mirrors a share of calls to shadow instances and compares their responses.
+	Shadow shadowConfig `json:"shadow"`
+}
+
+// gatewayCfg is the configuration the running gateway was started with.
+var gatewayCfg = defaultGatewayConfig()
+
+/**
+ * Returns the configuration used when no config file is present.
+ *
+ * @return A gatewayConfig with every section set to its default.
+ */
+func defaultGatewayConfig() gatewayConfig {
+	return gatewayConfig{
+		Services: []string{"TravelService", "ReviewService"},
+		Tracing: tracingConfig{
+			Exporter:    "none",
+			ServiceName: "api-gateway",
+			SampleRatio: 1,
+		},
+		Security: securityConfig{
+			Headers:      defaultSecurityHeaders(),
+			MaxBodyBytes: 1 << 20,
+			MaxJSONDepth: 32,
+		},
+		JSONRPC: jsonRPCConfig{
+			MaxBatch: 100,
+			Workers:  8,
+		},
+		Nacos: nacosConfig{
+			ServerAddr: "http://127.0.0.1:8848",
+			Namespace:  "public",
+			Group:      "DEFAULT_GROUP",
+		},
+		Admin: adminConfig{
+			Addr:     "127.0.0.1:9881",
+			AuditLog: "./log/audit.log",
+		},
+		InstanceCheck: instanceCheckConfig{IDLMismatch: idlMismatchWarn},
+		Recording: recordingConfig{
+			Dir:          "./log/traffic",
+			SampleRate:   1,
+			MaxFileBytes: 10 << 20,
+			MaxFiles:     10,
+		},
+		Shadow: shadowConfig{
+			DiffSampleRate: 1,
+			MaxDiffs:       100,
+			MaxInFlight:    64,
+		},
+	}
+}
+
+/**
+ * Loads the gateway configuration from a JSON file.
+ *
+ * @param path The path of the JSON config file.
+ *
+ * @return The defaults overlaid with the values found in the file. A missing file is not an
+ *         error and simply yields the defaults.
+ * @return An error if the file exists but cannot be read or parsed.
+ */
+func loadGatewayConfig(path string) (gatewayConfig, error) {
+	cfg := defaultGatewayConfig()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+/**
+ * duration is a time.Duration written in the config file as a string such as "30s" or "5m".
+ */
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,152 @@
+// Package contract generates end-to-end contract cases for the gateway's POST
+// /{service}/{method} routes from the Thrift IDLs.
+//
+// For every method it produces a valid payload, one payload per missing required field and one
+// payload per wrongly typed field, each with the status the gateway must answer with and, for
+// valid payloads, the response type the answer must conform to. The cases are derived from the
+// IDLs each time they are generated, so they follow the IDLs without a generated file to
+// keep in step.
+package contract
+
+import (
+	"fmt"
+	"net/http"
+
+	"hertz_demo/idl"
+)
+
+// Case kinds.
+const (
+	Valid           = "valid"
+	MissingRequired = "missing_required"
+	WrongType       = "wrong_type"
+)
+
+// Case is one request the gateway must answer as described.
+type Case struct {
+	// Name identifies the case, e.g. "TravelService.SendClientData missing Msg".
+	Name    string
+	Service string
+	Method  string
+	Kind    string
+	Body    map[string]interface{}
+	// WantStatus is 200 for valid payloads and 400 for the others.
+	WantStatus int
+	// Response is the type a 200 answer must conform to, nil for void methods.
+	Response *idl.Type
+}
+
+// Generate returns the cases for every method of every service in docs, in IDL order.
+func Generate(docs []*idl.Document) []Case {
+	cases := []Case{}
+	for _, doc := range docs {
+		for _, svc := range doc.Services {
+			for _, m := range svc.Methods {
+				if m.Oneway {
+					continue
+				}
+				cases = append(cases, methodCases(svc.Name, m)...)
+			}
+		}
+	}
+	return cases
+}
+
+func methodCases(service string, m *idl.Method) []Case {
+	prefix := service + "." + m.Name
+	newCase := func(name string, kind string, body map[string]interface{}, status int) Case {
+		return Case{Name: prefix + name, Service: service, Method: m.Name, Kind: kind, Body: body, WantStatus: status, Response: m.Response}
+	}
+
+	req := m.Request()
+	if req == nil || req.Category != idl.Struct {
+		return []Case{newCase(" valid", Valid, map[string]interface{}{}, http.StatusOK)}
+	}
+	valid, _ := Sample(req).(map[string]interface{})
+	cases := []Case{newCase(" valid", Valid, valid, http.StatusOK)}
+
+	for _, f := range req.Struct.Fields {
+		if f.Requiredness == idl.Required {
+			body := copyBody(valid)
+			delete(body, f.Name)
+			cases = append(cases, newCase(" missing "+f.Name, MissingRequired, body, http.StatusBadRequest))
+		}
+	}
+	for _, f := range req.Struct.Fields {
+		body := copyBody(valid)
+		body[f.Name] = wrongValue(f.Type)
+		cases = append(cases, newCase(" wrong type "+f.Name, WrongType, body, http.StatusBadRequest))
+	}
+	return cases
+}
+
+func copyBody(body map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		out[k] = v
+	}
+	return out
+}
+
+// Sample returns a value of type t with every field set, as encoding/json would decode it.
+// A struct that contains itself is left out below its first level.
+func Sample(t *idl.Type) interface{} {
+	return sample(t, map[*idl.StructDef]bool{})
+}
+
+func sample(t *idl.Type, open map[*idl.StructDef]bool) interface{} {
+	switch t.Category {
+	case idl.Bool:
+		return true
+	case idl.Byte, idl.I16, idl.I32, idl.I64:
+		return float64(1)
+	case idl.Double:
+		return 1.5
+	case idl.String, idl.Binary:
+		return "sample"
+	case idl.Enum:
+		if len(t.EnumValues) > 0 {
+			return float64(t.EnumValues[0])
+		}
+		return float64(0)
+	case idl.List, idl.Set:
+		return []interface{}{sample(t.Value, open)}
+	case idl.Map:
+		return map[string]interface{}{sampleKey(t.Key): sample(t.Value, open)}
+	case idl.Struct:
+		open[t.Struct] = true
+		defer delete(open, t.Struct)
+		fields := map[string]interface{}{}
+		for _, f := range t.Struct.Fields {
+			if f.Type.Category == idl.Struct && open[f.Type.Struct] {
+				continue
+			}
+			fields[f.Name] = sample(f.Type, open)
+		}
+		return fields
+	}
+	return nil
+}
+
+func sampleKey(t *idl.Type) string {
+	switch t.Category {
+	case idl.Bool:
+		return "true"
+	case idl.Byte, idl.I16, idl.I32, idl.I64, idl.Enum:
+		return "1"
+	case idl.Double:
+		return "1.5"
+	}
+	return "key"
+}
+
+// wrongValue returns a JSON value that can never be read as t.
+func wrongValue(t *idl.Type) interface{} {
+	switch t.Category {
+	case idl.String, idl.Binary:
+		return float64(12345)
+	case idl.Bool, idl.Byte, idl.I16, idl.I32, idl.I64, idl.Double, idl.Enum:
+		return "not a " + t.Category
+	}
+	return fmt.Sprintf("not a %s", t)
+}
@@ -0,0 +1,84 @@
+package contract
+
+import (
+	"net/http"
+	"testing"
+
+	"hertz_demo/idl"
+)
+
+func TestGenerateCoversEveryMethod(t *testing.T) {
+	docs, err := idl.ParseDir("../thriftFiles")
+	if err != nil {
+		t.Fatalf("Should parse thriftFiles: %v", err)
+	}
+	byName := map[string]Case{}
+	for _, c := range Generate(docs) {
+		byName[c.Name] = c
+	}
+
+	for _, name := range []string{
+		"TravelService.SendClientData valid",
+		"TravelService.RetrieveClientData valid",
+		"TravelService.GetAllTravelDestinations valid",
+		"ReviewService.sendReview valid",
+		"ReviewService.editReview valid",
+		"ReviewService.deleteReview valid",
+	} {
+		c, ok := byName[name]
+		if !ok {
+			t.Fatalf("Missing case %q", name)
+		}
+		if c.WantStatus != http.StatusOK || c.Response == nil {
+			t.Fatalf("%s: unexpected case %+v", name, c)
+		}
+		if err := idl.Check(mustRequest(t, docs, c), c.Body); err != nil {
+			t.Fatalf("%s: the valid payload does not conform: %v", name, err)
+		}
+	}
+
+	missing, ok := byName["TravelService.SendClientData missing Msg"]
+	if !ok || missing.Kind != MissingRequired || missing.WantStatus != http.StatusBadRequest {
+		t.Fatalf("Expected a missing Msg case, got %+v", missing)
+	}
+	if _, present := missing.Body["Msg"]; present {
+		t.Fatalf("The missing Msg case still sends Msg")
+	}
+	// ReviewRequest has no required fields, so it gets no missing-field cases.
+	if _, ok := byName["ReviewService.sendReview missing Msg"]; ok {
+		t.Fatalf("Only required fields should get missing-field cases")
+	}
+
+	wrong, ok := byName["ReviewService.sendReview wrong type userID"]
+	if !ok || wrong.Kind != WrongType {
+		t.Fatalf("Expected a wrong type case for userID, got %+v", wrong)
+	}
+	if err := idl.Check(mustRequest(t, docs, wrong), wrong.Body); err == nil {
+		t.Fatalf("The wrong type payload conforms: %v", wrong.Body)
+	}
+}
+
+func mustRequest(t *testing.T, docs []*idl.Document, c Case) *idl.Type {
+	for _, doc := range docs {
+		for _, svc := range doc.Services {
+			if m, ok := svc.Method(c.Method); ok && svc.Name == c.Service {
+				return m.Request()
+			}
+		}
+	}
+	t.Fatalf("No method for %s", c.Name)
+	return nil
+}
+
+func TestSampleStopsAtRecursiveStructs(t *testing.T) {
+	node := &idl.StructDef{Name: "Node"}
+	nodeType := &idl.Type{Category: idl.Struct, Struct: node}
+	node.Fields = []*idl.Field{
+		{ID: 1, Name: "value", Type: &idl.Type{Category: idl.I32}},
+		{ID: 2, Name: "next", Type: nodeType},
+	}
+	got := Sample(nodeType).(map[string]interface{})
+	if _, ok := got["next"]; ok || got["value"] != float64(1) {
+		t.Fatalf("Unexpected sample %v", got)
+	}
+}
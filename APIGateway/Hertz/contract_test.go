@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+
+	"hertz_demo/contract"
+	"hertz_demo/idl"
+)
+
+// TestContract runs the cases generated from every IDL in thriftFiles against the POST route
+// and the backends registered in TestMain.
+func TestContract(t *testing.T) {
+	docs, err := idl.ParseDir(thriftDirectory)
+	if err != nil {
+		t.Fatalf("Should parse thriftFiles: %v", err)
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/:serviceName/:methodName", genericPostHandler)
+
+	for _, tc := range contract.Generate(docs) {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			body, err := json.Marshal(tc.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := ut.PerformRequest(engine, consts.MethodPost, "/"+tc.Service+"/"+tc.Method,
+				&ut.Body{Body: strings.NewReader(string(body)), Len: len(body)})
+			if w.Code != tc.WantStatus {
+				t.Fatalf("Sent %s, expected %d, got %d %s", body, tc.WantStatus, w.Code, w.Body.String())
+			}
+
+			if tc.WantStatus != consts.StatusOK {
+				// Rejected requests get the error as plain text, as the OpenAPI document describes.
+				contentType := string(w.Header().ContentType())
+				if !strings.HasPrefix(contentType, "text/plain") || !strings.HasPrefix(w.Body.String(), errInvalidInputs.Error()) {
+					t.Fatalf("Unexpected error envelope %q: %s", contentType, w.Body.String())
+				}
+				return
+			}
+			if tc.Response == nil {
+				return
+			}
+			var resp interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Response is not JSON: %v", err)
+			}
+			if err := idl.Check(tc.Response, resp); err != nil {
+				t.Fatalf("Response does not conform to %s: %v\n%s", tc.Response, err, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFormatResponseKeepsNumbersAndAcceptsIDLNames(t *testing.T) {
+	cases := []struct {
+		method string
+		body   map[string]interface{}
+		want   string
+	}{
+		{"sendReview", map[string]interface{}{"data": "great", "userID": float64(7)}, `{"Msg":"great","userID":7}`},
+		{"sendReview", map[string]interface{}{"Msg": "great", "userID": float64(7)}, `{"Msg":"great","userID":7}`},
+		{"deleteReview", map[string]interface{}{"reviewID": float64(12)}, `{"reviewID":12}`},
+		{"SendClientData", map[string]interface{}{"Msg": "hi"}, `{"Msg":"hi"}`},
+	}
+	for _, tc := range cases {
+		got, err := formatResponse(tc.method, tc.body)
+		if err != nil || got != tc.want {
+			t.Fatalf("%s %v: expected %s, got %s, %v", tc.method, tc.body, tc.want, got, err)
+		}
+	}
+	if _, err := formatResponse("editReview", map[string]interface{}{"data": "x", "reviewID": float64(1)}); err != errInvalidInputs {
+		t.Fatalf("Expected invalid inputs without postID, got %v", err)
+	}
+}
+
+func TestInvalidBodiesAreRefusedBeforeTheBackendIsResolved(t *testing.T) {
+	withDynamicState(t)
+	if err := gatewayPolicies.replace(map[string]servicePolicy{"ReviewService": {RateLimit: rateLimitConfig{QPS: 1, Burst: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := makeThriftCall(filepath.Join(thriftDirectory, "ReviewService.thrift"),
+		map[string]interface{}{"Msg": "hi", "userID": "seven"}, "ReviewService", "sendReview", context.Background())
+	if !errors.Is(err, errInvalidInputs) {
+		t.Fatalf("Expected invalid inputs, got %v", err)
+	}
+	if !gatewayPolicies.admit("ReviewService", time.Now()) {
+		t.Fatal("An invalid body should not take a rate limit token")
+	}
+}
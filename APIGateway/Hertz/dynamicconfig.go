@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+/**
+ * routeRule exposes some or all methods of a service on POST /{service}/{method}.
+ */
+type routeRule struct {
+	Service string `json:"service"`
+	// Methods limits the rule to these methods; empty matches every method of the service.
+	Methods []string `json:"methods"`
+	// Disabled switches the route off without removing it; calls get 503.
+	Disabled bool `json:"disabled"`
+	// Public lets calls through without a token when auth is on.
+	Public bool `json:"public"`
+}
+
+func (r routeRule) matches(service string, method string) bool {
+	if r.Service != service {
+		return false
+	}
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * routePolicy decides which calls every public entrypoint accepts: the generic POST route,
+ * JSON-RPC, GraphQL, composite routes, streams and binary passthrough. With no rules every
+ * service and method is routed, as before routes were configurable.
+ */
+type routePolicy struct {
+	Routes []routeRule
+	Auth   authConfig
+}
+
+// Reasons the route policy refuses a call; each entrypoint maps them to its own errors.
+var (
+	errNoRoute         = errors.New("no route")
+	errRouteDisabled   = errors.New("route is disabled")
+	errUnauthenticated = errors.New("unauthenticated")
+)
+
+/**
+ * Checks a call to service.method against the policy.
+ *
+ * @param token The caller's bearer token, empty when it sent none.
+ *
+ * @return nil when the call is allowed, or an error wrapping errNoRoute, errRouteDisabled or
+ *         errUnauthenticated.
+ */
+func (p routePolicy) check(service string, method string, token string) error {
+	var rule *routeRule
+	for i := range p.Routes {
+		if p.Routes[i].matches(service, method) {
+			rule = &p.Routes[i]
+			break
+		}
+	}
+	if rule == nil && len(p.Routes) > 0 {
+		return fmt.Errorf("%w for %s.%s", errNoRoute, service, method)
+	}
+	if rule != nil && rule.Disabled {
+		return fmt.Errorf("%w: %s.%s", errRouteDisabled, service, method)
+	}
+	if rule == nil || !rule.Public {
+		if _, err := authenticateToken(p.Auth, token); err != nil {
+			return fmt.Errorf("%w: %v", errUnauthenticated, err)
+		}
+	}
+	return nil
+}
+
+// routePolicyStatus is the HTTP status for an error from routePolicy.check.
+func routePolicyStatus(err error) int {
+	switch {
+	case errors.Is(err, errNoRoute):
+		return consts.StatusNotFound
+	case errors.Is(err, errRouteDisabled):
+		return consts.StatusServiceUnavailable
+	}
+	return consts.StatusUnauthorized
+}
+
+/**
+ * routeTable holds the live route policy, swapped whole when a new config arrives.
+ */
+type routeTable struct {
+	current atomic.Value
+}
+
+func (t *routeTable) load() routePolicy {
+	p, _ := t.current.Load().(routePolicy)
+	return p
+}
+
+func (t *routeTable) store(p routePolicy) {
+	t.current.Store(p)
+}
+
+var gatewayRoutes = &routeTable{}
+
+// ctxTokenKey holds the bearer token of the request a call is made for.
+const ctxTokenKey ctxKey = ctxShadowKey + 1
+
+/**
+ * Returns middleware that keeps the request's bearer token in the context, for the route
+ * policy to check on each call made while serving it.
+ */
+func routeTokenMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Next(context.WithValue(ctx, ctxTokenKey, requestToken(c)))
+	}
+}
+
+/**
+ * Wraps a caller so every call is checked against the live route policy first, with the token
+ * routeTokenMiddleware kept in the context. Entrypoints that fan one request out to several
+ * calls, such as JSON-RPC batches, GraphQL fields and composite routes, are checked per call.
+ *
+ * @param call The caller to guard.
+ */
+func (t *routeTable) guard(call genericCaller) genericCaller {
+	return func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		token, _ := ctx.Value(ctxTokenKey).(string)
+		if err := t.load().check(service, method, token); err != nil {
+			return nil, err
+		}
+		return call(ctx, service, method, body)
+	}
+}
+
+/**
+ * Returns middleware enforcing the live route policy on POST /:serviceName/:methodName.
+ * Unrouted calls get 404, disabled routes 503, and calls without a valid token 401.
+ */
+func routePolicyMiddleware(table *routeTable) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if err := table.load().check(c.Param("serviceName"), c.Param("methodName"), requestToken(c)); err != nil {
+			c.AbortWithStatusJSON(routePolicyStatus(err), utils.H{"error": err.Error()})
+			return
+		}
+		c.Next(ctx)
+	}
+}
+
+/**
+ * dynamicConfigSource points the gateway at a Nacos config dataId holding a dynamicDocument.
+ */
+type dynamicConfigSource struct {
+	Enabled bool   `json:"enabled"`
+	DataID  string `json:"dataId"`
+	Group   string `json:"group"`
+}
+
+/**
+ * dynamicDocument is the part of the gateway config that can change while it runs. Only the
+ * keys a version contains are applied; a missing key is nil and keeps the gateway's own value.
+ */
+type dynamicDocument struct {
+	Routes   *[]routeRule              `json:"routes"`
+	Policies *map[string]servicePolicy `json:"policies"`
+	Auth     *authConfig               `json:"auth"`
+}
+
+var dynamicConfigUpdates = newCounterVec("gateway_dynamic_config_updates_total",
+	"Dynamic config versions seen, by outcome.", "outcome")
+
+/**
+ * Parses a dynamic config document and applies it. Unknown fields are rejected so a typo in
+ * Nacos does not silently drop a setting.
+ *
+ * Routes and auth start from the config file and are overridden by the keys the document
+ * contains, so a document without auth never switches authentication off. Policies are only
+ * replaced when the document has a policies key; otherwise the live policies, including any
+ * set through the admin API, are kept.
+ *
+ * @return An error, with nothing applied, if the document or any policy in it is invalid.
+ */
+func applyDynamicConfig(content []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	var doc dynamicDocument
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("error parsing dynamic config: %w", err)
+	}
+	routes := routePolicy{Routes: gatewayCfg.Routes, Auth: gatewayCfg.Auth}
+	if doc.Routes != nil {
+		routes.Routes = *doc.Routes
+	}
+	if doc.Auth != nil {
+		routes.Auth = *doc.Auth
+	}
+	for _, r := range routes.Routes {
+		if r.Service == "" {
+			return fmt.Errorf("every route needs a service")
+		}
+	}
+	if doc.Policies != nil {
+		if err := gatewayPolicies.replace(*doc.Policies); err != nil {
+			return err
+		}
+	}
+	gatewayRoutes.store(routes)
+	return nil
+}
+
+// configClient is the part of the Nacos config client the watcher uses.
+type configClient interface {
+	GetConfig(param vo.ConfigParam) (string, error)
+	ListenConfig(param vo.ConfigParam) error
+}
+
+/**
+ * dynamicConfigWatcher keeps the gateway in step with a Nacos dataId. Every version that
+ * applies cleanly is saved as the last-known-good copy, which is used at startup when Nacos
+ * cannot be reached. A version that fails to apply is logged and ignored.
+ */
+type dynamicConfigWatcher struct {
+	client    configClient
+	source    dynamicConfigSource
+	cachePath string
+	apply     func(content []byte) error
+}
+
+/**
+ * Creates a watcher for the dataId in source, using the Nacos config client.
+ */
+func newDynamicConfigWatcher(source dynamicConfigSource) (*dynamicConfigWatcher, error) {
+	if source.DataID == "" {
+		return nil, fmt.Errorf("dynamic config needs a dataId")
+	}
+	if source.Group == "" {
+		source.Group = "DEFAULT_GROUP"
+	}
+	param, err := nacosClientParam(gatewayCfg.Nacos.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := clients.NewConfigClient(param)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Nacos config client: %w", err)
+	}
+	return &dynamicConfigWatcher{
+		client:    cli,
+		source:    source,
+		cachePath: filepath.Join(nacosCacheDir, "gateway", gatewayCfg.Nacos.Namespace, source.Group+"@"+source.DataID),
+		apply:     applyDynamicConfig,
+	}, nil
+}
+
+/**
+ * Applies one version of the config and saves it as last-known-good.
+ *
+ * @return An error if it was not applied. An empty version, which is what Nacos reports for a
+ *         missing or deleted dataId, is not applied and not an error.
+ */
+func (w *dynamicConfigWatcher) update(content string) error {
+	if strings.TrimSpace(content) == "" {
+		fmt.Println("Dynamic config", w.source.DataID, "is empty; keeping the current config")
+		return nil
+	}
+	if err := w.apply([]byte(content)); err != nil {
+		dynamicConfigUpdates.Inc("rejected")
+		fmt.Println("Rejected dynamic config:", err)
+		return err
+	}
+	dynamicConfigUpdates.Inc("applied")
+	fmt.Println("Applied dynamic config", w.source.DataID)
+	if err := w.save([]byte(content)); err != nil {
+		fmt.Println("Error saving last-known-good config:", err)
+	}
+	return nil
+}
+
+// save writes the last-known-good copy through a temporary file so a crash never leaves half of it.
+func (w *dynamicConfigWatcher) save(content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(w.cachePath), 0o755); err != nil {
+		return err
+	}
+	tmp := w.cachePath + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.cachePath)
+}
+
+/**
+ * Loads the current config, falling back to the last-known-good copy, then listens for changes.
+ *
+ * When neither Nacos nor the copy has a usable config, the gateway keeps the routes and
+ * policies from its config file.
+ *
+ * @return An error if the listener cannot be registered.
+ */
+func (w *dynamicConfigWatcher) start() error {
+	param := vo.ConfigParam{DataId: w.source.DataID, Group: w.source.Group}
+
+	content, err := w.client.GetConfig(param)
+	if err != nil {
+		fmt.Println("Error fetching dynamic config from Nacos:", err)
+	}
+	if err != nil || strings.TrimSpace(content) == "" || w.update(content) != nil {
+		w.loadLastKnownGood()
+	}
+
+	param.OnChange = func(namespace string, group string, dataId string, data string) {
+		w.update(data)
+	}
+	return w.client.ListenConfig(param)
+}
+
+func (w *dynamicConfigWatcher) loadLastKnownGood() {
+	data, err := os.ReadFile(w.cachePath)
+	if err != nil {
+		fmt.Println("No last-known-good dynamic config; using the config file")
+		return
+	}
+	if err := w.apply(data); err != nil {
+		fmt.Println("Error applying last-known-good dynamic config:", err)
+		return
+	}
+	dynamicConfigUpdates.Inc("cached")
+	fmt.Println("Applied last-known-good dynamic config from", w.cachePath)
+}
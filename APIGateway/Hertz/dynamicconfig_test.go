@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+type fakeConfigClient struct {
+	content  string
+	err      error
+	onChange func(namespace, group, dataId, data string)
+}
+
+func (f *fakeConfigClient) GetConfig(param vo.ConfigParam) (string, error) {
+	return f.content, f.err
+}
+
+func (f *fakeConfigClient) ListenConfig(param vo.ConfigParam) error {
+	f.onChange = param.OnChange
+	return nil
+}
+
+// withDynamicState restores the global policies and routes a test changes.
+func withDynamicState(t *testing.T) {
+	policies, routes := gatewayPolicies.all(), gatewayRoutes.load()
+	t.Cleanup(func() {
+		gatewayPolicies.replace(policies)
+		gatewayRoutes.store(routes)
+	})
+}
+
+func newTestWatcher(t *testing.T, client *fakeConfigClient) *dynamicConfigWatcher {
+	return &dynamicConfigWatcher{
+		client:    client,
+		source:    dynamicConfigSource{DataID: "api-gateway.json", Group: "DEFAULT_GROUP"},
+		cachePath: filepath.Join(t.TempDir(), "DEFAULT_GROUP@api-gateway.json"),
+		apply:     applyDynamicConfig,
+	}
+}
+
+func TestDynamicConfigAppliesUpdatesAndSavesLastKnownGood(t *testing.T) {
+	withDynamicState(t)
+	client := &fakeConfigClient{content: `{"policies":{"ReviewService":{"timeout":"1s"}}}`}
+	w := newTestWatcher(t, client)
+	if err := w.start(); err != nil {
+		t.Fatal(err)
+	}
+	if p := gatewayPolicies.get("ReviewService"); time.Duration(p.Timeout) != time.Second {
+		t.Fatalf("Initial config not applied: %+v", p)
+	}
+
+	client.onChange("public", "DEFAULT_GROUP", "api-gateway.json", `{"policies":{"ReviewService":{"timeout":"2s"}},"routes":[{"service":"ReviewService"}]}`)
+	if p := gatewayPolicies.get("ReviewService"); time.Duration(p.Timeout) != 2*time.Second {
+		t.Fatalf("Update not applied: %+v", p)
+	}
+	if len(gatewayRoutes.load().Routes) != 1 {
+		t.Fatalf("Routes not applied: %+v", gatewayRoutes.load())
+	}
+
+	// A broken version, or one with a typo, is ignored and does not replace the saved copy.
+	client.onChange("public", "DEFAULT_GROUP", "api-gateway.json", `{"policies":{"ReviewService":{"timeout":"-1s"}}}`)
+	client.onChange("public", "DEFAULT_GROUP", "api-gateway.json", `{"polices":{}}`)
+	if p := gatewayPolicies.get("ReviewService"); time.Duration(p.Timeout) != 2*time.Second {
+		t.Fatalf("A rejected update changed the policy: %+v", p)
+	}
+	saved, err := os.ReadFile(w.cachePath)
+	if err != nil || !strings.Contains(string(saved), `"2s"`) {
+		t.Fatalf("Expected the last good version on disk, got %s, %v", saved, err)
+	}
+}
+
+func TestDynamicConfigFallsBackToLastKnownGood(t *testing.T) {
+	withDynamicState(t)
+	w := newTestWatcher(t, &fakeConfigClient{err: errors.New("connection refused")})
+	if err := os.WriteFile(w.cachePath, []byte(`{"policies":{"TravelService":{"timeout":"4s"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.start(); err != nil {
+		t.Fatal(err)
+	}
+	if p := gatewayPolicies.get("TravelService"); time.Duration(p.Timeout) != 4*time.Second {
+		t.Fatalf("Last-known-good config not applied: %+v", p)
+	}
+}
+
+func TestDynamicConfigKeepsWhatTheDocumentLeavesOut(t *testing.T) {
+	withDynamicState(t)
+	cfg := gatewayCfg
+	t.Cleanup(func() { gatewayCfg = cfg })
+	gatewayCfg.Auth = authConfig{Secret: "s3cret"}
+	gatewayCfg.Routes = nil
+	if err := gatewayPolicies.replace(map[string]servicePolicy{"ReviewService": {Timeout: duration(5 * time.Second)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyDynamicConfig([]byte(`{"routes":[{"service":"ReviewService"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if p := gatewayPolicies.get("ReviewService"); time.Duration(p.Timeout) != 5*time.Second {
+		t.Fatalf("A document without policies should keep the live ones, got %+v", p)
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/:serviceName/:methodName", routePolicyMiddleware(gatewayRoutes), func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, "ok")
+	})
+	resp := ut.PerformRequest(engine, consts.MethodPost, "/ReviewService/sendReview", &ut.Body{Body: strings.NewReader("{}"), Len: 2})
+	if resp.Code != consts.StatusUnauthorized {
+		t.Fatalf("A document without auth should keep the configured secret and give 401, got %d", resp.Code)
+	}
+
+	if err := applyDynamicConfig([]byte(`{"auth":{}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if gatewayRoutes.load().Routes != nil {
+		t.Fatalf("A document without routes should use the config file's, got %+v", gatewayRoutes.load().Routes)
+	}
+	resp = ut.PerformRequest(engine, consts.MethodPost, "/ReviewService/sendReview", &ut.Body{Body: strings.NewReader("{}"), Len: 2})
+	if resp.Code != consts.StatusOK {
+		t.Fatalf("An explicit empty auth should switch it off, got %d", resp.Code)
+	}
+}
+
+func TestRoutePolicyMiddleware(t *testing.T) {
+	table := &routeTable{}
+	table.store(routePolicy{
+		Routes: []routeRule{
+			{Service: "TravelService", Methods: []string{"RetrieveClientData"}, Public: true},
+			{Service: "ReviewService", Methods: []string{"deleteReview"}, Disabled: true},
+			{Service: "ReviewService"},
+		},
+		Auth: authConfig{Secret: "s3cret"},
+	})
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/:serviceName/:methodName", routePolicyMiddleware(table), func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, "ok")
+	})
+	post := func(path string, headers ...ut.Header) int {
+		return ut.PerformRequest(engine, consts.MethodPost, path, &ut.Body{Body: strings.NewReader("{}"), Len: 2}, headers...).Code
+	}
+	token := ut.Header{Key: "Authorization", Value: "Bearer " + signHS256(t, `{"sub":"app"}`, "s3cret")}
+
+	cases := []struct {
+		path    string
+		headers []ut.Header
+		want    int
+	}{
+		{"/TravelService/RetrieveClientData", nil, consts.StatusOK},
+		{"/TravelService/GetAllTravelDestinations", []ut.Header{token}, consts.StatusNotFound},
+		{"/ReviewService/deleteReview", []ut.Header{token}, consts.StatusServiceUnavailable},
+		{"/ReviewService/sendReview", nil, consts.StatusUnauthorized},
+		{"/ReviewService/sendReview", []ut.Header{token}, consts.StatusOK},
+	}
+	for _, tc := range cases {
+		if got := post(tc.path, tc.headers...); got != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.path, tc.want, got)
+		}
+	}
+
+	// Without rules every call is routed, as before.
+	table.store(routePolicy{})
+	if got := post("/TravelService/GetAllTravelDestinations"); got != consts.StatusOK {
+		t.Fatalf("Expected every route open without rules, got %d", got)
+	}
+}
+
+func TestRoutePolicyGuardsEveryEntrypoint(t *testing.T) {
+	table := &routeTable{}
+	table.store(routePolicy{
+		Routes: []routeRule{
+			{Service: "TravelService", Methods: []string{"RetrieveClientData"}, Public: true},
+			{Service: "ReviewService", Methods: []string{"deleteReview"}, Disabled: true},
+			{Service: "ReviewService"},
+		},
+		Auth: authConfig{Secret: "s3cret"},
+	})
+	var calls int32
+	rpc := &jsonRPCServer{
+		methodExists: idlDeclares,
+		cfg:          jsonRPCConfig{MaxBatch: 10, Workers: 2},
+		call: table.guard(func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return map[string]interface{}{}, nil
+		}),
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(routeTokenMiddleware())
+	engine.POST("/jsonrpc", rpc.ServeHTTP)
+	cfg := streamConfig{Subscriptions: []subscription{{Name: "deleted", Service: "ReviewService", Method: "deleteReview"}}}
+	engine.GET("/stream/:subscription", streamHandler(cfg, map[string]subscription{"deleted": cfg.Subscriptions[0]}, table, newStreamHub(nil, 0)))
+
+	token := signHS256(t, `{"sub":"app"}`, "s3cret")
+	codeOf := func(body string, headers ...ut.Header) interface{} {
+		resp := ut.PerformRequest(engine, consts.MethodPost, "/jsonrpc", &ut.Body{Body: strings.NewReader(body), Len: len(body)}, headers...)
+		var out map[string]interface{}
+		if err := json.Unmarshal(resp.Body.Bytes(), &out); err != nil {
+			t.Fatalf("Response is not JSON: %s", resp.Body.String())
+		}
+		if rpcErr, ok := out["error"].(map[string]interface{}); ok {
+			return rpcErr["code"]
+		}
+		return nil
+	}
+
+	cases := []struct {
+		method  string
+		headers []ut.Header
+		want    interface{}
+	}{
+		{"ReviewService.sendReview", nil, float64(jsonRPCForbidden)},
+		{"ReviewService.sendReview", []ut.Header{{Key: "Authorization", Value: "Bearer " + token}}, nil},
+		{"TravelService.RetrieveClientData", nil, nil},
+		{"TravelService.GetAllTravelDestinations", []ut.Header{{Key: "Authorization", Value: "Bearer " + token}}, float64(jsonRPCMethodNotFound)},
+		{"ReviewService.deleteReview", []ut.Header{{Key: "Authorization", Value: "Bearer " + token}}, float64(jsonRPCServiceUnavailable)},
+	}
+	for _, tc := range cases {
+		body := `{"jsonrpc":"2.0","method":"` + tc.method + `","params":{},"id":1}`
+		if got := codeOf(body, tc.headers...); got != tc.want {
+			t.Fatalf("%s: expected error code %v, got %v", tc.method, tc.want, got)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Only the 2 allowed calls should reach the backend, got %d", calls)
+	}
+
+	// Each batch member is checked on its own.
+	batch := `[{"jsonrpc":"2.0","method":"TravelService.RetrieveClientData","params":{},"id":1},
+		{"jsonrpc":"2.0","method":"ReviewService.sendReview","params":{},"id":2}]`
+	resp := ut.PerformRequest(engine, consts.MethodPost, "/jsonrpc", &ut.Body{Body: strings.NewReader(batch), Len: len(batch)})
+	var out []map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &out); err != nil || len(out) != 2 {
+		t.Fatalf("Unexpected batch response %s", resp.Body.String())
+	}
+	if _, ok := out[0]["result"]; !ok {
+		t.Fatalf("The public member should succeed, got %v", out[0])
+	}
+	if _, ok := out[1]["error"]; !ok {
+		t.Fatalf("The member without a token should be refused, got %v", out[1])
+	}
+
+	// Streams are checked for their subscription's method when they open.
+	streamResp := ut.PerformRequest(engine, consts.MethodGet, "/stream/deleted?access_token="+token, nil)
+	if streamResp.Code != consts.StatusServiceUnavailable {
+		t.Fatalf("A stream of a disabled route should give 503, got %d", streamResp.Code)
+	}
+
+	// Composite routes make their calls through the same guarded caller.
+	results, errs := runComposite(context.WithValue(context.Background(), ctxTokenKey, ""), compositeRoute{Calls: []compositeCall{
+		{Name: "client", Service: "TravelService", Method: "RetrieveClientData"},
+		{Name: "review", Service: "ReviewService", Method: "sendReview"},
+	}}, map[string]interface{}{}, rpc.call)
+	if _, ok := results["client"]; !ok || errs["review"] == "" {
+		t.Fatalf("Expected the public call to run and the other to be refused, got %v and %v", results, errs)
+	}
+}
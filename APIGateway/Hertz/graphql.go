@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"hertz_demo/idl"
+)
+
+/**
+ * graphQLConfig switches the optional /graphql endpoint on.
+ */
+type graphQLConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Methods whose names start with one of these prefixes become queries; all others are mutations.
+var readMethodPrefixes = []string{"get", "retrieve", "list", "find", "query", "search", "fetch"}
+
+func isReadMethod(name string) bool {
+	lower := strings.ToLower(name)
+	for _, prefix := range readMethodPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// graphQLName turns a qualified Thrift struct name such as base.BaseResp into a valid GraphQL name.
+func graphQLName(thriftName string) string {
+	return strings.ReplaceAll(thriftName, ".", "_")
+}
+
+// int64Scalar carries Thrift i64 values, which do not fit GraphQL's 32-bit Int.
+var int64Scalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Int64",
+	Description: "A 64-bit signed integer (Thrift i64).",
+	Serialize:   coerceInt64,
+	ParseValue:  coerceInt64,
+	ParseLiteral: func(v ast.Value) interface{} {
+		if iv, ok := v.(*ast.IntValue); ok {
+			n, err := strconv.ParseInt(iv.Value, 10, 64)
+			if err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+})
+
+func coerceInt64(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case string:
+		if parsed, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return parsed
+		}
+	}
+	return nil
+}
+
+// jsonScalar carries Thrift maps, which have no GraphQL equivalent, as plain JSON.
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "An arbitrary JSON value (Thrift map).",
+	Serialize:    func(v interface{}) interface{} { return v },
+	ParseValue:   func(v interface{}) interface{} { return v },
+	ParseLiteral: parseJSONLiteral,
+})
+
+func parseJSONLiteral(v ast.Value) interface{} {
+	switch val := v.(type) {
+	case *ast.StringValue:
+		return val.Value
+	case *ast.BooleanValue:
+		return val.Value
+	case *ast.IntValue:
+		n, _ := strconv.ParseInt(val.Value, 10, 64)
+		return n
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(val.Value, 64)
+		return f
+	case *ast.ListValue:
+		out := []interface{}{}
+		for _, item := range val.Values {
+			out = append(out, parseJSONLiteral(item))
+		}
+		return out
+	case *ast.ObjectValue:
+		out := map[string]interface{}{}
+		for _, f := range val.Fields {
+			out[f.Name.Value] = parseJSONLiteral(f.Value)
+		}
+		return out
+	}
+	return nil
+}
+
+/**
+ * graphQLTypes builds GraphQL output and input types from Thrift types, one per struct.
+ */
+type graphQLTypes struct {
+	objects map[string]*graphql.Object
+	inputs  map[string]*graphql.InputObject
+}
+
+func (g *graphQLTypes) scalar(t *idl.Type) graphql.Type {
+	switch t.Category {
+	case idl.Bool:
+		return graphql.Boolean
+	case idl.Byte, idl.I16, idl.I32, idl.Enum:
+		return graphql.Int
+	case idl.I64:
+		return int64Scalar
+	case idl.Double:
+		return graphql.Float
+	case idl.String, idl.Binary:
+		return graphql.String
+	case idl.Map:
+		return jsonScalar
+	}
+	return nil
+}
+
+func (g *graphQLTypes) output(t *idl.Type) graphql.Output {
+	switch t.Category {
+	case idl.List, idl.Set:
+		return graphql.NewList(g.output(t.Value))
+	case idl.Struct:
+		return g.object(t.Struct)
+	}
+	return g.scalar(t)
+}
+
+func (g *graphQLTypes) object(st *idl.StructDef) *graphql.Object {
+	name := graphQLName(st.Name)
+	if obj, ok := g.objects[name]; ok {
+		return obj
+	}
+	obj := graphql.NewObject(graphql.ObjectConfig{
+		Name: name,
+		// A thunk lets structs reference each other before all of them are built.
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{}
+			for _, f := range st.Fields {
+				fields[f.Name] = &graphql.Field{Type: g.output(f.Type)}
+			}
+			return fields
+		}),
+	})
+	g.objects[name] = obj
+	return obj
+}
+
+func (g *graphQLTypes) input(t *idl.Type) graphql.Input {
+	switch t.Category {
+	case idl.List, idl.Set:
+		return graphql.NewList(g.input(t.Value))
+	case idl.Struct:
+		return g.inputObject(t.Struct)
+	}
+	return g.scalar(t)
+}
+
+func (g *graphQLTypes) inputObject(st *idl.StructDef) *graphql.InputObject {
+	name := graphQLName(st.Name) + "Input"
+	if in, ok := g.inputs[name]; ok {
+		return in
+	}
+	in := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: name,
+		Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+			fields := graphql.InputObjectConfigFieldMap{}
+			for _, f := range st.Fields {
+				var fieldType graphql.Input = g.input(f.Type)
+				if f.Requiredness == idl.Required {
+					fieldType = graphql.NewNonNull(fieldType)
+				}
+				fields[f.Name] = &graphql.InputObjectFieldConfig{Type: fieldType}
+			}
+			return fields
+		}),
+	})
+	g.inputs[name] = in
+	return in
+}
+
+/**
+ * Generates a GraphQL schema from the parsed IDLs.
+ *
+ * Read-style methods become query fields and all other methods become mutation fields. Each
+ * field takes the method's request struct as an input argument and resolves through call.
+ * A method name used by two services is prefixed with its service name.
+ *
+ * @param docs The parsed IDLs.
+ * @param call Performs the generic call behind a field; callGeneric in production.
+ *
+ * @return The schema, or an error if graphql-go rejects it.
+ */
+func buildGraphQLSchema(docs []*idl.Document, call genericCaller) (graphql.Schema, error) {
+	types := &graphQLTypes{objects: map[string]*graphql.Object{}, inputs: map[string]*graphql.InputObject{}}
+	queries := graphql.Fields{}
+	mutations := graphql.Fields{}
+	serviceNames := []interface{}{}
+
+	seen := map[string]int{}
+	for _, doc := range docs {
+		for _, svc := range doc.Services {
+			for _, m := range svc.Methods {
+				seen[m.Name]++
+			}
+		}
+	}
+
+	for _, doc := range docs {
+		for _, svc := range doc.Services {
+			serviceNames = append(serviceNames, svc.Name)
+			for _, m := range svc.Methods {
+				svcName, method := svc.Name, m
+				fieldName := m.Name
+				if seen[m.Name] > 1 {
+					fieldName = svc.Name + "_" + m.Name
+				}
+
+				args := graphql.FieldConfigArgument{}
+				argName := ""
+				if req := m.Request(); req != nil {
+					argName = m.Args[0].Name
+					args[argName] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(types.input(req))}
+				}
+
+				var out graphql.Output = jsonScalar
+				if m.Response != nil {
+					out = types.output(m.Response)
+				}
+
+				field := &graphql.Field{
+					Type:        out,
+					Args:        args,
+					Description: fmt.Sprintf("Calls %s.%s", svcName, method.Name),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						body := map[string]interface{}{}
+						if argName != "" {
+							if in, ok := p.Args[argName].(map[string]interface{}); ok {
+								body = in
+							}
+						}
+						return call(p.Context, svcName, method.Name, body)
+					},
+				}
+				if isReadMethod(m.Name) {
+					queries[fieldName] = field
+				} else {
+					mutations[fieldName] = field
+				}
+			}
+		}
+	}
+
+	// Query needs at least one field even when every method is a mutation.
+	queries["_services"] = &graphql.Field{
+		Type:        graphql.NewList(graphql.String),
+		Description: "The services exposed through this endpoint.",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return serviceNames, nil
+		},
+	}
+
+	cfg := graphql.SchemaConfig{Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: queries})}
+	if len(mutations) > 0 {
+		cfg.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutations})
+	}
+	return graphql.NewSchema(cfg)
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+/**
+ * Returns the type of the operation a request would run: "query", "mutation" or
+ * "subscription", or "" when the document does not parse or has no such operation, in which
+ * case graphql.Do reports the error.
+ */
+func operationType(query string, operationName string) string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+	var ops []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			ops = append(ops, op)
+		}
+	}
+	for _, op := range ops {
+		if operationName == "" && len(ops) == 1 {
+			return op.Operation
+		}
+		if op.Name != nil && op.Name.Value == operationName {
+			return op.Operation
+		}
+	}
+	return ""
+}
+
+/**
+ * Returns a handler serving GraphQL over HTTP: POST with a JSON body, or GET with ?query=.
+ * GET only runs queries, so a link or a prefetch cannot trigger a mutation; other operations
+ * get 405 and must be sent with POST.
+ */
+func graphQLHandler(schema graphql.Schema) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		var req graphQLRequest
+		if string(c.Method()) == consts.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if vars := c.Query("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					c.JSON(consts.StatusBadRequest, utils.H{"errors": []utils.H{{"message": "invalid variables"}}})
+					return
+				}
+			}
+			if op := operationType(req.Query, req.OperationName); op != "" && op != ast.OperationTypeQuery {
+				c.Header("Allow", consts.MethodPost)
+				c.JSON(consts.StatusMethodNotAllowed, utils.H{"errors": []utils.H{{"message": op + " operations must be sent with POST"}}})
+				return
+			}
+		} else if err := json.Unmarshal(c.GetRawData(), &req); err != nil {
+			c.JSON(consts.StatusBadRequest, utils.H{"errors": []utils.H{{"message": "bad graphql request"}}})
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        ctx,
+		})
+		c.JSON(consts.StatusOK, result)
+	}
+}
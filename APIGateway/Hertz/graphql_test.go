@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/graphql-go/graphql"
+
+	"hertz_demo/idl"
+)
+
+func newTestGraphQLSchema(t *testing.T, call genericCaller) graphql.Schema {
+	docs, err := idl.ParseDir(thriftDirectory)
+	if err != nil {
+		t.Fatalf("Should parse thriftFiles: %v", err)
+	}
+	schema, err := buildGraphQLSchema(docs, call)
+	if err != nil {
+		t.Fatalf("Should build the GraphQL schema: %v", err)
+	}
+	return schema
+}
+
+func TestGraphQLQueryAppliesFieldSelection(t *testing.T) {
+	var gotService, gotMethod string
+	var gotBody map[string]interface{}
+	schema := newTestGraphQLSchema(t, func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		gotService, gotMethod, gotBody = service, method, body
+		return map[string]interface{}{
+			"Name":             "Ryan",
+			"userID":           1.0,
+			"VisitedCountries": []interface{}{"Taiwan", "Japan"},
+			"BaseResp":         map[string]interface{}{"StatusCode": 200.0, "StatusMessage": "Success"},
+		}, nil
+	})
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ RetrieveClientData(req: {userID: 1}) { Name BaseResp { StatusCode } } }`,
+		Context:       context.Background(),
+	})
+	if result.HasErrors() {
+		t.Fatalf("Query failed: %v", result.Errors)
+	}
+	if gotService != "TravelService" || gotMethod != "RetrieveClientData" || gotBody["userID"] != 1 {
+		t.Fatalf("Unexpected call %s.%s %v", gotService, gotMethod, gotBody)
+	}
+
+	data := result.Data.(map[string]interface{})["RetrieveClientData"].(map[string]interface{})
+	if data["Name"] != "Ryan" {
+		t.Fatalf("Name should be selected, got %v", data)
+	}
+	if _, ok := data["VisitedCountries"]; ok {
+		t.Fatalf("Unselected fields should be dropped, got %v", data)
+	}
+	if data["BaseResp"].(map[string]interface{})["StatusCode"] != 200 {
+		t.Fatalf("Nested selection failed, got %v", data)
+	}
+}
+
+func TestGraphQLReviewMethodsAreMutations(t *testing.T) {
+	schema := newTestGraphQLSchema(t, func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"action": method + " done"}, nil
+	})
+
+	for _, name := range []string{"sendReview", "editReview", "deleteReview"} {
+		if _, ok := schema.MutationType().Fields()[name]; !ok {
+			t.Fatalf("%s should be a mutation", name)
+		}
+		if _, ok := schema.QueryType().Fields()[name]; ok {
+			t.Fatalf("%s should not be a query", name)
+		}
+	}
+	if _, ok := schema.QueryType().Fields()["GetAllTravelDestinations"]; !ok {
+		t.Fatalf("GetAllTravelDestinations should be a query")
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `mutation { deleteReview(req: {reviewID: 9000000000}) { action } }`,
+		Context:       context.Background(),
+	})
+	if result.HasErrors() {
+		t.Fatalf("Mutation failed: %v", result.Errors)
+	}
+}
+
+func TestGraphQLGetOnlyRunsQueries(t *testing.T) {
+	var calls []string
+	schema := newTestGraphQLSchema(t, func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		calls = append(calls, method)
+		return map[string]interface{}{"action": method + " done"}, nil
+	})
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/graphql", graphQLHandler(schema))
+	engine.POST("/graphql", graphQLHandler(schema))
+	get := func(query string, operationName string) *ut.ResponseRecorder {
+		path := "/graphql?query=" + url.QueryEscape(query)
+		if operationName != "" {
+			path += "&operationName=" + operationName
+		}
+		return ut.PerformRequest(engine, consts.MethodGet, path, nil)
+	}
+
+	mutation := `mutation { deleteReview(req: {reviewID: 1}) { action } }`
+	if resp := get(mutation, ""); resp.Code != consts.StatusMethodNotAllowed || resp.Header().Get("Allow") != consts.MethodPost {
+		t.Fatalf("A mutation over GET should give 405, got %d", resp.Code)
+	}
+	named := `query Q { GetAllTravelDestinations(req: {userID: 1}) { BaseResp { StatusCode } } } mutation M { deleteReview(req: {reviewID: 1}) { action } }`
+	if resp := get(named, "M"); resp.Code != consts.StatusMethodNotAllowed {
+		t.Fatalf("A named mutation over GET should give 405, got %d", resp.Code)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("No mutation should reach the backend over GET, got %v", calls)
+	}
+
+	if resp := get(named, "Q"); resp.Code != consts.StatusOK || len(calls) != 1 {
+		t.Fatalf("A query over GET should run, got %d with calls %v", resp.Code, calls)
+	}
+	body := `{"query":"mutation { deleteReview(req: {reviewID: 1}) { action } }"}`
+	resp := ut.PerformRequest(engine, consts.MethodPost, "/graphql", &ut.Body{Body: strings.NewReader(body), Len: len(body)})
+	if resp.Code != consts.StatusOK || len(calls) != 2 || calls[1] != "deleteReview" {
+		t.Fatalf("A mutation over POST should run, got %d with calls %v", resp.Code, calls)
+	}
+}
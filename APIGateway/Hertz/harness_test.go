@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+
+	"hertz_demo/nacostest"
+)
+
+// testRegistry is the fake Nacos the gateway tests discover backends in.
+var testRegistry *nacostest.Server
+
+/**
+ * Runs the tests against a fake Nacos with TravelService and ReviewService backends registered,
+ * as RPCBackend registers them. Set NACOS_ADDR, e.g. http://127.0.0.1:8848, to run against a
+ * live Nacos and backends instead.
+ */
+func TestMain(m *testing.M) {
+	if addr := os.Getenv("NACOS_ADDR"); addr != "" {
+		serviceRegistryIP = addr
+		os.Exit(m.Run())
+	}
+
+	testRegistry = nacostest.NewServer()
+	serviceRegistryIP = testRegistry.URL
+	backends, err := startTestBackends(testRegistry)
+	if err != nil {
+		fmt.Println("Error starting test backends:", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	for _, b := range backends {
+		b.Stop()
+	}
+	testRegistry.Close()
+	os.Exit(code)
+}
+
+// startTestBackends starts two instances of each service and registers them in the default namespace and group.
+func startTestBackends(registry *nacostest.Server) ([]*nacostest.Backend, error) {
+	handlers := map[string]testBackend{"TravelService": travelBackend, "ReviewService": reviewBackend}
+	backends := []*nacostest.Backend{}
+	for service, handler := range handlers {
+		for i := 0; i < 2; i++ {
+			b, err := nacostest.StartBackend(idlPathFor(service), handler)
+			if err != nil {
+				return backends, err
+			}
+			backends = append(backends, b)
+			registry.Register(nacostest.DefaultNamespace, nacostest.DefaultGroup, service, nacostest.Instance(b.Addr))
+		}
+	}
+	return backends, nil
+}
+
+// testBackend answers generic calls with a JSON string per method.
+type testBackend func(method string, request map[string]interface{}) string
+
+func (f testBackend) GenericCall(ctx context.Context, method string, request interface{}) (interface{}, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(request.(string)), &data); err != nil {
+		return nil, err
+	}
+	return f(method, data), nil
+}
+
+const testBaseResp = `"BaseResp":{"StatusCode":200,"StatusMessage":"Success"}`
+
+// travelBackend and reviewBackend answer like the generic servers in RPCBackend.
+var (
+	travelBackend testBackend = func(method string, request map[string]interface{}) string {
+		switch method {
+		case "SendClientData":
+			return fmt.Sprintf(`{"Msg": "Post request recieved, the message sent was %v",%s}`, request["Msg"], testBaseResp)
+		case "RetrieveClientData":
+			return fmt.Sprintf(`{"VisitedCountries": ["Taiwan","Singapore"],"Name": "Ryan","userID": %v,%s}`, request["userID"], testBaseResp)
+		case "GetAllTravelDestinations":
+			return fmt.Sprintf(`{"Destinations": ["Myammar","Japan","Sweden","Netherlands"],%s}`, testBaseResp)
+		}
+		return `{"Msg": "Invalid Service name"}`
+	}
+	reviewBackend testBackend = func(method string, request map[string]interface{}) string {
+		switch method {
+		case "sendReview", "editReview", "deleteReview":
+			return fmt.Sprintf(`{"action": "%s was successfully uploaded",%s}`, method, testBaseResp)
+		}
+		return `{"Msg": "Invalid Service name"}`
+	}
+)
+
+func TestPostFlowThroughTheRegistry(t *testing.T) {
+	if testRegistry == nil {
+		t.Skip("Runs against the fake registry only")
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/:serviceName/:methodName", genericPostHandler)
+	post := func(path string, body string) (int, string) {
+		w := ut.PerformRequest(engine, consts.MethodPost, path, &ut.Body{Body: strings.NewReader(body), Len: len(body)})
+		return w.Code, w.Body.String()
+	}
+
+	code, body := post("/TravelService/GetAllTravelDestinations", `{"userID": 1}`)
+	if code != consts.StatusOK || !strings.Contains(body, "Sweden") {
+		t.Fatalf("Expected the destinations, got %d %s", code, body)
+	}
+	code, body = post("/ReviewService/sendReview", `{"data": "great trip", "userID": 1}`)
+	if code != consts.StatusOK || !strings.Contains(body, "sendReview was successfully uploaded") {
+		t.Fatalf("Expected the review to be sent, got %d %s", code, body)
+	}
+	code, body = post("/ReviewService/sendReview", `{"userID": 1}`)
+	if code != consts.StatusBadRequest || body != errInvalidInputs.Error() {
+		t.Fatalf("Expected invalid inputs, got %d %s", code, body)
+	}
+}
+
+func TestPostFlowFollowsDeregistration(t *testing.T) {
+	if testRegistry == nil {
+		t.Skip("Runs against the fake registry only")
+	}
+	registry := nacostest.NewServer()
+	defer registry.Close()
+	b, err := nacostest.StartBackend(idlPathFor("ReviewService"), reviewBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+	ins := nacostest.Instance(b.Addr)
+	registry.Register(nacostest.DefaultNamespace, nacostest.DefaultGroup, "ReviewService", ins)
+
+	defer func(previous string) { serviceRegistryIP = previous }(serviceRegistryIP)
+	serviceRegistryIP = registry.URL
+
+	hosts, ok := getServiceHosts("ReviewService", serviceRegistryIP)["hosts"].([]interface{})
+	if !ok || len(hosts) != 1 {
+		t.Fatalf("Expected the one registered instance, got %v", hosts)
+	}
+	if _, err := callGeneric(context.Background(), "ReviewService", "deleteReview", map[string]interface{}{"reviewID": 1}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	registry.Deregister(nacostest.DefaultNamespace, nacostest.DefaultGroup, "ReviewService", ins.Ip, ins.Port)
+	if _, err := callGeneric(context.Background(), "ReviewService", "deleteReview", map[string]interface{}{"reviewID": 1}); err != errServiceNotFound {
+		t.Fatalf("Expected service not found after deregistering, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/kitex/pkg/generic"
+)
+
+/**
+ * dependencyCheck is the outcome of one readiness check.
+ */
+type dependencyCheck struct {
+	OK               bool   `json:"ok"`
+	Error            string `json:"error,omitempty"`
+	HealthyInstances *int   `json:"healthyInstances,omitempty"`
+}
+
+/**
+ * Counts the instances in a Nacos instance list that are healthy and enabled.
+ *
+ * @param hosts The "hosts" array returned by getServiceHosts.
+ *
+ * @return The number of instances able to take traffic.
+ */
+func countHealthyHosts(hosts []interface{}) int {
+	healthy := 0
+	for _, h := range hosts {
+		host, ok := h.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if isHealthy, _ := host["healthy"].(bool); !isHealthy {
+			continue
+		}
+		if enabled, ok := host["enabled"].(bool); ok && !enabled {
+			continue
+		}
+		healthy++
+	}
+	return healthy
+}
+
+/**
+ * Runs every readiness check against the configured services.
+ *
+ * @param services The services the gateway is expected to route to.
+ * @param registryIP The address of the service registry.
+ *
+ * @return Whether the gateway is ready, and the individual check results keyed by check name.
+ */
+func checkReadiness(services []string, registryIP string) (bool, map[string]interface{}) {
+	ready := true
+	registry := dependencyCheck{OK: true}
+	idls := map[string]dependencyCheck{}
+	instances := map[string]dependencyCheck{}
+
+	for _, serviceName := range services {
+		if _, err := generic.NewThriftFileProvider(idlPathFor(serviceName)); err != nil {
+			idls[serviceName] = dependencyCheck{Error: err.Error()}
+			ready = false
+		} else {
+			idls[serviceName] = dependencyCheck{OK: true}
+		}
+
+		hostInfo := getServiceHosts(serviceName, registryIP)
+		if hostInfo == nil {
+			registry = dependencyCheck{Error: "registry unreachable"}
+			instances[serviceName] = dependencyCheck{Error: "registry unreachable"}
+			ready = false
+			continue
+		}
+
+		hostList, _ := hostInfo["hosts"].([]interface{})
+		healthy := countHealthyHosts(hostList)
+		check := dependencyCheck{OK: healthy > 0, HealthyInstances: &healthy}
+		if healthy == 0 {
+			check.Error = "no healthy instances"
+			ready = false
+		}
+		instances[serviceName] = check
+	}
+
+	return ready, map[string]interface{}{
+		"registry":  registry,
+		"idl":       idls,
+		"instances": instances,
+	}
+}
+
+/**
+ * Liveness probe: answers as long as the gateway can serve requests at all.
+ */
+func healthzHandler(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, utils.H{"status": "ok"})
+}
+
+/**
+ * Readiness probe: 200 when the registry is reachable, every configured IDL parses and each
+ * configured service has at least one healthy instance, 503 otherwise.
+ */
+func readyzHandler(ctx context.Context, c *app.RequestContext) {
+	ready, checks := checkReadiness(gatewayCfg.Services, serviceRegistryIP)
+	if !ready {
+		c.JSON(consts.StatusServiceUnavailable, utils.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(consts.StatusOK, utils.H{"status": "ready", "checks": checks})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCountHealthyHosts(t *testing.T) {
+	hosts := []interface{}{
+		map[string]interface{}{"ip": "127.0.0.1", "port": 8888.0, "healthy": true, "enabled": true},
+		map[string]interface{}{"ip": "127.0.0.1", "port": 8889.0, "healthy": false, "enabled": true},
+		map[string]interface{}{"ip": "127.0.0.1", "port": 8887.0, "healthy": true, "enabled": false},
+		"not an instance",
+	}
+	if got := countHealthyHosts(hosts); got != 1 {
+		t.Fatalf("Should count 1 healthy instance, got %d", got)
+	}
+}
+
+func TestReadinessWithUnreachableRegistry(t *testing.T) {
+	ready, checks := checkReadiness([]string{"TravelService"}, "http://127.0.0.1:1")
+	if ready {
+		t.Fatalf("Should not be ready when the registry is unreachable")
+	}
+	if checks["registry"].(dependencyCheck).OK {
+		t.Fatalf("Registry check should fail")
+	}
+	if !checks["idl"].(map[string]dependencyCheck)["TravelService"].OK {
+		t.Fatalf("TravelService IDL should parse")
+	}
+}
+
+func TestReadinessWithMissingIDL(t *testing.T) {
+	_, checks := checkReadiness([]string{"invalidIDLName"}, "http://127.0.0.1:1")
+	if checks["idl"].(map[string]dependencyCheck)["invalidIDLName"].OK {
+		t.Fatalf("Missing IDL should fail the idl check")
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+var (
+	idempotentReplays = newCounterVec("gateway_idempotent_replays_total",
+		"Requests answered with the stored response of an earlier request with the same Idempotency-Key.", "service", "method")
+	idempotencyConflicts = newCounterVec("gateway_idempotency_conflicts_total",
+		"Requests rejected because their Idempotency-Key was used with a different body.", "service", "method")
+)
+
+/**
+ * idempotencyConfig turns on Idempotency-Key handling for the listed "Service/method" routes.
+ *
+ * The first successful response for a key is kept for Window and replayed for retries. Keys
+ * are scoped to a principal, taken from PrincipalHeader (Authorization by default) or the
+ * client IP when the header is absent.
+ */
+type idempotencyConfig struct {
+	Methods         []string `json:"methods"`
+	Window          duration `json:"window"`
+	PrincipalHeader string   `json:"principalHeader"`
+}
+
+/**
+ * idempotencyRecord is the stored outcome of the first request made with a key.
+ */
+type idempotencyRecord struct {
+	bodyHash string
+	done     chan struct{}
+	// completed is set before done is closed when the response was kept, and left unset when
+	// the key was released.
+	completed   bool
+	status      int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// idempotencyExpiry is a completed record waiting in the expiry heap.
+type idempotencyExpiry struct {
+	key    string
+	record *idempotencyRecord
+}
+
+// expiryHeap orders completed records by expiry, soonest first.
+type expiryHeap []idempotencyExpiry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].record.expiresAt.Before(h[j].record.expiresAt) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(idempotencyExpiry)) }
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+/**
+ * idempotencyStore remembers requests by principal and Idempotency-Key. Completed records are
+ * also kept in a heap by expiry, so expired ones are dropped without scanning every record.
+ */
+type idempotencyStore struct {
+	mu       sync.Mutex
+	window   time.Duration
+	records  map[string]*idempotencyRecord
+	expiries expiryHeap
+}
+
+func newIdempotencyStore(window time.Duration) *idempotencyStore {
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	return &idempotencyStore{window: window, records: map[string]*idempotencyRecord{}}
+}
+
+/**
+ * Claims a key for a request, or returns the record of the request that claimed it first.
+ *
+ * @return The record and whether the caller owns it. An owner must call complete or release.
+ */
+func (s *idempotencyStore) claim(key string, bodyHash string) (*idempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for len(s.expiries) > 0 && now.After(s.expiries[0].record.expiresAt) {
+		expired := heap.Pop(&s.expiries).(idempotencyExpiry)
+		if s.records[expired.key] == expired.record {
+			delete(s.records, expired.key)
+		}
+	}
+
+	if r, ok := s.records[key]; ok {
+		return r, false
+	}
+	r := &idempotencyRecord{bodyHash: bodyHash, done: make(chan struct{})}
+	s.records[key] = r
+	return r, true
+}
+
+// complete stores the response of the owning request and wakes up waiting duplicates.
+func (s *idempotencyStore) complete(key string, r *idempotencyRecord, status int, contentType string, body []byte) {
+	s.mu.Lock()
+	r.status, r.contentType, r.body = status, contentType, append([]byte(nil), body...)
+	r.completed = true
+	r.expiresAt = time.Now().Add(s.window)
+	heap.Push(&s.expiries, idempotencyExpiry{key: key, record: r})
+	s.mu.Unlock()
+	close(r.done)
+}
+
+// release forgets a key whose request failed, so a retry reaches the backend again.
+func (s *idempotencyStore) release(key string, r *idempotencyRecord) {
+	s.mu.Lock()
+	if s.records[key] == r {
+		delete(s.records, key)
+	}
+	s.mu.Unlock()
+	close(r.done)
+}
+
+/**
+ * Hashes a request body so equivalent JSON documents compare equal regardless of key order.
+ */
+func hashRequestBody(raw []byte) string {
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			raw = canonical
+		}
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+/**
+ * Hertz middleware implementing Idempotency-Key for the configured methods of the
+ * /:serviceName/:methodName route.
+ *
+ * A duplicate with the same body replays the stored response with Idempotent-Replayed: true,
+ * waiting first if the original is still in flight. A duplicate with a different body gets 409.
+ * Only 2xx responses are kept; after a failure, or a panic in a later handler, the key is free
+ * to be retried.
+ */
+func idempotencyMiddleware(cfg idempotencyConfig) app.HandlerFunc {
+	methods := map[string]bool{}
+	for _, m := range cfg.Methods {
+		methods[m] = true
+	}
+	principalHeader := cfg.PrincipalHeader
+	if principalHeader == "" {
+		principalHeader = "Authorization"
+	}
+	store := newIdempotencyStore(time.Duration(cfg.Window))
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		service, method := c.Param("serviceName"), c.Param("methodName")
+		key := string(c.GetHeader(idempotencyKeyHeader))
+		if key == "" || !methods[service+"/"+method] {
+			c.Next(ctx)
+			return
+		}
+
+		principal := string(c.GetHeader(principalHeader))
+		if principal == "" {
+			principal = "ip:" + c.ClientIP()
+		}
+		principalSum := sha256.Sum256([]byte(principal))
+		scopedKey := hex.EncodeToString(principalSum[:]) + ":" + service + "/" + method + ":" + key
+
+		bodyHash := hashRequestBody(c.GetRawData())
+		for {
+			record, owner := store.claim(scopedKey, bodyHash)
+			if owner {
+				// Released unless the response is kept, even when a later handler panics, so
+				// duplicates waiting on the record never block for good.
+				defer func() {
+					if !record.completed {
+						store.release(scopedKey, record)
+					}
+				}()
+				c.Next(ctx)
+
+				status := c.Response.StatusCode()
+				if status >= 200 && status < 300 {
+					store.complete(scopedKey, record, status, string(c.Response.Header.ContentType()), c.Response.Body())
+				}
+				return
+			}
+
+			if record.bodyHash != bodyHash {
+				idempotencyConflicts.Inc(service, method)
+				c.AbortWithStatusJSON(consts.StatusConflict, utils.H{
+					"error": "Idempotency-Key was already used with a different request body",
+				})
+				return
+			}
+			select {
+			case <-record.done:
+			case <-ctx.Done():
+				c.AbortWithStatus(consts.StatusRequestTimeout)
+				return
+			}
+			if !record.completed {
+				// The original failed and released the key; try to claim it for this request.
+				continue
+			}
+			idempotentReplays.Inc(service, method)
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.status, record.contentType, record.body)
+			c.Abort()
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// newIdempotencyEngine serves the idempotency middleware in front of a handler that counts calls
+// and fails while fail is set.
+func newIdempotencyEngine(calls *int32, fail *int32) *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	cfg := idempotencyConfig{Methods: []string{"ReviewService/sendReview"}}
+	engine.POST("/:serviceName/:methodName", idempotencyMiddleware(cfg), func(ctx context.Context, c *app.RequestContext) {
+		n := atomic.AddInt32(calls, 1)
+		if atomic.LoadInt32(fail) == 1 {
+			c.String(consts.StatusBadRequest, "backend unavailable")
+			return
+		}
+		c.JSON(consts.StatusOK, map[string]interface{}{"call": n})
+	})
+	return engine
+}
+
+func postReview(engine *route.Engine, path string, body string, headers ...ut.Header) *ut.ResponseRecorder {
+	headers = append(headers, ut.Header{Key: "Content-Type", Value: "application/json"})
+	return ut.PerformRequest(engine, consts.MethodPost, path, &ut.Body{Body: strings.NewReader(body), Len: len(body)}, headers...)
+}
+
+func TestIdempotencyReplaysAndRejectsChangedBody(t *testing.T) {
+	var calls, fail int32
+	engine := newIdempotencyEngine(&calls, &fail)
+	key := ut.Header{Key: "Idempotency-Key", Value: "abc"}
+	alice := ut.Header{Key: "Authorization", Value: "Bearer alice"}
+
+	first := postReview(engine, "/ReviewService/sendReview", `{"userID":1,"text":"great"}`, key, alice)
+	second := postReview(engine, "/ReviewService/sendReview", `{"text":"great","userID":1}`, key, alice)
+	if calls != 1 {
+		t.Fatalf("A retry with the same key and body should not reach the backend, got %d calls", calls)
+	}
+	if second.Code != consts.StatusOK || string(second.Body.Bytes()) != string(first.Body.Bytes()) {
+		t.Fatalf("The retry should replay the first response, got %d %s", second.Code, second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("A replayed response should be marked with Idempotent-Replayed")
+	}
+
+	conflict := postReview(engine, "/ReviewService/sendReview", `{"userID":1,"text":"awful"}`, key, alice)
+	if conflict.Code != consts.StatusConflict {
+		t.Fatalf("Reusing a key with a different body should give 409, got %d", conflict.Code)
+	}
+
+	bob := postReview(engine, "/ReviewService/sendReview", `{"userID":1,"text":"awful"}`, key, ut.Header{Key: "Authorization", Value: "Bearer bob"})
+	if bob.Code != consts.StatusOK || calls != 2 {
+		t.Fatalf("Keys should be scoped to the principal, got %d with %d calls", bob.Code, calls)
+	}
+}
+
+func TestIdempotencyForgetsFailedRequests(t *testing.T) {
+	var calls, fail int32 = 0, 1
+	engine := newIdempotencyEngine(&calls, &fail)
+	key := ut.Header{Key: "Idempotency-Key", Value: "retry-me"}
+
+	if resp := postReview(engine, "/ReviewService/sendReview", `{"userID":1}`, key); resp.Code != consts.StatusBadRequest {
+		t.Fatalf("Expected the backend failure to pass through, got %d", resp.Code)
+	}
+	atomic.StoreInt32(&fail, 0)
+	if resp := postReview(engine, "/ReviewService/sendReview", `{"userID":1}`, key); resp.Code != consts.StatusOK || calls != 2 {
+		t.Fatalf("A retry after a failure should reach the backend, got %d with %d calls", resp.Code, calls)
+	}
+}
+
+func TestIdempotencyIgnoresUnconfiguredMethods(t *testing.T) {
+	var calls, fail int32
+	engine := newIdempotencyEngine(&calls, &fail)
+	key := ut.Header{Key: "Idempotency-Key", Value: "abc"}
+
+	postReview(engine, "/TravelService/RetrieveClientData", `{"userID":1}`, key)
+	postReview(engine, "/TravelService/RetrieveClientData", `{"userID":1}`, key)
+	if calls != 2 {
+		t.Fatalf("Methods without opt-in should ignore Idempotency-Key, got %d calls", calls)
+	}
+}
+
+func TestIdempotencyReplaysEmptyBodiesAndReleasesOnPanic(t *testing.T) {
+	var calls int32
+	engine := route.NewEngine(config.NewOptions(nil))
+	cfg := idempotencyConfig{Methods: []string{"ReviewService/sendReview", "ReviewService/deleteReview"}}
+	engine.POST("/:serviceName/:methodName", idempotencyMiddleware(cfg), func(ctx context.Context, c *app.RequestContext) {
+		if atomic.AddInt32(&calls, 1) == 1 && c.Param("methodName") == "sendReview" {
+			panic("handler bug")
+		}
+		c.Status(consts.StatusNoContent)
+	})
+	key := ut.Header{Key: "Idempotency-Key", Value: "abc"}
+
+	func() {
+		defer func() { recover() }()
+		postReview(engine, "/ReviewService/sendReview", `{"userID":1}`, key)
+	}()
+	done := make(chan *ut.ResponseRecorder)
+	go func() { done <- postReview(engine, "/ReviewService/sendReview", `{"userID":1}`, key) }()
+	select {
+	case resp := <-done:
+		if resp.Code != consts.StatusNoContent || calls != 2 {
+			t.Fatalf("A retry after a panic should reach the handler, got %d with %d calls", resp.Code, calls)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("A retry after a panic should not wait for the key")
+	}
+
+	go func() { done <- postReview(engine, "/ReviewService/sendReview", `{"userID":1}`, key) }()
+	select {
+	case resp := <-done:
+		if resp.Code != consts.StatusNoContent || calls != 2 || resp.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("An empty 2xx response should be replayed, got %d with %d calls", resp.Code, calls)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Replaying an empty 2xx response should not spin")
+	}
+}
+
+func TestIdempotencyStoreDropsExpiredRecords(t *testing.T) {
+	store := newIdempotencyStore(time.Millisecond)
+	first, _ := store.claim("first", "hash")
+	store.complete("first", first, consts.StatusOK, "application/json", nil)
+	pending, _ := store.claim("pending", "hash")
+	time.Sleep(5 * time.Millisecond)
+
+	if _, owner := store.claim("first", "hash"); !owner {
+		t.Fatal("An expired key should be free to claim again")
+	}
+	if _, owner := store.claim("pending", "hash"); owner {
+		t.Fatal("A key still in flight should not expire")
+	}
+	store.release("pending", pending)
+	if len(store.expiries) != 0 {
+		t.Fatalf("Expected the expired record to leave the heap, got %d", len(store.expiries))
+	}
+}
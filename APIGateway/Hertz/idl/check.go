@@ -0,0 +1,172 @@
+package idl
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Check reports whether a decoded JSON value conforms to t: every required field is present
+// and every field that is present has the declared type. Values are as encoding/json decodes
+// them into interface{}, though any Go number type is accepted for numbers. Fields the IDL
+// does not declare are ignored, as Kitex's JSON codec ignores them. A null counts as absent.
+func Check(t *Type, v interface{}) error {
+	return check(t, v, "")
+}
+
+func check(t *Type, v interface{}, path string) error {
+	name := path
+	if name == "" {
+		name = "body"
+	}
+	switch t.Category {
+	case Bool:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a bool", name)
+		}
+	case Byte, I16, I32, I64:
+		n, ok := number(v)
+		if !ok || n != math.Trunc(n) || !fitsInt(t.Category, n) {
+			return fmt.Errorf("%s must be an %s", name, t.Category)
+		}
+	case Double:
+		if _, ok := number(v); !ok {
+			return fmt.Errorf("%s must be a number", name)
+		}
+	case String, Binary:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+	case Enum:
+		n, ok := number(v)
+		if !ok || !enumHas(t.EnumValues, n) {
+			return fmt.Errorf("%s must be one of the enum values %v", name, t.EnumValues)
+		}
+	case List, Set:
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be a %s", name, t)
+		}
+		for i, item := range items {
+			if err := check(t.Value, item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+		}
+	case Map:
+		entries, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be a %s", name, t)
+		}
+		for key, value := range entries {
+			if err := checkMapKey(t.Key, key); err != nil {
+				return fmt.Errorf("%s key %q: %w", name, key, err)
+			}
+			if err := check(t.Value, value, fmt.Sprintf("%s[%q]", name, key)); err != nil {
+				return err
+			}
+		}
+	case Struct:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object (%s)", name, t)
+		}
+		for _, f := range t.Struct.Fields {
+			fieldPath := f.Name
+			if path != "" {
+				fieldPath = path + "." + f.Name
+			}
+			value, present := fields[f.Name]
+			if !present || value == nil {
+				if f.Requiredness == Required {
+					return fmt.Errorf("%s is required", fieldPath)
+				}
+				continue
+			}
+			if err := check(f.Type, value, fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func fitsInt(category string, n float64) bool {
+	switch category {
+	case Byte:
+		return n >= math.MinInt8 && n <= math.MaxInt8
+	case I16:
+		return n >= math.MinInt16 && n <= math.MaxInt16
+	case I32:
+		return n >= math.MinInt32 && n <= math.MaxInt32
+	}
+	// float64 cannot hold every i64 exactly; anything it can represent in range is accepted.
+	return n >= math.MinInt64 && n <= math.MaxInt64
+}
+
+func enumHas(values []int64, n float64) bool {
+	for _, v := range values {
+		if float64(v) == n {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMapKey checks a JSON object key against the map's key type; non-string keys are
+// written as their decimal or boolean text.
+func checkMapKey(t *Type, key string) error {
+	switch t.Category {
+	case String, Binary:
+		return nil
+	case Bool:
+		if _, err := strconv.ParseBool(key); err != nil {
+			return fmt.Errorf("must be a bool")
+		}
+	case Byte, I16, I32, I64, Enum:
+		n, err := strconv.ParseInt(key, 10, 64)
+		if err != nil || (t.Category != I64 && t.Category != Enum && !fitsInt(t.Category, float64(n))) {
+			return fmt.Errorf("must be an %s", t.Category)
+		}
+	case Double:
+		if _, err := strconv.ParseFloat(key, 64); err != nil {
+			return fmt.Errorf("must be a number")
+		}
+	default:
+		return fmt.Errorf("%s keys cannot be written in JSON", t)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package idl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCheckAgainstTravelService(t *testing.T) {
+	doc, err := ParseFile("../thriftFiles/TravelService.thrift")
+	if err != nil {
+		t.Fatalf("Should parse TravelService: %v", err)
+	}
+	m, _ := doc.Services[0].Method("RetrieveClientData")
+
+	cases := []struct {
+		body  string
+		valid bool
+	}{
+		{`{"userID": 7}`, true},
+		{`{"userID": 7, "unknown": "ignored", "Base": null}`, true},
+		{`{"userID": 7, "Base": {"LogID": "a", "Extra": {"k": "v"}}}`, true},
+		{`{}`, false},
+		{`{"userID": null}`, false},
+		{`{"userID": "7"}`, false},
+		{`{"userID": 7.5}`, false},
+		{`{"userID": 4294967296}`, false},
+		{`{"userID": 7, "Base": {"Extra": {"k": 1}}}`, false},
+		{`{"userID": 7, "Base": "x"}`, false},
+	}
+	for _, tc := range cases {
+		var body interface{}
+		if err := json.Unmarshal([]byte(tc.body), &body); err != nil {
+			t.Fatal(err)
+		}
+		if err := Check(m.Request(), body); (err == nil) != tc.valid {
+			t.Fatalf("%s: expected valid=%t, got %v", tc.body, tc.valid, err)
+		}
+	}
+
+	resp := map[string]interface{}{"Name": "Ryan", "userID": 7, "VisitedCountries": []interface{}{"Japan"}}
+	if err := Check(m.Response, resp); err != nil {
+		t.Fatalf("Response should conform: %v", err)
+	}
+	delete(resp, "VisitedCountries")
+	if err := Check(m.Response, resp); err == nil {
+		t.Fatalf("Expected the missing required VisitedCountries to be reported")
+	}
+}
@@ -0,0 +1,266 @@
+package idl
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Change is one difference between two versions of an IDL.
+type Change struct {
+	// Breaking is set when a peer still using the old IDL can no longer talk to one using the
+	// new IDL, or the other way round.
+	Breaking bool
+	// File is the base name of the IDL file the change is in.
+	File string
+	// Where names what changed, e.g. "Review.rating" or "ReviewService.sendReview".
+	Where  string
+	Detail string
+}
+
+func (c Change) String() string {
+	kind := "compatible"
+	if c.Breaking {
+		kind = "BREAKING"
+	}
+	return fmt.Sprintf("%-10s %s %s: %s", kind, c.File, c.Where, c.Detail)
+}
+
+// HasBreaking reports whether any of the changes is breaking.
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare lists the changes from old to new in the services, structs and enums declared in the
+// file itself. Types pulled in through includes are left to the comparison of the included file,
+// so a change in base.thrift is reported once rather than once per file that includes it.
+func Compare(old, new *Document) []Change {
+	c := &comparison{file: filepath.Base(old.File)}
+	c.services(old.Services, new.Services)
+	c.structs(old.Structs, new.Structs)
+	c.enums(old.Enums, new.Enums)
+	sortChanges(c.changes)
+	return c.changes
+}
+
+// CompareDirs parses the .thrift files in two directories and compares the files with the same
+// name. A file missing from newDir is breaking; a file only in newDir is compatible.
+func CompareDirs(oldDir, newDir string) ([]Change, error) {
+	oldDocs, err := ParseDir(oldDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", oldDir, err)
+	}
+	newDocs, err := ParseDir(newDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", newDir, err)
+	}
+	byName := map[string]*Document{}
+	for _, d := range newDocs {
+		byName[filepath.Base(d.File)] = d
+	}
+
+	changes := []Change{}
+	for _, o := range oldDocs {
+		name := filepath.Base(o.File)
+		n, ok := byName[name]
+		if !ok {
+			changes = append(changes, Change{Breaking: true, File: name, Where: "file", Detail: "removed"})
+			continue
+		}
+		delete(byName, name)
+		changes = append(changes, Compare(o, n)...)
+	}
+	for name := range byName {
+		changes = append(changes, Change{File: name, Where: "file", Detail: "added"})
+	}
+	sortChanges(changes)
+	return changes, nil
+}
+
+func sortChanges(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].File != changes[j].File {
+			return changes[i].File < changes[j].File
+		}
+		return changes[i].Where < changes[j].Where
+	})
+}
+
+type comparison struct {
+	file    string
+	changes []Change
+}
+
+func (c *comparison) add(breaking bool, where string, format string, args ...interface{}) {
+	c.changes = append(c.changes, Change{Breaking: breaking, File: c.file, Where: where, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (c *comparison) services(old, new []*Service) {
+	next := map[string]*Service{}
+	for _, s := range new {
+		next[s.Name] = s
+	}
+	for _, o := range old {
+		n, ok := next[o.Name]
+		if !ok {
+			c.add(true, o.Name, "service removed")
+			continue
+		}
+		delete(next, o.Name)
+		for _, om := range o.Methods {
+			where := o.Name + "." + om.Name
+			nm, ok := n.Method(om.Name)
+			if !ok {
+				c.add(true, where, "method removed")
+				continue
+			}
+			if before, after := signature(om), signature(nm); before != after {
+				c.add(true, where, "signature changed from %s to %s", before, after)
+			}
+		}
+		for _, nm := range n.Methods {
+			if _, ok := o.Method(nm.Name); !ok {
+				c.add(false, o.Name+"."+nm.Name, "method added")
+			}
+		}
+	}
+	for name := range next {
+		c.add(false, name, "service added")
+	}
+}
+
+// signature spells a method as the IDL would, e.g.
+// "Response sendReview(1: ReviewRequest req) throws (1: ReviewError err)".
+func signature(m *Method) string {
+	response := "void"
+	if m.Response != nil {
+		response = m.Response.String()
+	}
+	if m.Oneway {
+		response = "oneway " + response
+	}
+	s := fmt.Sprintf("%s %s(%s)", response, m.Name, fieldList(m.Args))
+	if len(m.Throws) > 0 {
+		s += fmt.Sprintf(" throws (%s)", fieldList(m.Throws))
+	}
+	return s
+}
+
+func fieldList(fields []*Field) string {
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, fmt.Sprintf("%d: %s %s", f.ID, f.Type, f.Name))
+	}
+	return strings.Join(out, ", ")
+}
+
+func (c *comparison) structs(old, new map[string]*StructDef) {
+	names := []string{}
+	for name := range old {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.Contains(name, ".") {
+			continue
+		}
+		n, ok := new[name]
+		if !ok {
+			c.add(true, name, "struct removed")
+			continue
+		}
+		c.fields(name, old[name].Fields, n.Fields)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok && !strings.Contains(name, ".") {
+			c.add(false, name, "struct added")
+		}
+	}
+}
+
+func (c *comparison) fields(structName string, old, new []*Field) {
+	byID, byName := map[int32]*Field{}, map[string]*Field{}
+	for _, f := range new {
+		byID[f.ID] = f
+		byName[f.Name] = f
+	}
+	seen := map[int32]bool{}
+	for _, o := range old {
+		where := structName + "." + o.Name
+		n, ok := byID[o.ID]
+		if !ok {
+			if moved, ok := byName[o.Name]; ok {
+				seen[moved.ID] = true
+				c.add(true, where, "field id changed from %d to %d", o.ID, moved.ID)
+			} else {
+				c.add(true, where, "field %d removed", o.ID)
+			}
+			continue
+		}
+		seen[o.ID] = true
+		if n.Name != o.Name {
+			// Binary callers do not see names, but the gateway's JSON mapping does.
+			c.add(true, where, "field %d renamed to %s", o.ID, n.Name)
+		}
+		if before, after := o.Type.String(), n.Type.String(); before != after {
+			c.add(true, where, "type changed from %s to %s", before, after)
+		}
+		switch {
+		case n.Requiredness == Required && o.Requiredness != Required:
+			c.add(true, where, "became required")
+		case o.Requiredness == Required && n.Requiredness != Required:
+			c.add(false, where, "no longer required")
+		}
+	}
+	for _, n := range new {
+		if seen[n.ID] {
+			continue
+		}
+		if n.Requiredness == Required {
+			c.add(true, structName+"."+n.Name, "required field %d added", n.ID)
+		} else {
+			c.add(false, structName+"."+n.Name, "field %d added", n.ID)
+		}
+	}
+}
+
+// enums reports removed values as breaking, since a peer on the old IDL may still send them,
+// and added values as compatible.
+func (c *comparison) enums(old, new map[string]*Type) {
+	names := []string{}
+	for name := range old {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.Contains(name, ".") {
+			continue
+		}
+		n, ok := new[name]
+		if !ok {
+			c.add(true, name, "enum removed")
+			continue
+		}
+		for _, v := range old[name].EnumValues {
+			if !enumHas(n.EnumValues, float64(v)) {
+				c.add(true, name, "value %d removed", v)
+			}
+		}
+		for _, v := range n.EnumValues {
+			if !enumHas(old[name].EnumValues, float64(v)) {
+				c.add(false, name, "value %d added", v)
+			}
+		}
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok && !strings.Contains(name, ".") {
+			c.add(false, name, "enum added")
+		}
+	}
+}
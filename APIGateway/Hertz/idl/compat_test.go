@@ -0,0 +1,139 @@
+package idl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const compatBase = `
+enum Status {
+    PENDING = 1,
+    APPROVED = 2,
+}
+
+enum Visibility {
+    PUBLIC = 1,
+    HIDDEN = 2,
+}
+
+exception ReviewError {
+    1: string reason
+}
+
+struct Review {
+    1: required string reviewID
+    2: string text
+    3: i32 rating
+    4: optional string author
+}
+
+struct Response {
+    1: string message
+    2: Status status
+}
+
+service ReviewService {
+    Response sendReview(1: Review req) throws (1: ReviewError err)
+    Response deleteReview(1: Review req)
+}
+`
+
+func writeCompatDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func compareSources(t *testing.T, old, new string) []Change {
+	changes, err := CompareDirs(
+		writeCompatDir(t, map[string]string{"ReviewService.thrift": old}),
+		writeCompatDir(t, map[string]string{"ReviewService.thrift": new}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return changes
+}
+
+func TestCompareIdenticalIDLs(t *testing.T) {
+	if changes := compareSources(t, compatBase, compatBase); len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestCompareClassifiesChanges(t *testing.T) {
+	cases := []struct {
+		name     string
+		old, new string
+		where    string
+		breaking bool
+	}{
+		{"field removed", "    4: optional string author\n", "", "Review.author", true},
+		{"field id changed", "    3: i32 rating", "    5: i32 rating", "Review.rating", true},
+		{"field type changed", "    3: i32 rating", "    3: string rating", "Review.rating", true},
+		{"required added", "    2: string text", "    2: required string text", "Review.text", true},
+		{"required dropped", "    1: required string reviewID", "    1: string reviewID", "Review.reviewID", false},
+		{"optional field added", "    4: optional string author\n", "    4: optional string author\n    5: optional i64 createdAt\n", "Review.createdAt", false},
+		{"required field added", "    4: optional string author\n", "    4: optional string author\n    5: required i64 createdAt\n", "Review.createdAt", true},
+		{"method signature changed", "Response deleteReview(1: Review req)", "Response deleteReview(1: string reviewID)", "ReviewService.deleteReview", true},
+		{"throws removed", "Response sendReview(1: Review req) throws (1: ReviewError err)", "Response sendReview(1: Review req)", "ReviewService.sendReview", true},
+		{"throws changed", "throws (1: ReviewError err)", "throws (1: Review err)", "ReviewService.sendReview", true},
+		{"enum type swapped", "    2: Status status", "    2: Visibility status", "Response.status", true},
+		{"enum value removed", "    APPROVED = 2,\n", "", "Status", true},
+		{"enum value added", "    APPROVED = 2,\n", "    APPROVED = 2,\n    REJECTED = 3,\n", "Status", false},
+		{"enum removed", "enum Visibility {\n    PUBLIC = 1,\n    HIDDEN = 2,\n}\n", "", "Visibility", true},
+		{"method removed", "    Response deleteReview(1: Review req)\n", "", "ReviewService.deleteReview", true},
+		{"method added", "    Response deleteReview(1: Review req)\n", "    Response deleteReview(1: Review req)\n    Response editReview(1: Review req)\n", "ReviewService.editReview", false},
+	}
+	for _, tc := range cases {
+		changes := compareSources(t, compatBase, strings.Replace(compatBase, tc.old, tc.new, 1))
+		if len(changes) != 1 {
+			t.Fatalf("%s: expected one change, got %v", tc.name, changes)
+		}
+		if c := changes[0]; c.Where != tc.where || c.Breaking != tc.breaking || c.File != "ReviewService.thrift" {
+			t.Fatalf("%s: unexpected change %v", tc.name, c)
+		}
+	}
+}
+
+func TestCompareDirsReportsIncludedFilesOnce(t *testing.T) {
+	base := "struct BaseResp {\n    1: string StatusMessage\n}\n"
+	svc := "include \"base.thrift\"\nstruct Response {\n    255: base.BaseResp BaseResp\n}\nservice EchoService {\n    Response echo(1: string msg)\n}\n"
+	old := writeCompatDir(t, map[string]string{"base.thrift": base, "EchoService.thrift": svc})
+	new := writeCompatDir(t, map[string]string{"base.thrift": strings.Replace(base, "1: string", "1: i32", 1), "EchoService.thrift": svc})
+
+	changes, err := CompareDirs(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].File != "base.thrift" || changes[0].Where != "BaseResp.StatusMessage" || !HasBreaking(changes) {
+		t.Fatalf("Expected one breaking change in base.thrift, got %v", changes)
+	}
+}
+
+func TestCompareDirsMissingFile(t *testing.T) {
+	old := writeCompatDir(t, map[string]string{"ReviewService.thrift": compatBase})
+	changes, err := CompareDirs(old, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !changes[0].Breaking || changes[0].Detail != "removed" {
+		t.Fatalf("Expected the file to be reported removed, got %v", changes)
+	}
+}
+
+func TestCompareRepoCopies(t *testing.T) {
+	changes, err := CompareDirs("../thriftFiles", "../../../RPCBackend/server/thriftFiles")
+	if err != nil {
+		t.Skipf("Backend IDLs not available: %v", err)
+	}
+	if HasBreaking(changes) {
+		t.Fatalf("The gateway and backend IDLs have drifted apart: %v", changes)
+	}
+}
@@ -0,0 +1,88 @@
+package idl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudwego/thriftgo/parser"
+)
+
+// modelHash hashes what a parsed IDL declares, so comments, whitespace and formatting do not
+// change it. The backend hashes its IDL the same way before publishing it as idl_hash; the two
+// must stay byte for byte identical.
+//
+// Each file contributes its base name, typedefs, enums with their values, structs, unions and
+// exceptions with their fields in ID order, and services with their functions, arguments and
+// throws. The root file comes first and includes follow depth first in the order they appear.
+func modelHash(ast *parser.Thrift) string {
+	h := sha256.New()
+	seen := map[*parser.Thrift]bool{}
+	var visit func(t *parser.Thrift)
+	visit = func(t *parser.Thrift) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		fmt.Fprintf(h, "file %s\n", filepath.Base(t.Filename))
+		for _, td := range t.Typedefs {
+			fmt.Fprintf(h, "typedef %s %s\n", typeName(td.Type), td.Alias)
+		}
+		for _, e := range t.Enums {
+			fmt.Fprintf(h, "enum %s\n", e.Name)
+			for _, v := range e.Values {
+				fmt.Fprintf(h, "  %s = %d\n", v.Name, v.Value)
+			}
+		}
+		for _, group := range [][]*parser.StructLike{t.Structs, t.Unions, t.Exceptions} {
+			for _, s := range group {
+				fmt.Fprintf(h, "%s %s\n", s.Category, s.Name)
+				hashFields(h, s.Fields)
+			}
+		}
+		for _, svc := range t.Services {
+			fmt.Fprintf(h, "service %s extends %s\n", svc.Name, svc.Extends)
+			for _, fn := range svc.Functions {
+				response := "void"
+				if !fn.Void {
+					response = typeName(fn.FunctionType)
+				}
+				fmt.Fprintf(h, "  function %s %s oneway=%t\n", response, fn.Name, fn.Oneway)
+				hashFields(h, fn.Arguments)
+				fmt.Fprintf(h, "  throws\n")
+				hashFields(h, fn.Throws)
+			}
+		}
+		for _, inc := range t.Includes {
+			if inc.Reference != nil {
+				visit(inc.Reference)
+			}
+		}
+	}
+	visit(ast)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashFields(w io.Writer, fields []*parser.Field) {
+	sorted := append([]*parser.Field(nil), fields...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, f := range sorted {
+		fmt.Fprintf(w, "    %d: %d %s %s\n", f.ID, int64(f.Requiredness), typeName(f.Type), f.Name)
+	}
+}
+
+// typeName spells a type as written in the IDL, e.g. "map<string,list<base.BaseResp>>".
+func typeName(t *parser.Type) string {
+	switch {
+	case t == nil:
+		return ""
+	case t.KeyType != nil:
+		return fmt.Sprintf("%s<%s,%s>", t.Name, typeName(t.KeyType), typeName(t.ValueType))
+	case t.ValueType != nil:
+		return fmt.Sprintf("%s<%s>", t.Name, typeName(t.ValueType))
+	}
+	return t.Name
+}
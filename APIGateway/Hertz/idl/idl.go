@@ -0,0 +1,382 @@
+// Package idl loads Thrift IDL files into a resolved schema model.
+//
+// The gateway only ever needed the IDLs to build a Kitex generic codec. The model here
+// resolves every service, method and struct (including those pulled in through includes such
+// as base.thrift) so the gateway can describe the API it exposes.
+package idl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/thriftgo/parser"
+)
+
+// Type categories.
+const (
+	Bool   = "bool"
+	Byte   = "byte"
+	I16    = "i16"
+	I32    = "i32"
+	I64    = "i64"
+	Double = "double"
+	String = "string"
+	Binary = "binary"
+	List   = "list"
+	Set    = "set"
+	Map    = "map"
+	Struct = "struct"
+	Enum   = "enum"
+)
+
+// Field requiredness as written in the IDL.
+const (
+	Required = "required"
+	Optional = "optional"
+	Default  = "default"
+)
+
+// Type is a resolved Thrift type.
+type Type struct {
+	// Category is one of the type category constants.
+	Category string
+	// Key is the key type of a map.
+	Key *Type
+	// Value is the element type of a list or set, or the value type of a map.
+	Value *Type
+	// Struct is set when Category is Struct.
+	Struct *StructDef
+	// Name is set when Category is Enum, qualified like a struct name.
+	Name string
+	// EnumValues is set when Category is Enum.
+	EnumValues []int64
+}
+
+// String spells the type the way it would be written in the IDL, with struct and enum names
+// qualified by their include prefix.
+func (t *Type) String() string {
+	switch t.Category {
+	case List, Set:
+		return fmt.Sprintf("%s<%s>", t.Category, t.Value)
+	case Map:
+		return fmt.Sprintf("map<%s,%s>", t.Key, t.Value)
+	case Struct:
+		return t.Struct.Name
+	case Enum:
+		return t.Name
+	default:
+		return t.Category
+	}
+}
+
+// Field is a struct field or a method argument.
+type Field struct {
+	ID           int32
+	Name         string
+	Requiredness string
+	Type         *Type
+}
+
+// StructDef is a Thrift struct, union or exception.
+type StructDef struct {
+	// Name is qualified with the include prefix when the struct lives in an included file,
+	// e.g. "base.BaseResp".
+	Name   string
+	Fields []*Field
+}
+
+// Method is one function of a service.
+type Method struct {
+	Name   string
+	Oneway bool
+	// Args are the method arguments. Kitex JSON generic calls take the first argument
+	// as the request body.
+	Args []*Field
+	// Response is nil for void methods.
+	Response *Type
+	// Throws are the exceptions the method declares.
+	Throws []*Field
+}
+
+// Request returns the type of the request body, or nil for methods without arguments.
+func (m *Method) Request() *Type {
+	if len(m.Args) == 0 {
+		return nil
+	}
+	return m.Args[0].Type
+}
+
+// Service is a Thrift service with its methods resolved.
+type Service struct {
+	Name    string
+	File    string
+	Methods []*Method
+}
+
+// Method looks up a method by name.
+func (s *Service) Method(name string) (*Method, bool) {
+	for _, m := range s.Methods {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// Document is a parsed IDL file together with everything it includes.
+type Document struct {
+	File string
+	// Files lists the file and everything it includes, directly or not.
+	Files []string
+	// Hash identifies what the file and its includes declare; comments and formatting do
+	// not change it.
+	Hash     string
+	Services []*Service
+	// Structs holds every struct reachable from the file, keyed by qualified name.
+	Structs map[string]*StructDef
+	// Enums holds every enum reachable from the file, keyed by qualified name.
+	Enums map[string]*Type
+}
+
+// StructNames returns the qualified names of all structs in the document, sorted.
+func (d *Document) StructNames() []string {
+	names := make([]string, 0, len(d.Structs))
+	for name := range d.Structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// includedFiles lists the file of ast and of everything it includes, root first.
+func includedFiles(ast *parser.Thrift) []string {
+	var files []string
+	seen := map[*parser.Thrift]bool{}
+	var visit func(t *parser.Thrift)
+	visit = func(t *parser.Thrift) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		files = append(files, t.Filename)
+		for _, inc := range t.Includes {
+			if inc.Reference != nil {
+				visit(inc.Reference)
+			}
+		}
+	}
+	visit(ast)
+	return files
+}
+
+// ParseFile parses an IDL file and the files it includes.
+func ParseFile(path string) (*Document, error) {
+	ast, err := parser.ParseFile(path, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &resolver{root: ast, structs: map[string]*StructDef{}, enums: map[string]*Type{}}
+	doc := &Document{File: path, Files: includedFiles(ast), Hash: modelHash(ast), Structs: r.structs, Enums: r.enums}
+
+	// Resolve every struct, union, exception and enum up front so the document lists them even
+	// when no method uses them.
+	for _, group := range [][]*parser.StructLike{ast.Structs, ast.Unions, ast.Exceptions} {
+		for _, st := range group {
+			if _, err := r.resolveStruct(ast, st); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, en := range ast.Enums {
+		r.resolveEnum(ast, en)
+	}
+
+	for _, svc := range ast.Services {
+		s := &Service{Name: svc.Name, File: path}
+		for _, fn := range svc.Functions {
+			m := &Method{Name: fn.Name, Oneway: fn.Oneway}
+			for _, arg := range fn.Arguments {
+				f, err := r.resolveField(ast, arg)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", svc.Name, fn.Name, err)
+				}
+				m.Args = append(m.Args, f)
+			}
+			if !fn.Void {
+				t, err := r.resolveType(ast, fn.FunctionType)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", svc.Name, fn.Name, err)
+				}
+				m.Response = t
+			}
+			for _, exc := range fn.Throws {
+				f, err := r.resolveField(ast, exc)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", svc.Name, fn.Name, err)
+				}
+				m.Throws = append(m.Throws, f)
+			}
+			s.Methods = append(s.Methods, m)
+		}
+		doc.Services = append(doc.Services, s)
+	}
+	return doc, nil
+}
+
+// ParseDir parses every .thrift file in dir. Files that declare no service, such as
+// base.thrift, are still parsed so syntax errors surface, but contribute no services.
+func ParseDir(dir string) ([]*Document, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := []*Document{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".thrift" {
+			continue
+		}
+		doc, err := ParseFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+type resolver struct {
+	root    *parser.Thrift
+	structs map[string]*StructDef
+	enums   map[string]*Type
+}
+
+// qualify prefixes names from included files with the include's reference name.
+func (r *resolver) qualify(file *parser.Thrift, name string) string {
+	if file == r.root {
+		return name
+	}
+	return refName(file.Filename) + "." + name
+}
+
+func refName(filename string) string {
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+}
+
+func (r *resolver) resolveField(file *parser.Thrift, f *parser.Field) (*Field, error) {
+	t, err := r.resolveType(file, f.Type)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	req := Default
+	switch f.Requiredness {
+	case parser.FieldType_Required:
+		req = Required
+	case parser.FieldType_Optional:
+		req = Optional
+	}
+	return &Field{ID: f.ID, Name: f.Name, Requiredness: req, Type: t}, nil
+}
+
+func (r *resolver) resolveStruct(file *parser.Thrift, st *parser.StructLike) (*StructDef, error) {
+	name := r.qualify(file, st.Name)
+	if def, ok := r.structs[name]; ok {
+		return def, nil
+	}
+
+	// Register before resolving fields so self-referencing structs terminate.
+	def := &StructDef{Name: name}
+	r.structs[name] = def
+	for _, f := range st.Fields {
+		field, err := r.resolveField(file, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		def.Fields = append(def.Fields, field)
+	}
+	return def, nil
+}
+
+func (r *resolver) resolveEnum(file *parser.Thrift, en *parser.Enum) *Type {
+	name := r.qualify(file, en.Name)
+	if t, ok := r.enums[name]; ok {
+		return t
+	}
+	values := []int64{}
+	for _, v := range en.Values {
+		values = append(values, v.Value)
+	}
+	t := &Type{Category: Enum, Name: name, EnumValues: values}
+	r.enums[name] = t
+	return t
+}
+
+func (r *resolver) resolveType(file *parser.Thrift, t *parser.Type) (*Type, error) {
+	switch t.Name {
+	case "bool", "byte", "i8", "i16", "i32", "i64", "double", "string", "binary":
+		cat := t.Name
+		if cat == "i8" {
+			cat = Byte
+		}
+		return &Type{Category: cat}, nil
+	case "list", "set":
+		v, err := r.resolveType(file, t.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Category: t.Name, Value: v}, nil
+	case "map":
+		k, err := r.resolveType(file, t.KeyType)
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.resolveType(file, t.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Category: Map, Key: k, Value: v}, nil
+	}
+
+	// A named type, either local or "prefix.Name" from an include.
+	scope, name := file, t.Name
+	if idx := strings.LastIndex(t.Name, "."); idx >= 0 {
+		ref, ok := file.GetReference(t.Name[:idx])
+		if !ok {
+			return nil, fmt.Errorf("unknown include %q", t.Name[:idx])
+		}
+		scope, name = ref, t.Name[idx+1:]
+	}
+
+	if st, ok := scope.GetStruct(name); ok {
+		def, err := r.resolveStruct(scope, st)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Category: Struct, Struct: def}, nil
+	}
+	if st, ok := scope.GetUnion(name); ok {
+		def, err := r.resolveStruct(scope, st)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Category: Struct, Struct: def}, nil
+	}
+	if st, ok := scope.GetException(name); ok {
+		def, err := r.resolveStruct(scope, st)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Category: Struct, Struct: def}, nil
+	}
+	if td, ok := scope.GetTypedef(name); ok {
+		return r.resolveType(scope, td.Type)
+	}
+	if en, ok := scope.GetEnum(name); ok {
+		return r.resolveEnum(scope, en), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", t.Name)
+}
@@ -0,0 +1,65 @@
+package idl
+
+import (
+	"testing"
+)
+
+func TestParseReviewService(t *testing.T) {
+	doc, err := ParseFile("../thriftFiles/ReviewService.thrift")
+	if err != nil {
+		t.Fatalf("Should parse ReviewService: %v", err)
+	}
+	if len(doc.Services) != 1 || doc.Services[0].Name != "ReviewService" {
+		t.Fatalf("Should find ReviewService, got %v", doc.Services)
+	}
+
+	m, ok := doc.Services[0].Method("sendReview")
+	if !ok {
+		t.Fatalf("sendReview not found")
+	}
+	if m.Request().String() != "ReviewRequest" {
+		t.Fatalf("Request type should be ReviewRequest, got %s", m.Request())
+	}
+	if m.Response.String() != "Response" {
+		t.Fatalf("Response type should be Response, got %s", m.Response)
+	}
+
+	resp := m.Response.Struct
+	baseResp := resp.Fields[len(resp.Fields)-1]
+	if baseResp.ID != 255 || baseResp.Type.String() != "base.BaseResp" {
+		t.Fatalf("BaseResp should resolve through the include, got %d %s", baseResp.ID, baseResp.Type)
+	}
+	if _, ok := doc.Structs["base.BaseResp"]; !ok {
+		t.Fatalf("base.BaseResp should be listed in the document structs")
+	}
+}
+
+func TestParseTravelServiceTypes(t *testing.T) {
+	doc, err := ParseFile("../thriftFiles/TravelService.thrift")
+	if err != nil {
+		t.Fatalf("Should parse TravelService: %v", err)
+	}
+	m, _ := doc.Services[0].Method("GetAllTravelDestinations")
+	dest := m.Response.Struct.Fields[0]
+	if dest.Requiredness != Required || dest.Type.String() != "list<string>" {
+		t.Fatalf("Destinations should be a required list<string>, got %s %s", dest.Requiredness, dest.Type)
+	}
+	extra := doc.Structs["base.BaseResp"].Fields[2]
+	if extra.Requiredness != Optional || extra.Type.String() != "map<string,string>" {
+		t.Fatalf("Extra should be an optional map<string,string>, got %s %s", extra.Requiredness, extra.Type)
+	}
+}
+
+func TestParseDirSkipsFilesWithoutServices(t *testing.T) {
+	docs, err := ParseDir("../thriftFiles")
+	if err != nil {
+		t.Fatalf("Should parse thriftFiles: %v", err)
+	}
+	services := 0
+	for _, d := range docs {
+		services += len(d.Services)
+	}
+	if services != 2 {
+		t.Fatalf("Should find 2 services, got %d", services)
+	}
+}
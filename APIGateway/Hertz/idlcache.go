@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"hertz_demo/idl"
+)
+
+/**
+ * idlCache keeps each IDL file parsed, so request checks do not parse it on every call. An
+ * entry is parsed again when the modification time or size of the file, or of any file it
+ * includes such as base.thrift, changes, as it does when the admin API replaces an IDL.
+ */
+type idlCache struct {
+	mu      sync.Mutex
+	entries map[string]idlCacheEntry
+}
+
+type idlCacheEntry struct {
+	// files holds the stat of the file and of every file it includes when it was parsed.
+	files map[string]fileStamp
+	doc   *idl.Document
+}
+
+// fileStamp is what the cache compares to tell whether a file changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+func newIDLCache() *idlCache {
+	return &idlCache{entries: map[string]idlCacheEntry{}}
+}
+
+/**
+ * Returns the parsed IDL file.
+ *
+ * @param path The IDL file.
+ * @return An error if the file cannot be read or parsed; failures are not cached.
+ */
+func (c *idlCache) get(path string) (*idl.Document, error) {
+	root, err := stampOf(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.fresh() {
+		return entry.doc, nil
+	}
+
+	doc, err := idl.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]fileStamp{path: root}
+	for _, file := range doc.Files[1:] {
+		stamp, err := stampOf(file)
+		if err != nil {
+			return nil, err
+		}
+		files[file] = stamp
+	}
+	c.mu.Lock()
+	c.entries[path] = idlCacheEntry{files: files, doc: doc}
+	c.mu.Unlock()
+	return doc, nil
+}
+
+// fresh reports whether none of the entry's files changed since it was parsed.
+func (e idlCacheEntry) fresh() bool {
+	for file, stamp := range e.files {
+		now, err := stampOf(file)
+		if err != nil || !now.modTime.Equal(stamp.modTime) || now.size != stamp.size {
+			return false
+		}
+	}
+	return true
+}
+
+// parsedIDLs caches the IDLs of the services the gateway calls.
+var parsedIDLs = newIDLCache()
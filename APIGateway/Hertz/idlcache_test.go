@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIDLCacheReparsesChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Echo.thrift")
+	write := func(idl string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(idl), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("service Echo { string ping(1: string msg) }", time.Unix(1000, 0))
+
+	cache := newIDLCache()
+	first, err := cache.get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.get(path); again != first {
+		t.Fatal("An unchanged file should not be parsed again")
+	}
+
+	write("service Echo { string ping(1: string msg), string pong(1: string msg) }", time.Unix(2000, 0))
+	changed, err := cache.get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := changed.Services[0].Method("pong"); !ok || changed == first {
+		t.Fatal("A changed file should be parsed again")
+	}
+
+	if _, err := cache.get(filepath.Join(dir, "Missing.thrift")); err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+}
+
+func TestIDLCacheReparsesWhenAnIncludeChanges(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, idl string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(idl), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("shared.thrift", "struct Base { 1: string id }")
+	write("Echo.thrift", "include \"shared.thrift\"\nservice Echo { shared.Base ping(1: string msg) }")
+
+	cache := newIDLCache()
+	first, err := cache.get(filepath.Join(dir, "Echo.thrift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("shared.thrift", "struct Base { 1: string id, 2: i64 version }")
+	changed, err := cache.get(filepath.Join(dir, "Echo.thrift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first || len(changed.Structs["shared.Base"].Fields) != 2 {
+		t.Fatal("A change to an included file should parse the IDL again")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"hertz_demo/idl"
+)
+
+/**
+ * idlCompatConfig points the startup check at the backend's copy of the IDLs.
+ */
+type idlCompatConfig struct {
+	// BackendDir is the backend's thriftFiles directory; the check is off when it is empty.
+	BackendDir string `json:"backendDir"`
+	// FailOnBreaking stops the gateway from starting when a breaking change is found.
+	FailOnBreaking bool `json:"failOnBreaking"`
+}
+
+/**
+ * Compares the gateway's IDLs with the backend's copy and logs every difference, treating the
+ * gateway's copy as the old version.
+ *
+ * @param cfg Where the backend's IDLs are and whether a breaking change is fatal.
+ * @param gatewayDir The gateway's thriftFiles directory.
+ * @return An error, only when FailOnBreaking is set, if a change is breaking or the IDLs cannot
+ *         be compared. Without it a missing or unreadable directory is only logged.
+ */
+func checkIDLCompat(cfg idlCompatConfig, gatewayDir string) error {
+	if cfg.BackendDir == "" {
+		return nil
+	}
+	changes, err := idl.CompareDirs(gatewayDir, cfg.BackendDir)
+	if err != nil {
+		err = fmt.Errorf("error comparing IDLs with %s: %w", cfg.BackendDir, err)
+		if cfg.FailOnBreaking {
+			return err
+		}
+		fmt.Println("Skipping the IDL compatibility check:", err)
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Println("IDL drift:", c)
+	}
+	if cfg.FailOnBreaking && idl.HasBreaking(changes) {
+		return fmt.Errorf("the IDLs in %s are incompatible with the gateway's", cfg.BackendDir)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckIDLCompat(t *testing.T) {
+	backend := t.TempDir()
+	for _, name := range []string{"TravelService.thrift", "ReviewService.thrift", "base.thrift"} {
+		data, err := os.ReadFile(filepath.Join(thriftDirectory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(backend, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := idlCompatConfig{BackendDir: backend, FailOnBreaking: true}
+	if err := checkIDLCompat(cfg, thriftDirectory); err != nil {
+		t.Fatalf("Identical IDLs should pass: %v", err)
+	}
+
+	// Removing a method from the backend's copy is breaking.
+	path := filepath.Join(backend, "ReviewService.thrift")
+	data, _ := os.ReadFile(path)
+	lines := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, "deleteReview(") {
+			lines = append(lines, line)
+		}
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkIDLCompat(cfg, thriftDirectory); err == nil {
+		t.Fatalf("Expected the removed method to fail the check")
+	}
+	cfg.FailOnBreaking = false
+	if err := checkIDLCompat(cfg, thriftDirectory); err != nil {
+		t.Fatalf("Without failOnBreaking drift is only logged: %v", err)
+	}
+
+	missing := idlCompatConfig{BackendDir: filepath.Join(backend, "missing")}
+	if err := checkIDLCompat(missing, thriftDirectory); err != nil {
+		t.Fatalf("Without failOnBreaking a missing backend dir is only logged: %v", err)
+	}
+	missing.FailOnBreaking = true
+	if err := checkIDLCompat(missing, thriftDirectory); err == nil {
+		t.Fatalf("With failOnBreaking a missing backend dir should fail the check")
+	}
+
+	if err := checkIDLCompat(idlCompatConfig{}, thriftDirectory); err != nil {
+		t.Fatalf("The check should be off without a backend dir: %v", err)
+	}
+}
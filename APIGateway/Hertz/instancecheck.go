@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/kitex/pkg/discovery"
+)
+
+// Metadata keys the backends publish with each instance.
+const (
+	metaVersion = "version"
+	metaIDLHash = "idl_hash"
+)
+
+// What the gateway does with an instance whose IDL hash differs from its own.
+const (
+	idlMismatchWarn   = "warn"
+	idlMismatchRefuse = "refuse"
+	idlMismatchOff    = "off"
+)
+
+/**
+ * instanceCheckConfig decides how the gateway treats backend instances built from another IDL.
+ */
+type instanceCheckConfig struct {
+	// IDLMismatch is warn (the default), refuse or off. Instances that publish no hash are
+	// always kept, so backends older than the check keep working.
+	IDLMismatch string `json:"idlMismatch"`
+}
+
+/**
+ * Returns the hash of what a service's IDL declares, together with every file it includes,
+ * as the backend computes it before publishing it. Comments and formatting do not change it.
+ *
+ * The hash is kept with the parsed IDL in parsedIDLs, so resolving instances does not read
+ * the files again until one of them changes.
+ *
+ * @param path The path of the service's IDL file.
+ * @return The hex-encoded SHA-256 hash, or an error if a file cannot be read or parsed.
+ */
+func idlHash(path string) (string, error) {
+	doc, err := parsedIDLs.get(path)
+	if err != nil {
+		return "", err
+	}
+	return doc.Hash, nil
+}
+
+var instanceIDLChecks = newCounterVec("gateway_instance_idl_checks_total",
+	"Backend instances checked against the gateway's IDL, by outcome.", "service", "outcome")
+
+// idlMismatchWarnings remembers the instance and hash pairs already logged, so each is warned about once.
+var idlMismatchWarnings sync.Map
+
+/**
+ * idlCheckResolver compares the IDL hash each instance publishes with the hash of the
+ * gateway's own copy of the service's IDL. A mismatch is logged once per instance and hash;
+ * in refuse mode the instance is also dropped.
+ */
+type idlCheckResolver struct {
+	discovery.Resolver
+	mode string
+	// idlPath returns the gateway's IDL file for a service.
+	idlPath func(service string) string
+}
+
+func (r idlCheckResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	res, err := r.Resolver.Resolve(ctx, desc)
+	if err != nil || r.mode == idlMismatchOff {
+		return res, err
+	}
+	want, err := idlHash(r.idlPath(desc))
+	if err != nil {
+		// Without a local IDL the call fails later anyway; there is nothing to compare against.
+		return res, nil
+	}
+
+	kept := make([]discovery.Instance, 0, len(res.Instances))
+	for _, ins := range res.Instances {
+		got, ok := ins.Tag(metaIDLHash)
+		switch {
+		case !ok || got == "":
+			instanceIDLChecks.Inc(desc, "unknown")
+		case got == want:
+			instanceIDLChecks.Inc(desc, "match")
+		default:
+			instanceIDLChecks.Inc(desc, "mismatch")
+			address := ins.Address().String()
+			if _, logged := idlMismatchWarnings.LoadOrStore(desc+"/"+address+"/"+got, true); !logged {
+				version, _ := ins.Tag(metaVersion)
+				fmt.Printf("Instance %s of %s (version %q) has IDL hash %s, the gateway has %s\n", address, desc, version, got, want)
+			}
+			if r.mode == idlMismatchRefuse {
+				continue
+			}
+		}
+		kept = append(kept, ins)
+	}
+	if len(kept) == 0 && len(res.Instances) > 0 {
+		return discovery.Result{}, fmt.Errorf("every instance of %s was built from a different IDL", desc)
+	}
+	res.Instances = kept
+	return res, nil
+}
+
+// validate rejects an unknown idlMismatch mode at startup.
+func (c instanceCheckConfig) validate() error {
+	switch c.IDLMismatch {
+	case idlMismatchWarn, idlMismatchRefuse, idlMismatchOff:
+		return nil
+	}
+	return fmt.Errorf("instanceCheck.idlMismatch must be warn, refuse or off, not %q", c.IDLMismatch)
+}
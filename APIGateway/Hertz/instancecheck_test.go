@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/kitex/pkg/discovery"
+)
+
+func writeIDL(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestIDLHashIgnoresFormattingButCoversIncludes(t *testing.T) {
+	dir := t.TempDir()
+	root := writeIDL(t, dir, "EchoService.thrift", "include \"shared.thrift\"\nservice EchoService { shared.Base echo(1: shared.Base req) }\n")
+	writeIDL(t, dir, "shared.thrift", "struct Base { 1: string id }\n")
+
+	before, err := idlHash(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeIDL(t, dir, "EchoService.thrift", "include \"shared.thrift\"\r\n\r\n// Echoes.\r\nservice EchoService {\r\n    shared.Base echo(1: shared.Base req)\r\n}\r\n")
+	if reformatted, _ := idlHash(root); reformatted != before {
+		t.Fatalf("Comments, whitespace and line endings changed the hash")
+	}
+	writeIDL(t, dir, "shared.thrift", "struct Base { 1: i64 id }\n")
+	if changed, _ := idlHash(root); changed == before {
+		t.Fatalf("A change in an included file did not change the hash")
+	}
+	if _, err := idlHash(filepath.Join(dir, "missing.thrift")); err == nil {
+		t.Fatalf("Expected an error for a missing IDL")
+	}
+}
+
+// goldenIDL and goldenIDLHash are shared with the backend's TestIDLHashGolden; a change to how
+// either side hashes an IDL must change both.
+const (
+	goldenIDL = `enum Kind { A = 1, B }
+exception Failed { 1: string why }
+union Choice { 1: string text, 2: i32 number }
+struct Item {
+    2: optional map<string, list<Kind>> tags,
+    1: required i64 id = 7,
+}
+service Golden {
+    Item get(1: Item req, 2: Choice choice) throws (1: Failed failed),
+    oneway void ping(),
+}
+`
+	goldenIDLHash = "4f0deb3256f10152d4d1b564fa7b1c1519eed0fb25cd9eaedaa67ab3f904af03"
+)
+
+func TestIDLHashGolden(t *testing.T) {
+	got, err := idlHash(writeIDL(t, t.TempDir(), "Golden.thrift", goldenIDL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != goldenIDLHash {
+		t.Fatalf("Expected %s, got %s", goldenIDLHash, got)
+	}
+}
+
+// The hash must match what the backend publishes for the same files.
+func TestIDLHashMatchesTheBackendCopy(t *testing.T) {
+	for _, service := range []string{"TravelService", "ReviewService"} {
+		gateway, err := idlHash(idlPathFor(service))
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend, err := idlHash(filepath.Join("..", "..", "RPCBackend", "server", "thriftFiles", service+".thrift"))
+		if err != nil {
+			t.Skipf("Backend IDL not available: %v", err)
+		}
+		if gateway != backend {
+			t.Fatalf("%s: the gateway and backend IDLs differ", service)
+		}
+	}
+}
+
+func TestIDLCheckResolver(t *testing.T) {
+	dir := t.TempDir()
+	path := writeIDL(t, dir, "EchoService.thrift", "service EchoService {}\n")
+	want, err := idlHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := discovery.SynthesizedResolver{ResolveFunc: func(ctx context.Context, key string) (discovery.Result, error) {
+		return discovery.Result{Instances: []discovery.Instance{
+			discovery.NewInstance("tcp", "10.0.0.1:8888", 10, map[string]string{metaIDLHash: want}),
+			discovery.NewInstance("tcp", "10.0.0.2:8888", 10, map[string]string{metaIDLHash: "stale", metaVersion: "v1"}),
+			discovery.NewInstance("tcp", "10.0.0.3:8888", 10, nil),
+		}}, nil
+	}}
+	resolve := func(mode string) []string {
+		r := idlCheckResolver{Resolver: inner, mode: mode, idlPath: func(string) string { return path }}
+		res, err := r.Resolve(context.Background(), "EchoService")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addresses := []string{}
+		for _, ins := range res.Instances {
+			addresses = append(addresses, ins.Address().String())
+		}
+		return addresses
+	}
+
+	if got := resolve(idlMismatchWarn); len(got) != 3 {
+		t.Fatalf("warn should keep every instance, got %v", got)
+	}
+	// Instances without a hash predate the check and are kept.
+	if got := resolve(idlMismatchRefuse); len(got) != 2 || got[0] != "10.0.0.1:8888" || got[1] != "10.0.0.3:8888" {
+		t.Fatalf("refuse should drop only the mismatched instance, got %v", got)
+	}
+
+	only := idlCheckResolver{mode: idlMismatchRefuse, idlPath: func(string) string { return path },
+		Resolver: discovery.SynthesizedResolver{ResolveFunc: func(ctx context.Context, key string) (discovery.Result, error) {
+			return discovery.Result{Instances: []discovery.Instance{
+				discovery.NewInstance("tcp", "10.0.0.2:8888", 10, map[string]string{metaIDLHash: "stale"}),
+			}}, nil
+		}}}
+	if _, err := only.Resolve(context.Background(), "EchoService"); err == nil {
+		t.Fatalf("Expected an error when every instance is refused")
+	}
+}
+
+func TestInstanceCheckConfigValidate(t *testing.T) {
+	if err := defaultGatewayConfig().InstanceCheck.validate(); err != nil {
+		t.Fatalf("The default should be valid: %v", err)
+	}
+	if err := (instanceCheckConfig{IDLMismatch: "block"}).validate(); err == nil {
+		t.Fatalf("Expected an unknown mode to be rejected")
+	}
+}
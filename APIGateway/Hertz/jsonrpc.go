@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCInternalError  = -32603
+)
+
+// Server error codes, from the range the specification reserves for implementations.
+const (
+	jsonRPCBackendError       = -32000
+	jsonRPCTimeout            = -32001
+	jsonRPCServiceUnavailable = -32002
+	jsonRPCOverLimit          = -32003
+	jsonRPCForbidden          = -32004
+)
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	// ID is nil when the member is absent, which makes the request a notification.
+	ID json.RawMessage `json:"id"`
+}
+
+type jsonRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string
+	Result  interface{}
+	Error   *jsonRPCError
+	ID      json.RawMessage
+}
+
+// MarshalJSON writes exactly one of result and error; result is kept even when null or empty.
+func (r jsonRPCResponse) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{"jsonrpc": r.JSONRPC, "id": r.ID}
+	if r.Error != nil {
+		out["error"] = r.Error
+	} else {
+		out["result"] = r.Result
+	}
+	return json.Marshal(out)
+}
+
+var nullID = json.RawMessage("null")
+
+/**
+ * Maps an error from the generic call path to a JSON-RPC error object.
+ */
+func jsonRPCErrorFrom(err error) *jsonRPCError {
+	code := jsonRPCBackendError
+	switch {
+	case errors.Is(err, errInvalidInputs):
+		code = jsonRPCInvalidParams
+	case errors.Is(err, errNoRoute):
+		code = jsonRPCMethodNotFound
+	case errors.Is(err, kerrors.ErrRPCTimeout), errors.Is(err, kerrors.ErrTimeoutByBusiness):
+		code = jsonRPCTimeout
+	case errors.Is(err, errServiceNotFound), errors.Is(err, errRegistryUnavailable),
+		errors.Is(err, kerrors.ErrServiceDiscovery), errors.Is(err, kerrors.ErrGetConnection),
+		errors.Is(err, kerrors.ErrNoMoreInstance), errors.Is(err, kerrors.ErrLoadbalance),
+		errors.Is(err, kerrors.ErrCircuitBreak), errors.Is(err, errRouteDisabled):
+		code = jsonRPCServiceUnavailable
+	case errors.Is(err, kerrors.ErrOverlimit):
+		code = jsonRPCOverLimit
+	case errors.Is(err, kerrors.ErrACL), errors.Is(err, errUnauthenticated):
+		code = jsonRPCForbidden
+	}
+	return &jsonRPCError{Code: code, Message: err.Error()}
+}
+
+/**
+ * Turns a response whose BaseResp reports a failure into a JSON-RPC error object.
+ *
+ * A StatusCode of 0 or in the 2xx range is success. Otherwise the StatusCode becomes the error
+ * code, the StatusMessage the message, and the whole response is kept as data.
+ *
+ * @return nil when the response succeeded or carries no BaseResp.
+ */
+func baseRespError(resp interface{}) *jsonRPCError {
+	m, ok := resp.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	baseResp, ok := m["BaseResp"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	status, _ := baseResp["StatusCode"].(float64)
+	if status == 0 || (status >= 200 && status < 300) {
+		return nil
+	}
+	message, _ := baseResp["StatusMessage"].(string)
+	if message == "" {
+		message = "backend reported a failure"
+	}
+	return &jsonRPCError{Code: int(status), Message: message, Data: resp}
+}
+
+/**
+ * jsonRPCConfig bounds batches: a batch with more than MaxBatch members is refused, and at
+ * most Workers members of a batch are called at once.
+ */
+type jsonRPCConfig struct {
+	MaxBatch int `json:"maxBatch"`
+	Workers  int `json:"workers"`
+}
+
+/**
+ * jsonRPCServer dispatches JSON-RPC calls named "Service.method" through a generic caller.
+ */
+type jsonRPCServer struct {
+	call genericCaller
+	// methodExists reports whether the IDL of service declares method.
+	methodExists func(service string, method string) bool
+	cfg          jsonRPCConfig
+}
+
+/**
+ * Creates the JSON-RPC server.
+ *
+ * @param cfg The jsonrpc section of the config.
+ * @param call The caller batch members and single requests are dispatched to.
+ *
+ * @return An error if the batch size or the number of workers is not positive.
+ */
+func newJSONRPCServer(cfg jsonRPCConfig, call genericCaller) (*jsonRPCServer, error) {
+	if cfg.MaxBatch <= 0 || cfg.Workers <= 0 {
+		return nil, fmt.Errorf("jsonrpc.maxBatch and jsonrpc.workers must be positive, got %d and %d", cfg.MaxBatch, cfg.Workers)
+	}
+	return &jsonRPCServer{call: call, methodExists: idlDeclares, cfg: cfg}, nil
+}
+
+// idlDeclares looks a method up in the service's IDL file.
+func idlDeclares(service string, method string) bool {
+	doc, err := parsedIDLs.get(idlPathFor(service))
+	if err != nil {
+		return false
+	}
+	for _, svc := range doc.Services {
+		if _, ok := svc.Method(method); ok && svc.Name == service {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Handles a single request object.
+ *
+ * @return The response, or nil for a notification.
+ */
+func (s *jsonRPCServer) handle(ctx context.Context, raw json.RawMessage) *jsonRPCResponse {
+	var req jsonRPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return &jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCInvalidRequest, Message: "invalid request"}, ID: nullID}
+	}
+	if req.ID != nil && !validJSONRPCID(req.ID) {
+		return &jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCInvalidRequest, Message: "id must be a string, number or null"}, ID: nullID}
+	}
+
+	respond := func(result interface{}, rpcErr *jsonRPCError) *jsonRPCResponse {
+		if req.ID == nil {
+			return nil
+		}
+		return &jsonRPCResponse{JSONRPC: "2.0", Result: result, Error: rpcErr, ID: req.ID}
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = nullID
+		}
+		return &jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCInvalidRequest, Message: "invalid request"}, ID: id}
+	}
+
+	dot := strings.LastIndex(req.Method, ".")
+	if dot <= 0 || dot == len(req.Method)-1 {
+		return respond(nil, &jsonRPCError{Code: jsonRPCMethodNotFound, Message: "method must be Service.method"})
+	}
+	service, method := req.Method[:dot], req.Method[dot+1:]
+	if !s.methodExists(service, method) {
+		return respond(nil, &jsonRPCError{Code: jsonRPCMethodNotFound, Message: "method not found"})
+	}
+
+	body := map[string]interface{}{}
+	if len(req.Params) > 0 && !bytes.Equal(req.Params, nullID) {
+		if err := json.Unmarshal(req.Params, &body); err != nil {
+			return respond(nil, &jsonRPCError{Code: jsonRPCInvalidParams, Message: "params must be an object of named arguments"})
+		}
+	}
+
+	resp, err := s.call(ctx, service, method, body)
+	if err != nil {
+		return respond(nil, jsonRPCErrorFrom(err))
+	}
+	if rpcErr := baseRespError(resp); rpcErr != nil {
+		return respond(nil, rpcErr)
+	}
+	return respond(resp, nil)
+}
+
+// handleRecovered is handle for a batch member, turning a panic into an internal error.
+func (s *jsonRPCServer) handleRecovered(ctx context.Context, raw json.RawMessage) (resp *jsonRPCResponse) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp = &jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCInternalError, Message: "internal error"}, ID: nullID}
+		}
+	}()
+	return s.handle(ctx, raw)
+}
+
+func validJSONRPCID(id json.RawMessage) bool {
+	var v interface{}
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+	switch v.(type) {
+	case nil, string, float64:
+		return true
+	}
+	return false
+}
+
+/**
+ * Serves POST /jsonrpc: a single request object or a batch array. Batch members run
+ * concurrently, on up to cfg.Workers goroutines. Notifications get no response; a request or
+ * batch made only of notifications gets 204.
+ */
+func (s *jsonRPCServer) ServeHTTP(ctx context.Context, c *app.RequestContext) {
+	body := bytes.TrimSpace(c.GetRawData())
+	if !json.Valid(body) {
+		c.JSON(consts.StatusOK, jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCParseError, Message: "parse error"}, ID: nullID})
+		return
+	}
+
+	if len(body) == 0 || body[0] != '[' {
+		resp := s.handle(ctx, body)
+		if resp == nil {
+			c.Status(consts.StatusNoContent)
+			return
+		}
+		c.JSON(consts.StatusOK, resp)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+		c.JSON(consts.StatusOK, jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCInvalidRequest, Message: "invalid request"}, ID: nullID})
+		return
+	}
+
+	if len(batch) > s.cfg.MaxBatch {
+		c.JSON(consts.StatusOK, jsonRPCResponse{JSONRPC: "2.0", Error: &jsonRPCError{Code: jsonRPCInvalidRequest, Message: fmt.Sprintf("batch has %d requests, at most %d are allowed", len(batch), s.cfg.MaxBatch)}, ID: nullID})
+		return
+	}
+
+	responses := make([]*jsonRPCResponse, len(batch))
+	workers := s.cfg.Workers
+	if workers > len(batch) {
+		workers = len(batch)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				responses[i] = s.handleRecovered(ctx, batch[i])
+			}
+		}()
+	}
+	for i := range batch {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	out := []*jsonRPCResponse{}
+	for _, resp := range responses {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	if len(out) == 0 {
+		c.Status(consts.StatusNoContent)
+		return
+	}
+	c.JSON(consts.StatusOK, out)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+)
+
+func newJSONRPCEngine(calls *int32) *route.Engine {
+	rpc := &jsonRPCServer{
+		methodExists: idlDeclares,
+		cfg:          jsonRPCConfig{MaxBatch: 4, Workers: 2},
+		call: func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+			atomic.AddInt32(calls, 1)
+			switch method {
+			case "RetrieveClientData":
+				return map[string]interface{}{"Name": "Ryan", "BaseResp": map[string]interface{}{"StatusCode": 200.0}}, nil
+			case "editReview":
+				return map[string]interface{}{"BaseResp": map[string]interface{}{"StatusCode": 404.0, "StatusMessage": "review not found"}}, nil
+			case "deleteReview":
+				return nil, kerrors.ErrRPCTimeout.WithCause(fmt.Errorf("3s elapsed"))
+			}
+			return map[string]interface{}{}, nil
+		},
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/jsonrpc", rpc.ServeHTTP)
+	return engine
+}
+
+func postJSONRPC(t *testing.T, engine *route.Engine, body string) (int, interface{}) {
+	resp := ut.PerformRequest(engine, consts.MethodPost, "/jsonrpc", &ut.Body{Body: strings.NewReader(body), Len: len(body)})
+	if resp.Code == consts.StatusNoContent {
+		return resp.Code, nil
+	}
+	var out interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &out); err != nil {
+		t.Fatalf("Response is not JSON: %s", resp.Body.String())
+	}
+	return resp.Code, out
+}
+
+func TestJSONRPCSingleCallAndErrors(t *testing.T) {
+	var calls int32
+	engine := newJSONRPCEngine(&calls)
+
+	_, out := postJSONRPC(t, engine, `{"jsonrpc":"2.0","method":"TravelService.RetrieveClientData","params":{"userID":1},"id":7}`)
+	resp := out.(map[string]interface{})
+	if resp["id"] != 7.0 || resp["result"].(map[string]interface{})["Name"] != "Ryan" {
+		t.Fatalf("Unexpected response %v", resp)
+	}
+
+	cases := map[string]float64{
+		`{"jsonrpc":"2.0","method":"ReviewService.editReview","params":{},"id":1}`:   404,
+		`{"jsonrpc":"2.0","method":"ReviewService.deleteReview","params":{},"id":1}`: jsonRPCTimeout,
+		`{"jsonrpc":"2.0","method":"ReviewService.nope","id":1}`:                     jsonRPCMethodNotFound,
+		`{"jsonrpc":"2.0","method":"ReviewService.sendReview","params":[1],"id":1}`:  jsonRPCInvalidParams,
+		`{"method":"ReviewService.sendReview","id":1}`:                               jsonRPCInvalidRequest,
+		`{"jsonrpc":"2.0",`: jsonRPCParseError,
+		`[]`:                jsonRPCInvalidRequest,
+	}
+	for body, code := range cases {
+		_, out := postJSONRPC(t, engine, body)
+		rpcErr, ok := out.(map[string]interface{})["error"].(map[string]interface{})
+		if !ok || rpcErr["code"] != code {
+			t.Errorf("%s: expected error code %v, got %v", body, code, out)
+		}
+	}
+}
+
+func TestJSONRPCBatchesAndNotifications(t *testing.T) {
+	var calls int32
+	engine := newJSONRPCEngine(&calls)
+
+	_, out := postJSONRPC(t, engine, `[
+		{"jsonrpc":"2.0","method":"TravelService.RetrieveClientData","params":{"userID":1},"id":"a"},
+		{"jsonrpc":"2.0","method":"ReviewService.sendReview","params":{"userID":1}},
+		{"jsonrpc":"2.0","method":"ReviewService.editReview","params":{},"id":"b"},
+		1
+	]`)
+	batch := out.([]interface{})
+	if len(batch) != 3 || calls != 3 {
+		t.Fatalf("Expected 3 responses for 3 calls, got %d responses and %d calls: %v", len(batch), calls, batch)
+	}
+	if batch[0].(map[string]interface{})["id"] != "a" || batch[1].(map[string]interface{})["id"] != "b" {
+		t.Fatalf("Responses should keep the order of the requests, got %v", batch)
+	}
+	if _, ok := batch[2].(map[string]interface{})["error"]; !ok {
+		t.Fatal("A non-object batch member should get an invalid request error")
+	}
+
+	code, _ := postJSONRPC(t, engine, `[{"jsonrpc":"2.0","method":"ReviewService.sendReview","params":{}}]`)
+	if code != consts.StatusNoContent {
+		t.Fatalf("A batch of notifications should get no body, got %d", code)
+	}
+}
+
+func TestJSONRPCBatchesAreBounded(t *testing.T) {
+	var inFlight, peak int32
+	rpc := &jsonRPCServer{
+		methodExists: idlDeclares,
+		cfg:          jsonRPCConfig{MaxBatch: 4, Workers: 2},
+		call: func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return map[string]interface{}{}, nil
+		},
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/jsonrpc", rpc.ServeHTTP)
+
+	member := `{"jsonrpc":"2.0","method":"TravelService.RetrieveClientData","params":{},"id":1}`
+	_, out := postJSONRPC(t, engine, "["+strings.Repeat(member+",", 3)+member+"]")
+	if batch := out.([]interface{}); len(batch) != 4 {
+		t.Fatalf("Expected 4 responses, got %v", batch)
+	}
+	if peak != 2 {
+		t.Fatalf("Expected at most 2 members in flight, got %d", peak)
+	}
+
+	_, out = postJSONRPC(t, engine, "["+strings.Repeat(member+",", 4)+member+"]")
+	resp, ok := out.(map[string]interface{})
+	if !ok || resp["error"].(map[string]interface{})["code"] != float64(jsonRPCInvalidRequest) {
+		t.Fatalf("A batch over the limit should get a single invalid request error, got %v", out)
+	}
+
+	if _, err := newJSONRPCServer(jsonRPCConfig{MaxBatch: 10}, nil); err == nil {
+		t.Fatal("Expected an error for a config without workers")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+/**
+ * authConfig protects a route with HS256 JSON Web Tokens. A token is sent as a bearer
+ * Authorization header; only the stream route, whose EventSource and WebSocket clients cannot
+ * set headers, also takes the access_token query parameter. An empty Secret turns auth off.
+ */
+type authConfig struct {
+	Secret string `json:"secret"`
+}
+
+/**
+ * Authenticates a request.
+ *
+ * @return The token's claims (empty when auth is off), and an error when the token is missing or invalid.
+ */
+func authenticateRequest(cfg authConfig, c *app.RequestContext) (map[string]interface{}, error) {
+	return authenticateToken(cfg, requestToken(c))
+}
+
+// requestToken returns the bearer token in a request's Authorization header, empty when it has none.
+func requestToken(c *app.RequestContext) string {
+	return strings.TrimPrefix(string(c.GetHeader("Authorization")), "Bearer ")
+}
+
+/**
+ * Returns the bearer token of a stream request, from the Authorization header or else the
+ * access_token query parameter.
+ *
+ * Tokens in URLs end up in proxy access logs and browser history, so the parameter is only
+ * read here, for clients that cannot set headers. tracingMiddleware leaves it out of spans.
+ */
+func streamToken(c *app.RequestContext) string {
+	if token := requestToken(c); token != "" {
+		return token
+	}
+	return c.Query("access_token")
+}
+
+/**
+ * Authenticates a bearer token.
+ *
+ * @return The token's claims (empty when auth is off), and an error when the token is missing or invalid.
+ */
+func authenticateToken(cfg authConfig, token string) (map[string]interface{}, error) {
+	if cfg.Secret == "" {
+		return map[string]interface{}{}, nil
+	}
+	if token == "" {
+		return nil, fmt.Errorf("a token is required")
+	}
+	return verifyHS256(token, []byte(cfg.Secret), time.Now())
+}
+
+/**
+ * Verifies an HS256-signed JSON Web Token.
+ *
+ * @param token The compact token, header.payload.signature.
+ * @param secret The shared HMAC secret.
+ * @param now The time used to check the exp and nbf claims.
+ *
+ * @return The token's claims, or an error if the signature, algorithm or validity window is wrong.
+ */
+func verifyHS256(token string, secret []byte, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Alg != "HS256" {
+		return nil, errors.New("token must be signed with HS256")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token payload")
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed token payload")
+	}
+	if exp, ok := claims["exp"].(float64); ok && now.Unix() >= int64(exp) {
+		return nil, errors.New("token has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Unix() < int64(nbf) {
+		return nil, errors.New("token is not valid yet")
+	}
+	return claims, nil
+}
+
+/**
+ * Returns when a token's claims stop being valid.
+ *
+ * @return The exp claim as a time, and false when the token does not expire.
+ */
+func claimsExpiry(claims map[string]interface{}) (time.Time, bool) {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
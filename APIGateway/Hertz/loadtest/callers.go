@@ -0,0 +1,97 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/cloudwego/kitex/client"
+	"github.com/cloudwego/kitex/client/genericclient"
+	"github.com/cloudwego/kitex/pkg/generic"
+	"github.com/cloudwego/kitex/transport"
+)
+
+// GatewayCaller posts each call to the gateway's /{service}/{method} route at base, e.g.
+// http://127.0.0.1:8881, with headers such as an Authorization token added to every call.
+// Answers other than 200 are returned as a *StatusError.
+func GatewayCaller(base string, httpClient *http.Client, headers http.Header) Caller {
+	return func(ctx context.Context, service string, method string, body map[string]interface{}) error {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/"+service+"/"+method, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for name, values := range headers {
+			req.Header[name] = values
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		answer, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return &StatusError{Status: resp.StatusCode, Body: string(answer)}
+		}
+		return nil
+	}
+}
+
+// BackendCaller makes generic calls straight to the backends, with one client per service
+// built from its IDL in idlDir. addrs lists the host:port of every instance of each service;
+// calls are balanced across them.
+func BackendCaller(addrs map[string][]string, idlDir string) Caller {
+	var mu sync.Mutex
+	clients := map[string]genericclient.Client{}
+	clientFor := func(service string) (genericclient.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cli, ok := clients[service]; ok {
+			return cli, nil
+		}
+		if len(addrs[service]) == 0 {
+			return nil, fmt.Errorf("no backend address for %s", service)
+		}
+		p, err := generic.NewThriftFileProvider(filepath.Join(idlDir, service+".thrift"))
+		if err != nil {
+			return nil, err
+		}
+		g, err := generic.JSONThriftGeneric(p)
+		if err != nil {
+			return nil, err
+		}
+		cli, err := genericclient.NewClient(service, g,
+			client.WithHostPorts(addrs[service]...),
+			client.WithTransportProtocol(transport.TTHeader))
+		if err != nil {
+			return nil, err
+		}
+		clients[service] = cli
+		return cli, nil
+	}
+
+	return func(ctx context.Context, service string, method string, body map[string]interface{}) error {
+		cli, err := clientFor(service)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		_, err = cli.GenericCall(ctx, method, string(data))
+		return err
+	}
+}
@@ -0,0 +1,217 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/kitex/pkg/generic"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"github.com/cloudwego/kitex/pkg/limit"
+	"github.com/cloudwego/kitex/server"
+	"github.com/cloudwego/kitex/server/genericserver"
+)
+
+func TestRender(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	got, err := Render(map[string]interface{}{
+		"userID": "${int:5:5}",
+		"Msg":    "review ${seq} from ${choice:Lisbon}",
+		"tags":   []interface{}{"${string:4}", true},
+	}, 7, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := got.(map[string]interface{})
+	if body["userID"] != int64(5) || body["Msg"] != "review 7 from Lisbon" {
+		t.Fatalf("Unexpected body %v", body)
+	}
+	if tag := body["tags"].([]interface{})[0].(string); len(tag) != 4 || strings.Trim(tag, "abcdefghijklmnopqrstuvwxyz") != "" {
+		t.Fatalf("Expected 4 random letters, got %q", tag)
+	}
+
+	for _, bad := range []string{"${int:9:1}", "${choice:}", "${string:x}", "${nope}"} {
+		if _, err := Render(bad, 0, rnd); err == nil {
+			t.Fatalf("Expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := &Scenario{Requests: 1, Mix: []Step{{Service: "TravelService", Method: "RetrieveClientData", Weight: 1}}}
+	if err := s.Validate(); err != nil || s.Concurrency != 1 || s.Timeout != Duration(5*time.Second) {
+		t.Fatalf("Expected defaults to be filled in, got %+v %v", s, err)
+	}
+	for _, bad := range []*Scenario{
+		{Mix: s.Mix},
+		{Requests: 1},
+		{Requests: 1, Mix: []Step{{Service: "TravelService", Method: "RetrieveClientData"}}},
+		{Requests: 1, Mix: []Step{{Service: "TravelService", Method: "RetrieveClientData", Weight: 1, Body: map[string]interface{}{"userID": "${int}"}}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Fatalf("Expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestRunFollowsTheMix(t *testing.T) {
+	s := &Scenario{Requests: 400, Concurrency: 8, Mix: []Step{
+		{Service: "ReviewService", Method: "sendReview", Weight: 3, Body: map[string]interface{}{"Msg": "hi", "userID": "${seq}"}},
+		{Service: "TravelService", Method: "RetrieveClientData", Weight: 1, Body: map[string]interface{}{"userID": "${int:1:10}"}},
+	}}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	seen := map[interface{}]bool{}
+	report := Run(context.Background(), s, func(ctx context.Context, service string, method string, body map[string]interface{}) error {
+		if method == "sendReview" {
+			mu.Lock()
+			seen[body["userID"]] = true
+			mu.Unlock()
+			return nil
+		}
+		if body["userID"].(int64) > 5 {
+			return errors.New("invalid inputs: userID too large")
+		}
+		return nil
+	})
+
+	if report.Total.Calls != 400 {
+		t.Fatalf("Expected 400 calls, got %d", report.Total.Calls)
+	}
+	reviews, clients := report.ByMethod["ReviewService.sendReview"], report.ByMethod["TravelService.RetrieveClientData"]
+	if reviews.Calls < 240 || reviews.Calls > 360 || reviews.Calls+clients.Calls != 400 {
+		t.Fatalf("Expected about 3 sendReview calls per RetrieveClientData call, got %d and %d", reviews.Calls, clients.Calls)
+	}
+	if len(seen) != reviews.Calls {
+		t.Fatalf("Expected every sendReview to get its own ${seq}, got %d for %d calls", len(seen), reviews.Calls)
+	}
+	if clients.Errors[ErrInvalidInputs] == 0 || clients.Failed() != report.Total.Failed() {
+		t.Fatalf("Expected invalid inputs on RetrieveClientData only, got %v and %v", clients.Errors, report.Total.Errors)
+	}
+}
+
+func TestRunPacesToTheRate(t *testing.T) {
+	s := &Scenario{Duration: Duration(500 * time.Millisecond), Rate: 40, Concurrency: 4, Mix: []Step{{Service: "TravelService", Method: "GetAllTravelDestinations", Weight: 1}}}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	report := Run(context.Background(), s, func(ctx context.Context, service string, method string, body map[string]interface{}) error {
+		return nil
+	})
+	if report.Total.Calls < 15 || report.Total.Calls > 25 {
+		t.Fatalf("Expected about 20 calls in half a second at 40/s, got %d", report.Total.Calls)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	s := newStats()
+	for i := 1; i <= 100; i++ {
+		s.add(time.Duration(i)*time.Millisecond, nil)
+	}
+	if s.Percentile(50) != 50*time.Millisecond || s.Percentile(99) != 99*time.Millisecond || s.Percentile(100) != 100*time.Millisecond {
+		t.Fatalf("Unexpected percentiles %v %v %v", s.Percentile(50), s.Percentile(99), s.Percentile(100))
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := map[string]error{
+		ErrGatewayRateLimit: &StatusError{Status: 400, Body: "request over limit: ReviewService is over its rate limit"},
+		ErrBackendMaxQPS:    &StatusError{Status: 400, Body: kerrors.ErrQPSOverLimit.Error()},
+		ErrNetwork:          errors.New("remote or network error[remote=127.0.0.1:8888]: default codec read failed: EOF"),
+		ErrInvalidInputs:    &StatusError{Status: 400, Body: "invalid inputs: userID is required"},
+		ErrServiceMissing:   &StatusError{Status: 400, Body: "service name not found"},
+		ErrTimeout:          context.DeadlineExceeded,
+		ErrHTTP + "_502":    &StatusError{Status: 502, Body: "bad gateway"},
+		ErrOther:            errors.New("something else"),
+	}
+	for want, err := range cases {
+		if got := Classify(err); got != want {
+			t.Fatalf("Classify(%v) = %s, expected %s", err, got, want)
+		}
+	}
+	if got := Classify(&StatusError{Status: 429, Body: "rate limit exceeded"}); got != ErrGatewayRateLimit {
+		t.Fatalf("Expected a 429 to be a gateway rate limit, got %s", got)
+	}
+	if got := Classify(kerrors.ErrConnOverLimit); got != ErrBackendMaxQPS {
+		t.Fatalf("Expected Kitex's connection limit to be a backend limit, got %s", got)
+	}
+	if got := Classify(errors.New("write tcp 127.0.0.1:5000: connection reset by peer")); got != ErrNetwork {
+		t.Fatalf("Expected a reset connection to be a network error, got %s", got)
+	}
+}
+
+func TestGatewayCallerSendsHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	headers := http.Header{"Authorization": {"Bearer t0ken"}, "X-Env": {"staging"}}
+	if err := GatewayCaller(srv.URL, srv.Client(), headers)(context.Background(), "ReviewService", "sendReview", map[string]interface{}{}); err != nil {
+		t.Fatalf("Expected the call to be authorised, got %v", err)
+	}
+	if got.Get("X-Env") != "staging" || got.Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected every configured header to be sent, got %v", got)
+	}
+	err := GatewayCaller(srv.URL, srv.Client(), nil)(context.Background(), "ReviewService", "sendReview", map[string]interface{}{})
+	if Classify(err) != ErrHTTP+"_401" {
+		t.Fatalf("Expected a 401 without the header, got %v", err)
+	}
+}
+
+type okHandler struct{}
+
+func (*okHandler) GenericCall(ctx context.Context, method string, request interface{}) (interface{}, error) {
+	return `{"action": "ok"}`, nil
+}
+
+// The backend's Kitex server limits QPS as RPCBackend's servers do, with a lower MaxQPS. Over
+// TTHeader it closes the connection of calls over the limit, which only shows as a network error.
+func TestBackendMaxQPSIsReportedAsNetworkErrors(t *testing.T) {
+	p, err := generic.NewThriftFileProvider("../thriftFiles/ReviewService.thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := generic.JSONThriftGeneric(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr := genericserver.NewServer(&okHandler{}, g, server.WithListener(ln),
+		server.WithLimit(&limit.Option{MaxConnections: 10000, MaxQPS: 100}))
+	go svr.Run()
+	defer svr.Stop()
+
+	s := &Scenario{Requests: 300, Concurrency: 4, Mix: []Step{
+		{Service: "ReviewService", Method: "deleteReview", Weight: 1, Body: map[string]interface{}{"reviewID": "${seq}"}},
+	}}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	report := Run(context.Background(), s, BackendCaller(map[string][]string{"ReviewService": {ln.Addr().String()}}, "../thriftFiles"))
+	stats := report.ByMethod["ReviewService.deleteReview"]
+	if stats.Errors[ErrNetwork] == 0 || stats.Calls-stats.Failed() == 0 {
+		t.Fatalf("Expected some calls to pass and some to hit MaxQPS, got %d calls and %v", stats.Calls, stats.Errors)
+	}
+	if stats.Failed() != stats.Errors[ErrNetwork] {
+		t.Fatalf("Expected calls over MaxQPS to be network errors only, got %v", stats.Errors)
+	}
+}
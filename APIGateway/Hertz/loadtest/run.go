@@ -0,0 +1,221 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Caller makes one call and returns its error, nil when it succeeded.
+type Caller func(ctx context.Context, service string, method string, body map[string]interface{}) error
+
+// Error kinds, the keys of Stats.Errors.
+const (
+	// ErrGatewayRateLimit is a call the gateway refused because of a rate limit in its policies
+	// or routes.
+	ErrGatewayRateLimit = "gateway_rate_limit"
+	// ErrBackendMaxQPS is a call a backend refused with Kitex's error for the MaxQPS or
+	// MaxConnections limit of its server. Over TTHeader, Kitex refuses such calls by closing the
+	// connection instead; that cannot be told apart from other connection failures, so those
+	// calls count as ErrNetwork.
+	ErrBackendMaxQPS  = "backend_max_qps"
+	ErrTimeout        = "timeout"
+	ErrInvalidInputs  = "invalid_inputs"
+	ErrServiceMissing = "service_not_found"
+	// ErrNetwork is any other failure to reach the target or the backend, including a
+	// connection closed or reset before the response was read.
+	ErrNetwork = "network"
+	// ErrHTTP is an unexpected HTTP status from the gateway; the kind is followed by the status,
+	// e.g. "http_502".
+	ErrHTTP  = "http"
+	ErrOther = "other"
+)
+
+// StatusError is a non-200 answer from the gateway.
+type StatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return e.Body
+}
+
+// Classify returns the kind of an error from a Caller. The gateway passes backend errors on
+// in its answer, so the same kinds apply whether the load goes through the gateway or not.
+func Classify(err error) string {
+	var status *StatusError
+	if errors.As(err, &status) && status.Status == 429 {
+		return ErrGatewayRateLimit
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	// Kitex's ErrQPSOverLimit and ErrConnOverLimit also start with "request over limit", so they
+	// are checked first. "to many connections" is how Kitex spells it.
+	case strings.Contains(msg, "request too frequent"), strings.Contains(msg, "to many connections"):
+		return ErrBackendMaxQPS
+	case strings.Contains(msg, "request over limit"), strings.Contains(msg, "rate limit"):
+		return ErrGatewayRateLimit
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "deadline exceeded"):
+		return ErrTimeout
+	case strings.Contains(msg, "invalid inputs"):
+		return ErrInvalidInputs
+	case strings.Contains(msg, "service name not found"):
+		return ErrServiceMissing
+	case strings.Contains(msg, "remote or network error"), strings.Contains(msg, "connection refused"),
+		strings.Contains(msg, ": eof"), strings.Contains(msg, "unexpected eof"), strings.Contains(msg, "connection reset by peer"):
+		return ErrNetwork
+	}
+	if status != nil && status.Status != 400 {
+		return ErrHTTP + "_" + strconv.Itoa(status.Status)
+	}
+	return ErrOther
+}
+
+// Stats are the outcomes of the calls to one method, or to all of them.
+type Stats struct {
+	Calls  int
+	Errors map[string]int
+	// latencies of every call, failed ones included.
+	latencies []time.Duration
+}
+
+func newStats() *Stats {
+	return &Stats{Errors: map[string]int{}}
+}
+
+func (s *Stats) add(latency time.Duration, err error) {
+	s.Calls++
+	s.latencies = append(s.latencies, latency)
+	if err != nil {
+		s.Errors[Classify(err)]++
+	}
+}
+
+// Failed returns the number of calls that returned an error.
+func (s *Stats) Failed() int {
+	n := 0
+	for _, count := range s.Errors {
+		n += count
+	}
+	return n
+}
+
+// Percentile returns the latency below which p percent of the calls finished, 0 without calls.
+func (s *Stats) Percentile(p float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// Nearest rank: the smallest latency with at least p percent of the calls at or below it.
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// Report is the outcome of a load test.
+type Report struct {
+	Elapsed time.Duration
+	Total   *Stats
+	// ByMethod holds the stats of each "Service.method".
+	ByMethod map[string]*Stats
+}
+
+// Throughput returns the calls made per second.
+func (r *Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Total.Calls) / r.Elapsed.Seconds()
+}
+
+// Run makes the scenario's calls with call until its duration has passed or its requests have
+// been made, or ctx is done. Calls are spread over the mix by weight and paced to the
+// scenario's rate; a call that cannot start on time because every worker is busy starts as
+// soon as one is free.
+func Run(ctx context.Context, s *Scenario, call Caller) *Report {
+	if s.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Duration))
+		defer cancel()
+	}
+
+	type job struct {
+		step *Step
+		body map[string]interface{}
+	}
+	jobs := make(chan job)
+	report := &Report{Total: newStats(), ByMethod: map[string]*Stats{}}
+	for i := range s.Mix {
+		report.ByMethod[s.Mix[i].Service+"."+s.Mix[i].Method] = newStats()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < s.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				callCtx, cancel := context.WithTimeout(context.Background(), time.Duration(s.Timeout))
+				start := time.Now()
+				err := call(callCtx, j.step.Service, j.step.Method, j.body)
+				latency := time.Since(start)
+				cancel()
+
+				mu.Lock()
+				report.Total.add(latency, err)
+				report.ByMethod[j.step.Service+"."+j.step.Method].add(latency, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	total := s.totalWeight()
+	start := time.Now()
+	for seq := 0; s.Requests == 0 || seq < s.Requests; seq++ {
+		if s.Rate > 0 {
+			due := start.Add(time.Duration(float64(seq) / s.Rate * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		step := s.pick(rnd.Intn(total))
+		// Validate has rendered every body once, so rendering cannot fail here.
+		rendered, _ := Render(step.Body, seq, rnd)
+		body, _ := rendered.(map[string]interface{})
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+		select {
+		case jobs <- job{step: step, body: body}:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	return report
+}
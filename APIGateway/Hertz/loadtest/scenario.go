@@ -0,0 +1,213 @@
+// Package loadtest generates load against the gateway's POST /{service}/{method} routes, or
+// against the backends directly, from a scenario file, and reports throughput, latency
+// percentiles and errors by kind.
+package loadtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration written in a scenario as a string such as "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Step is one kind of call in a scenario's mix.
+type Step struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+	// Weight is the step's share of the calls relative to the other steps.
+	Weight int `json:"weight"`
+	// Body is the request body, a template: see Render.
+	Body map[string]interface{} `json:"body"`
+}
+
+// Scenario describes a load test.
+type Scenario struct {
+	// Duration stops the test after this long; Requests stops it after that many calls.
+	// At least one of them must be set.
+	Duration Duration `json:"duration"`
+	Requests int      `json:"requests"`
+	// Rate is the target number of calls per second across all workers, 0 for as many as the
+	// workers can make.
+	Rate float64 `json:"rate"`
+	// Concurrency is the number of workers making calls, 1 when unset.
+	Concurrency int `json:"concurrency"`
+	// Timeout is the timeout of each call, 5s when unset.
+	Timeout Duration `json:"timeout"`
+	Mix     []Step   `json:"mix"`
+}
+
+// Load reads and validates a scenario file.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Validate fills in defaults and checks every step, rendering each body once.
+func (s *Scenario) Validate() error {
+	if s.Duration <= 0 && s.Requests <= 0 {
+		return errors.New("set duration or requests")
+	}
+	if s.Rate < 0 || s.Requests < 0 || s.Concurrency < 0 {
+		return errors.New("rate, requests and concurrency must not be negative")
+	}
+	if s.Concurrency == 0 {
+		s.Concurrency = 1
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = Duration(5 * time.Second)
+	}
+	if len(s.Mix) == 0 {
+		return errors.New("mix needs at least one step")
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i, step := range s.Mix {
+		if step.Service == "" || step.Method == "" {
+			return fmt.Errorf("mix[%d] needs a service and a method", i)
+		}
+		if step.Weight <= 0 {
+			return fmt.Errorf("mix[%d] (%s.%s) needs a positive weight", i, step.Service, step.Method)
+		}
+		if _, err := Render(step.Body, 0, rnd); err != nil {
+			return fmt.Errorf("mix[%d] (%s.%s): %w", i, step.Service, step.Method, err)
+		}
+	}
+	return nil
+}
+
+// pick returns the step for a number drawn uniformly from [0, total weight).
+func (s *Scenario) pick(n int) *Step {
+	for i := range s.Mix {
+		if n < s.Mix[i].Weight {
+			return &s.Mix[i]
+		}
+		n -= s.Mix[i].Weight
+	}
+	return &s.Mix[len(s.Mix)-1]
+}
+
+func (s *Scenario) totalWeight() int {
+	total := 0
+	for _, step := range s.Mix {
+		total += step.Weight
+	}
+	return total
+}
+
+var placeholder = regexp.MustCompile(`\$\{([a-z]+)(?::([^}]*))?\}`)
+
+// Render fills in the placeholders of a body template for the seq-th call:
+//   - ${seq} is the call's sequence number, starting at 0;
+//   - ${int:MIN:MAX} is a random integer from MIN to MAX inclusive;
+//   - ${choice:a|b|c} is one of the listed strings;
+//   - ${string:N} is N random lowercase letters.
+//
+// A string that is exactly one placeholder takes its type, so "${int:1:100}" can fill an i32
+// field; placeholders inside longer strings are written as text.
+func Render(v interface{}, seq int, rnd *rand.Rand) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		if m := placeholder.FindStringSubmatch(val); m != nil && m[0] == val {
+			return generate(m[1], m[2], seq, rnd)
+		}
+		var firstErr error
+		out := placeholder.ReplaceAllStringFunc(val, func(ref string) string {
+			m := placeholder.FindStringSubmatch(ref)
+			generated, err := generate(m[1], m[2], seq, rnd)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return ref
+			}
+			return fmt.Sprint(generated)
+		})
+		return out, firstErr
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			rendered, err := Render(item, seq, rnd)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			rendered, err := Render(item, seq, rnd)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func generate(kind string, arg string, seq int, rnd *rand.Rand) (interface{}, error) {
+	switch kind {
+	case "seq":
+		return seq, nil
+	case "int":
+		bounds := strings.Split(arg, ":")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("${int:%s} needs MIN:MAX", arg)
+		}
+		min, errMin := strconv.ParseInt(bounds[0], 10, 64)
+		max, errMax := strconv.ParseInt(bounds[1], 10, 64)
+		if errMin != nil || errMax != nil || max < min {
+			return nil, fmt.Errorf("${int:%s} needs integers MIN <= MAX", arg)
+		}
+		return min + rnd.Int63n(max-min+1), nil
+	case "choice":
+		if arg == "" {
+			return nil, errors.New("${choice} needs at least one option")
+		}
+		options := strings.Split(arg, "|")
+		return options[rnd.Intn(len(options))], nil
+	case "string":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("${string:%s} needs a length", arg)
+		}
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte('a' + rnd.Intn(26))
+		}
+		return string(b), nil
+	}
+	return nil, fmt.Errorf("unknown placeholder ${%s}", kind)
+}
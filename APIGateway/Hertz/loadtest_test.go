@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+
+	"hertz_demo/loadtest"
+)
+
+func TestLoadThroughTheGatewayReportsRateLimits(t *testing.T) {
+	policies := gatewayPolicies.all()
+	defer gatewayPolicies.replace(policies)
+	if err := gatewayPolicies.replace(map[string]servicePolicy{
+		"ReviewService": {RateLimit: rateLimitConfig{QPS: 1, Burst: 5}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/:serviceName/:methodName", genericPostHandler)
+	// The same as loadtest.GatewayCaller, through the route instead of a listening gateway.
+	call := func(ctx context.Context, service string, method string, body map[string]interface{}) error {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		w := ut.PerformRequest(engine, consts.MethodPost, "/"+service+"/"+method, &ut.Body{Body: strings.NewReader(string(data)), Len: len(data)})
+		if w.Code != consts.StatusOK {
+			return &loadtest.StatusError{Status: w.Code, Body: w.Body.String()}
+		}
+		return nil
+	}
+
+	s := &loadtest.Scenario{Requests: 40, Concurrency: 4, Mix: []loadtest.Step{
+		{Service: "ReviewService", Method: "sendReview", Weight: 1, Body: map[string]interface{}{"Msg": "review ${seq}", "userID": "${int:1:100}"}},
+		{Service: "TravelService", Method: "RetrieveClientData", Weight: 1, Body: map[string]interface{}{"userID": "${int:1:100}"}},
+	}}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	report := loadtest.Run(context.Background(), s, call)
+
+	reviews, clients := report.ByMethod["ReviewService.sendReview"], report.ByMethod["TravelService.RetrieveClientData"]
+	if clients.Failed() != 0 {
+		t.Fatalf("Expected RetrieveClientData, which has no limit, to succeed, got %v", clients.Errors)
+	}
+	if limited := reviews.Errors[loadtest.ErrGatewayRateLimit]; limited == 0 || limited != reviews.Failed() || reviews.Calls-limited < 5 {
+		t.Fatalf("Expected the burst of sendReview calls to pass and the rest to be rate limited, got %d calls and %v", reviews.Calls, reviews.Errors)
+	}
+}
@@ -5,33 +5,46 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 	"github.com/cloudwego/kitex/client"
 	"github.com/cloudwego/kitex/client/genericclient"
 	"github.com/cloudwego/kitex/pkg/generic"
-	"github.com/cloudwego/kitex/pkg/loadbalance"
-	"github.com/kitex-contrib/registry-nacos/resolver"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"github.com/cloudwego/kitex/transport"
 	"github.com/nacos-group/nacos-sdk-go/clients"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
 	"github.com/nacos-group/nacos-sdk-go/vo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"hertz_demo/idl"
 )
 
 type ctxKey int
 
 const (
 	ctxConsistentKey ctxKey = iota
-	serviceRegistryIP = "http://127.0.0.1:8848"
+	thriftDirectory = "./thriftFiles"
 
 )
 
+// serviceRegistryIP is the Nacos server's base URL, set from nacos.serverAddr in the config.
+var serviceRegistryIP = "http://127.0.0.1:8848"
+
 /**
  * Retrieves a list of service hosts from a service registry.
  *
@@ -43,7 +56,22 @@ const (
  *                            are interface{} values containing the host information.
  */
 func getServiceHosts(hosts string, serviceRegistryIP string) (map[string]interface{}){
-		route := fmt.Sprintf("%s/nacos/v1/ns/instance/list?serviceName=%s",serviceRegistryIP,hosts)
+	return getServiceHostsIn(hosts, serviceRegistryIP, gatewayCfg.Nacos.Namespace)
+}
+
+/**
+ * Retrieves a list of service hosts from one namespace of the service registry, in the
+ * group the gateway config assigns to the service.
+ *
+ * @param hosts               The name of the service to query for hosts.
+ * @param serviceRegistryIP   The IP address of the service registry.
+ * @param namespace           The Nacos namespace to look in.
+ *
+ * @return                    The registry's instance list, as for getServiceHosts.
+ */
+func getServiceHostsIn(hosts string, serviceRegistryIP string, namespace string) (map[string]interface{}){
+		route := fmt.Sprintf("%s/nacos/v1/ns/instance/list?serviceName=%s&namespaceId=%s&groupName=%s",
+			serviceRegistryIP, url.QueryEscape(hosts), url.QueryEscape(namespace), url.QueryEscape(gatewayCfg.Nacos.groupFor(hosts)))
 		response, err := http.Get(route)
 		var jsonData map[string]interface{} = nil
 		if err != nil {
@@ -70,64 +98,158 @@ func getServiceHosts(hosts string, serviceRegistryIP string) (map[string]interfa
 }
 
 
+// Errors from building a client or a request, which callers such as /jsonrpc tell apart.
+var (
+	errRegistryUnavailable = errors.New("error converting into list")
+	errServiceNotFound     = errors.New("service name not found")
+	errInvalidInputs       = errors.New("invalid inputs")
+)
+
+// nacosCacheDir is where the Nacos SDK caches what it fetched, and where the gateway keeps its last-known-good config.
+const nacosCacheDir = "/tmp/nacos/cache"
+
 /**
+ * Converts a Nacos base URL such as http://127.0.0.1:8848 into the SDK's server config.
  *
- *Initializes a generic client using the given generic type and returns a client instance.
- * This function initializes a generic client by configuring various parameters such as server configuration,
- * client configuration, load balancing algorithm, resolver, and RPC timeout. It returns a client instance
- * along with an error, if any.
- * @param g The generic type to be used for the client.
- * @return The initialized client instance and an error, if any.
+ * @param addr The base URL, without the /nacos context path.
+ * @return The server config, or an error if addr has no host or port.
+ */
+func nacosServerConfig(addr string) (constant.ServerConfig, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return constant.ServerConfig{}, fmt.Errorf("invalid Nacos address %q: %w", addr, err)
+	}
+	port, err := strconv.ParseUint(u.Port(), 10, 64)
+	if err != nil || u.Hostname() == "" {
+		return constant.ServerConfig{}, fmt.Errorf("Nacos address %q needs a host and port", addr)
+	}
+	sc := constant.NewServerConfig(u.Hostname(), port)
+	if u.Scheme != "" {
+		sc.Scheme = u.Scheme
+	}
+	return *sc, nil
+}
+
+/**
+ * Returns the settings the gateway's Nacos naming and config clients connect with.
  *
-**/
-func initialiseClient(g generic.Generic,serviceName string) (genericclient.Client, error) {
-	sc := []constant.ServerConfig{
-		*constant.NewServerConfig("127.0.0.1", 8848),
+ * @param namespace The Nacos namespace the client works in.
+ * @return The server and client config for clients.NewNamingClient and clients.NewConfigClient,
+ *         or an error if serviceRegistryIP is not a valid address.
+ */
+func nacosClientParam(namespace string) (vo.NacosClientParam, error) {
+	srv, err := nacosServerConfig(serviceRegistryIP)
+	if err != nil {
+		return vo.NacosClientParam{}, err
 	}
+	sc := []constant.ServerConfig{srv}
 
 	// the nacos client config
 	cc := constant.ClientConfig{
-		NamespaceId:         "public",
+		NamespaceId:         namespace,
 		TimeoutMs:           5000,
 		NotLoadCacheAtStart: true,
 		LogDir:              "/tmp/nacos/log",
-		CacheDir:            "/tmp/nacos/cache",
+		CacheDir:            nacosCacheDir,
 		LogLevel:            "info",
 	}
 
-	resolvercli, err := clients.NewNamingClient(
-		vo.NacosClientParam{
-			ClientConfig:  &cc,
-			ServerConfigs: sc,
-		},
-	)
+	return vo.NacosClientParam{
+		ClientConfig:  &cc,
+		ServerConfigs: sc,
+	}, nil
+}
+
+/**
+ *
+ *Initializes a generic client using the given generic type and returns a client instance.
+ * This function initializes a generic client by configuring various parameters such as server configuration,
+ * client configuration, load balancing algorithm, resolver, and RPC timeout. It returns a client instance
+ * along with an error, if any.
+ * @param g The generic type to be used for the client.
+ * @return The initialized client instance and an error, if any.
+ *
+**/
+func initialiseClient(g generic.Generic,serviceName string) (genericclient.Client, error) {
+	return initialiseClientIn(g, serviceName, gatewayCfg.Nacos.Namespace)
+}
+
+/**
+ * Initializes a generic client that discovers the service in one Nacos namespace, using the
+ * group and cluster preference the gateway config sets for the service.
+ *
+ * @param g The generic type to be used for the client.
+ * @param serviceName The service to call.
+ * @param namespace The Nacos namespace to discover instances in.
+ * @return The initialized client instance and an error, if any.
+ */
+func initialiseClientIn(g generic.Generic, serviceName string, namespace string) (genericclient.Client, error) {
+	return initialiseClientFor(g, serviceName, namespace, false)
+}
+
+/**
+ * Initializes a generic client for either the primary or the shadow instances of a service.
+ * Shadow clients neither count against the service's rate limit nor trip its circuit breakers.
+ *
+ * @param g The generic type to be used for the client.
+ * @param serviceName The service to call.
+ * @param namespace The Nacos namespace to discover instances in.
+ * @param shadow Whether to call the instances registered with shadow=true instead of the others.
+ * @return The initialized client instance and an error, if any.
+ */
+func initialiseClientFor(g generic.Generic, serviceName string, namespace string, shadow bool) (genericclient.Client, error) {
+	param, err := nacosClientParam(namespace)
+	if err != nil {
+		panic(err)
+	}
+	resolvercli, err := clients.NewNamingClient(param)
 	if err != nil {
 		panic(err)
 	}
 
-	//NewWeightedBalancer creates a loadbalancer using weighted-round-robin algorithm.
-	lb := loadbalance.NewWeightedBalancer()
+	//the current policy, which the admin API can change, decides the timeout, balancer and limit
+	policy := gatewayPolicies.get(serviceName)
+	if !shadow && !gatewayPolicies.admit(serviceName, time.Now()) {
+		return nil, kerrors.ErrOverlimit.WithCause(fmt.Errorf("%s is over its rate limit", serviceName))
+	}
+	lb := policy.balancer()
 
-	hostList,ok := getServiceHosts(serviceName,serviceRegistryIP)["hosts"].([]interface{})
+	hostList,ok := getServiceHostsIn(serviceName,serviceRegistryIP,namespace)["hosts"].([]interface{})
 	if(!ok){
-		return nil, errors.New(("error converting into list"))
+		return nil, errRegistryUnavailable
 	}
 
 	if len(hostList) == 0{
-		return nil, errors.New(("service name not found"))
+		return nil, errServiceNotFound
 	}
 
 
-	//client specifies the endpoint for the rpc backend
-	cli, err := genericclient.NewClient(
-		serviceName,
-		g,
+	opts := []client.Option{
 		//we dont need to specify port names anymore as we are now using service discovery
 		// client.WithHostPorts("0.0.0.0:8888", "0.0.0.0:8889"),
 		client.WithLoadBalancer(lb),
-		client.WithResolver(resolver.NewNacosResolver(resolvercli)),
-		client.WithRPCTimeout(time.Second*3),
-	)
+		client.WithResolver(tracingResolver{idlCheckResolver{
+			Resolver: shadowResolver{drainingResolver{clusterResolver{
+				cli:       resolvercli,
+				namespace: namespace,
+				group:     gatewayCfg.Nacos.groupFor(serviceName),
+				selection: gatewayCfg.Nacos.Services[serviceName],
+			}}, shadow},
+			mode:    gatewayCfg.InstanceCheck.IDLMismatch,
+			idlPath: idlPathFor,
+		}}),
+		client.WithRPCTimeout(time.Duration(policy.Timeout)),
+		//TTHeader carries the trace context to the backend as metainfo
+		client.WithTransportProtocol(transport.TTHeader),
+	}
+	if !shadow {
+		opts = append(opts, client.WithMiddleware(serviceBreakerMW))
+	}
+	//mutual TLS to the backends when enabled in the config
+	opts = append(opts, backendTLSOptions()...)
+
+	//client specifies the endpoint for the rpc backend
+	cli, err := genericclient.NewClient(serviceName, g, opts...)
 
 	return cli, err
 }
@@ -137,7 +259,8 @@ func initialiseClient(g generic.Generic,serviceName string) (genericclient.Clien
  *
  * This function takes the method name and the response data as input, and formats the response
  * accordingly in JSON format. It checks the method name and response data to ensure that all
- * required fields are present before creating the JSON response.
+ * required fields are present before creating the JSON response. Fields are passed through
+ * under their IDL names, and the message may also be sent as "data", as the first clients did.
  *
  * @param[in] methodName The name of the method for which the response is being formatted.
  * @param[in] response A map containing the response data in key-value pairs.
@@ -147,38 +270,100 @@ func initialiseClient(g generic.Generic,serviceName string) (genericclient.Clien
  */
 
 func formatResponse( methodName string, response map[string]interface{})(string, error){
-	var jsonResponse string 	
 	fmt.Println(response)
+	request := requestFields(response)
+	var required []string
 	switch methodName {
 	case "sendReview":
-		if(response["data"] == nil || response["userID"] == nil){
-			return "", errors.New("invalid inputs")
-		}
-		jsonResponse = fmt.Sprintf("{\"Msg\":\"%s\",\"userID\": %i}", response["data"], response["userID"])
+		required = []string{"Msg", "userID"}
 	case "editReview":
-		if(response["data"] == nil || response["reviewID"] == nil|| response["postID"] == nil){
-			return "", errors.New("invalid inputs")
-		}
-		jsonResponse = fmt.Sprintf("{\"Msg\":\"%s\",\"reviewID\": %i,\"postID\": %i}", response["data"], response["reviewID"], response["postID"])
+		required = []string{"Msg", "reviewID", "postID"}
 	case "deleteReview":
-		if(response["reviewID"] == nil){
-			return "", errors.New("invalid inputs")
+		required = []string{"reviewID"}
+	case "RetrieveClientData", "GetAllTravelDestinations":
+		required = []string{"userID"}
+	}
+	for _, field := range required {
+		if request[field] == nil {
+			return "", errInvalidInputs
 		}
-		jsonResponse = fmt.Sprintf("{\"reviewID\":%i,}", response["reviewID"])
-	case "RetrieveClientData":
-		if(response["userID"] == nil){
-			return "", errors.New("invalid inputs")
+	}
+	jsonResponse, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidInputs, err)
+	}
+	return string(jsonResponse), nil;
+}
+
+// requestFields copies a request body, renaming the legacy "data" key to the IDL's "Msg".
+func requestFields(body map[string]interface{}) map[string]interface{} {
+	request := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		request[k] = v
+	}
+	if data, ok := request["data"]; ok {
+		delete(request, "data")
+		if _, ok := request["Msg"]; !ok {
+			request["Msg"] = data
 		}
-		jsonResponse = fmt.Sprintf("{\"userID\":%i}", response["userID"])
-	case "GetAllTravelDestinations":
-		if(response["userID"] == nil){
-			return "", errors.New("invalid inputs")
+	}
+	return request
+}
+
+/**
+ * Checks a request body against the request struct the IDL declares for the method.
+ *
+ * @param doc The parsed IDL of the service.
+ * @param serviceName The service being called.
+ * @param methodName The method being called.
+ * @param body The decoded JSON body.
+ * @return An error wrapping errInvalidInputs that names the first missing or mistyped field.
+ */
+func checkRequestBody(doc *idl.Document, serviceName string, methodName string, body map[string]interface{}) error {
+	for _, svc := range doc.Services {
+		if svc.Name != serviceName {
+			continue
+		}
+		m, ok := svc.Method(methodName)
+		if !ok || m.Request() == nil {
+			return nil
+		}
+		if err := idl.Check(m.Request(), requestFields(body)); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidInputs, err)
 		}
-		jsonResponse = fmt.Sprintf("{\"userID\":%i,}", response["userID"])
-	default:
-		jsonResponse = fmt.Sprintf("{\"Msg\":\"%s\",}", response["data"])
 	}
-	return jsonResponse, nil;
+	return nil
+}
+
+/**
+ * Returns the path of the Thrift IDL file for a service.
+ *
+ * @param serviceName The name of the service, which is also the IDL file name.
+ * @return The path of the IDL inside the thriftFiles directory.
+ */
+func idlPathFor(serviceName string) string {
+	return fmt.Sprintf("%s/%s.thrift", thriftDirectory, serviceName)
+}
+
+// genericCaller performs one generic call; features that fan out or wrap calls take one so tests can stub it.
+type genericCaller func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error)
+
+/**
+ * Calls a method through the IDL-driven generic client, the same path the POST route uses.
+ * Identical in-flight calls to methods that opted in to coalescing share one backend call,
+ * which may also be mirrored to shadow instances.
+ */
+func callGeneric(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+	return gatewayCoalescer.Wrap(gatewayShadow.Wrap(func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		return makeThriftCall(idlPathFor(service), body, service, method, ctx)
+	}))(ctx, service, method, body)
+}
+
+/**
+ * Calls a method on the shadow instances of a service, for the shadow mirror.
+ */
+func callShadow(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+	return makeThriftCall(idlPathFor(service), body, service, method, context.WithValue(ctx, ctxShadowKey, true))
 }
 
 /**
@@ -194,37 +379,63 @@ func formatResponse( methodName string, response map[string]interface{})(string,
 func makeThriftCall(IDLPath string, response map[string]interface{},serviceName string,methodName string, ctx context.Context) (interface{}, error) {
 	var jsonData map[string]interface{}
 
+	_, idlSpan := startSpan(ctx, "idl.lookup", attribute.String("idl.path", IDLPath))
+
 	p, err := generic.NewThriftFileProvider(IDLPath)
 
 	if err != nil {
 		fmt.Println("error creating thrift file provider")
+		endSpan(idlSpan, err)
 		return 0, err
 	}
 
 	g, err := generic.JSONThriftGeneric(p)
 
 	if err != nil {
+		endSpan(idlSpan, err)
 		return 0, errors.New(("error creating thrift generic"))
 	}
+	endSpan(idlSpan, nil)
 
-	cli, err := initialiseClient(g ,serviceName)
+	// invalid bodies are refused before they take a rate limit token or reach the registry
+	if doc, err := parsedIDLs.get(IDLPath); err == nil {
+		if err := checkRequestBody(doc, serviceName, methodName, response); err != nil {
+			return nil, err
+		}
+	}
+
+	message, err := formatResponse(methodName,response)
+	
 
 	if err != nil {
 		return nil, err
 	}
-	
-	message, err := formatResponse(methodName,response)
-	
+
+	_, resolveSpan := startSpan(ctx, "registry.resolve", attribute.String("rpc.service", serviceName))
+
+	cli, err := initialiseClientFor(g, serviceName, requestNamespace(ctx), isShadowCall(ctx))
+
+	endSpan(resolveSpan, err)
 
 	if err != nil {
 		return nil, err
 	}
 
-	ctx = context.WithValue(ctx, ctxConsistentKey, "my key0")
+	ctx = context.WithValue(ctx, ctxConsistentKey, gatewayPolicies.get(serviceName).hashKeyOf(ctx, response))
 	var resp interface{}
 	fmt.Println(methodName)
-	resp, err = cli.GenericCall(ctx, methodName, message)
-	
+
+	ctx, callSpan := otel.Tracer(tracerName).Start(ctx, "GenericCall",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "kitex"),
+			attribute.String("rpc.service", serviceName),
+			attribute.String("rpc.method", methodName),
+		),
+	)
+	resp, err = cli.GenericCall(injectTraceContext(ctx), methodName, message)
+	endSpan(callSpan, err)
+
 	if err != nil {
 		fmt.Println(err)
 		return 0, err
@@ -245,61 +456,202 @@ func makeThriftCall(IDLPath string, response map[string]interface{},serviceName
 
 func main() {
 
-	h := server.Default(server.WithHostPorts("0.0.0.0:8881"))
+	configPath := flag.String("config", "./config.json", "path to the gateway config file")
+	flag.Parse()
+
+	cfg, err := loadGatewayConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gatewayCfg = cfg
+	serviceRegistryIP = gatewayCfg.Nacos.ServerAddr
+	if _, err := nacosServerConfig(serviceRegistryIP); err != nil {
+		log.Fatal(err)
+	}
+	if err := gatewayCfg.InstanceCheck.validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkIDLCompat(gatewayCfg.IDLCompat, thriftDirectory); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gatewayPolicies.replace(gatewayCfg.Policies); err != nil {
+		log.Fatal(err)
+	}
+	gatewayRoutes.store(routePolicy{Routes: gatewayCfg.Routes, Auth: gatewayCfg.Auth})
+
+	if gatewayCfg.DynamicConfig.Enabled {
+		watcher, err := newDynamicConfigWatcher(gatewayCfg.DynamicConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := watcher.start(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	shutdownTracing, err := initTracing(context.Background(), gatewayCfg.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	backendTLS, err = newBackendTLS(gatewayCfg.BackendTLS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	serverOpts := []config.Option{server.WithHostPorts("0.0.0.0:8881")}
+	serverTLS, err := newServerTLS(gatewayCfg.TLS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if serverTLS != nil {
+		serverOpts = append(serverOpts, server.WithTLS(serverTLS))
+	}
+	if gatewayCfg.Security.MaxBodyBytes > 0 {
+		serverOpts = append(serverOpts, server.WithMaxRequestBodySize(gatewayCfg.Security.MaxBodyBytes))
+	}
+	h := server.Default(serverOpts...)
+
+	h.Use(tracingMiddleware())
+	h.Use(namespaceMiddleware(gatewayCfg.Nacos))
+	h.Use(securityHeadersMiddleware(gatewayCfg.Security.Headers))
+	h.Use(corsMiddleware(gatewayCfg.Security.CORS))
+	h.Use(requestLimitsMiddleware(gatewayCfg.Security))
+	h.Use(routeTokenMiddleware())
+	h.Use(requestHeadersMiddleware())
 
-	
 
 	h.GET("/ping", func(ctx context.Context, c *app.RequestContext) {
 
 		c.JSON(consts.StatusOK, utils.H{"message": "hello from api gateway"})
 	})
 
+	h.GET("/healthz", healthzHandler)
+
+	h.GET("/readyz", readyzHandler)
+
+	h.GET("/catalog", catalogHandler)
+
+	h.GET("/openapi.json", openAPIHandler)
+
+	h.GET("/swagger/*filepath", swaggerHandler)
+
 	h.GET("/getServiceHosts/:hosts", func(ctx context.Context, c *app.RequestContext) {
 		hosts := c.Param("hosts")
 		
 		c.JSON(consts.StatusOK, getServiceHosts(hosts,serviceRegistryIP))
 	})
 
-	h.POST("/:serviceName/:methodName", func(ctx context.Context, c *app.RequestContext) {
+	gatewayCache, err = newResponseCache(gatewayCfg.Cache)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gatewayCoalescer = newCoalescer(gatewayCfg.Coalesce)
 
-		serviceName := c.Param("serviceName")
-		
-		methodName := c.Param("methodName")
+	gatewayRecorder, err = newRecorder(gatewayCfg.Recording)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gatewayShadow, err = newShadowMirror(gatewayCfg.Shadow, callShadow)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	h.GET("/metrics", metricsHandler)
 
-		var jsonData map[string]interface{}
+	// Every entrypoint that names its own service and method goes through the route policy.
+	callRouted := gatewayRoutes.guard(callGeneric)
 
-		thriftDirectory := fmt.Sprintf("./thriftFiles/%s.thrift",serviceName)
+	if err := registerCompositeRoutes(h, gatewayCfg.CompositeRoutes, callRouted); err != nil {
+		log.Fatal(err)
+	}
 
-		var IDLPath string = thriftDirectory
+	rpc, err := newJSONRPCServer(gatewayCfg.JSONRPC, callRouted)
+	if err != nil {
+		log.Fatal(err)
+	}
+	h.POST("/jsonrpc", rpc.ServeHTTP)
 
-		//returns data in an array of bytes
-		response := c.GetRawData()
+	if gatewayCfg.Passthrough.Enabled {
+		h.POST("/thrift/:serviceName", passthroughHandler(gatewayCfg.Passthrough, gatewayRoutes, callBinaryThrift, idlDeclares))
+	}
 
-		//converts the array of bytes into array format and loads it into jsonData
-		err := json.Unmarshal(response, &jsonData)
+	if err := registerStreamRoutes(h, gatewayCfg.Streams, gatewayRoutes, callGeneric); err != nil {
+		log.Fatal(err)
+	}
 
+	if gatewayCfg.GraphQL.Enabled {
+		docs, err := idl.ParseDir(thriftDirectory)
 		if err != nil {
-			fmt.Println("Error:", err)
-			c.String(consts.StatusBadRequest, "bad post request")
-			return
+			log.Fatal(err)
 		}
-
-		//converts the response to thrift binary format
-		responseFromRPC, err := makeThriftCall(IDLPath, jsonData,serviceName,methodName, ctx)
-
+		schema, err := buildGraphQLSchema(docs, callRouted)
 		if err != nil {
-			fmt.Println(err)
-			c.String(consts.StatusBadRequest, err.Error())
-			return
+			log.Fatal(err)
 		}
+		h.GET("/graphql", graphQLHandler(schema))
+		h.POST("/graphql", graphQLHandler(schema))
+	}
 
-		fmt.Println("Post Request successful")
-
-		c.JSON(consts.StatusOK, responseFromRPC)
+	h.POST("/:serviceName/:methodName", routePolicyMiddleware(gatewayRoutes), idempotencyMiddleware(gatewayCfg.Idempotency), genericPostHandler)
 
-	})
+	if gatewayCfg.Admin.Enabled {
+		if err := startAdminServer(gatewayCfg.Admin, h, serverTLS); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	//spin runs the application
 	h.Spin()
 }
 
+/**
+ * Serves POST /:serviceName/:methodName, converting the JSON body into a generic Thrift call
+ * to the service and returning its response as JSON.
+ */
+func genericPostHandler(ctx context.Context, c *app.RequestContext) {
+
+	serviceName := c.Param("serviceName")
+	
+	methodName := c.Param("methodName")
+
+	var jsonData map[string]interface{}
+
+	//returns data in an array of bytes
+	response := c.GetRawData()
+
+	//converts the array of bytes into array format and loads it into jsonData
+	err := json.Unmarshal(response, &jsonData)
+
+	if err != nil {
+		fmt.Println("Error:", err)
+		c.String(consts.StatusBadRequest, "bad post request")
+		return
+	}
+
+	//converts the response to thrift binary format, unless a cached response can be served
+	start := time.Now()
+	responseFromRPC, cacheStatus, err := gatewayCache.Do(ctx, serviceName, methodName, jsonData,
+		func(name string) string { return string(c.GetHeader(name)) },
+		callGeneric)
+	if cacheStatus != "" {
+		c.Header("X-Cache", cacheStatus)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		gatewayRecorder.record(serviceName, methodName, jsonData, consts.StatusBadRequest, nil, err, time.Since(start))
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+	gatewayRecorder.record(serviceName, methodName, jsonData, consts.StatusOK, responseFromRPC, nil, time.Since(start))
+
+	fmt.Println("Post Request successful")
+
+	c.JSON(consts.StatusOK, responseFromRPC)
+
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+/**
+ * counterVec is a monotonically increasing counter partitioned by label values, rendered in
+ * the Prometheus text exposition format by /metrics.
+ */
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+var (
+	metricsMu sync.Mutex
+	counters  []*counterVec
+)
+
+/**
+ * Creates a counter and registers it with /metrics.
+ *
+ * @param name The metric name.
+ * @param help The one-line description shown in the exposition.
+ * @param labels The label names; Add takes the values in the same order.
+ */
+func newCounterVec(name string, help string, labels ...string) *counterVec {
+	c := &counterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+	metricsMu.Lock()
+	counters = append(counters, c)
+	metricsMu.Unlock()
+	return c
+}
+
+// Add increases the counter for the given label values by delta.
+func (c *counterVec) Add(delta float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key] += delta
+	c.mu.Unlock()
+}
+
+// Inc increases the counter for the given label values by one.
+func (c *counterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the current count for the given label values.
+func (c *counterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *counterVec) write(b *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pairs := []string{}
+		for i, v := range strings.Split(k, "\xff") {
+			if i < len(c.labels) {
+				pairs = append(pairs, fmt.Sprintf("%s=%q", c.labels[i], v))
+			}
+		}
+		if len(pairs) > 0 {
+			fmt.Fprintf(b, "%s{%s} %v\n", c.name, strings.Join(pairs, ","), c.values[k])
+		} else {
+			fmt.Fprintf(b, "%s %v\n", c.name, c.values[k])
+		}
+	}
+}
+
+/**
+ * Serves /metrics in the Prometheus text exposition format.
+ */
+func metricsHandler(ctx context.Context, c *app.RequestContext) {
+	var b strings.Builder
+	metricsMu.Lock()
+	for _, counter := range counters {
+		counter.write(&b)
+	}
+	metricsMu.Unlock()
+	c.Data(consts.StatusOK, "text/plain; version=0.0.4", []byte(b.String()))
+}
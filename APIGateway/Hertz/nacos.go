@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/kitex/pkg/discovery"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// ctxNamespaceKey carries the Nacos namespace a request was routed to by header.
+const ctxNamespaceKey ctxKey = ctxConsistentKey + 1
+
+/**
+ * nacosConfig selects where in Nacos the gateway looks for backends, so dev, staging and prod
+ * can share one Nacos without their instances mixing.
+ *
+ * NamespaceHeader names a request header that sends a call to another namespace, for test
+ * traffic. Only namespaces listed in AllowedNamespaces are accepted from it.
+ */
+type nacosConfig struct {
+	// ServerAddr is the Nacos base URL, http://127.0.0.1:8848 when unset.
+	ServerAddr        string                      `json:"serverAddr"`
+	Namespace         string                      `json:"namespace"`
+	Group             string                      `json:"group"`
+	NamespaceHeader   string                      `json:"namespaceHeader"`
+	AllowedNamespaces []string                    `json:"allowedNamespaces"`
+	Services          map[string]clusterSelection `json:"services"`
+}
+
+/**
+ * clusterSelection picks the instances of one service. Clusters are tried in order and the
+ * first with a healthy instance wins, e.g. ["zone-a"] for same-zone first. With Failover, or
+ * when no clusters are listed, any cluster is used once the listed ones are empty.
+ */
+type clusterSelection struct {
+	// Group overrides nacosConfig.Group for this service.
+	Group    string   `json:"group"`
+	Clusters []string `json:"clusters"`
+	Failover bool     `json:"failover"`
+}
+
+// groupFor returns the Nacos group a service is registered in.
+func (n nacosConfig) groupFor(service string) string {
+	if g := n.Services[service].Group; g != "" {
+		return g
+	}
+	return n.Group
+}
+
+// namespaceAllowed reports whether the namespace header may select ns.
+func (n nacosConfig) namespaceAllowed(ns string) bool {
+	if ns == n.Namespace {
+		return true
+	}
+	for _, allowed := range n.AllowedNamespaces {
+		if allowed == ns {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Returns the namespace a request was routed to.
+ *
+ * @return The configured namespace unless the namespace header chose another.
+ */
+func requestNamespace(ctx context.Context) string {
+	if ns, ok := ctx.Value(ctxNamespaceKey).(string); ok {
+		return ns
+	}
+	return gatewayCfg.Nacos.Namespace
+}
+
+// routedByHeader reports whether a request was sent to a namespace other than the configured one.
+func routedByHeader(ctx context.Context) bool {
+	_, ok := ctx.Value(ctxNamespaceKey).(string)
+	return ok
+}
+
+/**
+ * Returns middleware that routes a request to the namespace named in the namespace header.
+ * Requests naming a namespace that is not allowed get 400.
+ */
+func namespaceMiddleware(cfg nacosConfig) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if cfg.NamespaceHeader == "" {
+			c.Next(ctx)
+			return
+		}
+		ns := string(c.GetHeader(cfg.NamespaceHeader))
+		if ns == "" || ns == cfg.Namespace {
+			c.Next(ctx)
+			return
+		}
+		if !cfg.namespaceAllowed(ns) {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("namespace %q is not allowed", ns)})
+			return
+		}
+		c.Next(context.WithValue(ctx, ctxNamespaceKey, ns))
+	}
+}
+
+// instanceSelector is the part of the Nacos naming client the resolver uses.
+type instanceSelector interface {
+	SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error)
+}
+
+/**
+ * clusterResolver resolves a service within one namespace and group, walking the cluster
+ * preference of a clusterSelection.
+ */
+type clusterResolver struct {
+	cli       instanceSelector
+	namespace string
+	group     string
+	selection clusterSelection
+}
+
+// tiers returns the cluster lists to try in order; a nil list means any cluster.
+func (r clusterResolver) tiers() [][]string {
+	tiers := [][]string{}
+	for _, cluster := range r.selection.Clusters {
+		tiers = append(tiers, []string{cluster})
+	}
+	if r.selection.Failover || len(r.selection.Clusters) == 0 {
+		tiers = append(tiers, nil)
+	}
+	return tiers
+}
+
+func (r clusterResolver) Target(ctx context.Context, target rpcinfo.EndpointInfo) string {
+	return target.ServiceName()
+}
+
+func (r clusterResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	lastErr := fmt.Errorf("no instance remains for %v", desc)
+	for _, clusters := range r.tiers() {
+		res, err := r.cli.SelectInstances(vo.SelectInstancesParam{
+			ServiceName: desc,
+			GroupName:   r.group,
+			Clusters:    clusters,
+			HealthyOnly: true,
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		instances := make([]discovery.Instance, 0, len(res))
+		for _, in := range res {
+			if !in.Enable {
+				continue
+			}
+			tags := map[string]string{"cluster": in.ClusterName}
+			for k, v := range in.Metadata {
+				tags[k] = v
+			}
+			instances = append(instances, discovery.NewInstance("tcp", fmt.Sprintf("%s:%d", in.Ip, in.Port), int(in.Weight), tags))
+		}
+		if len(instances) > 0 {
+			return discovery.Result{Cacheable: true, CacheKey: desc, Instances: instances}, nil
+		}
+	}
+	return discovery.Result{}, lastErr
+}
+
+func (r clusterResolver) Diff(cacheKey string, prev, next discovery.Result) (discovery.Change, bool) {
+	return discovery.DefaultDiff(cacheKey, prev, next)
+}
+
+// Name tells Kitex's balancer cache apart per namespace, group and cluster preference.
+func (r clusterResolver) Name() string {
+	return fmt.Sprintf("nacos:%s:%s:%s:%t", r.namespace, r.group, strings.Join(r.selection.Clusters, ","), r.selection.Failover)
+}
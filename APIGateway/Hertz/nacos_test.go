@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// fakeSelector serves instances per cluster; a query without clusters sees all of them.
+type fakeSelector struct {
+	clusters map[string][]model.Instance
+	queries  []string
+}
+
+func (f *fakeSelector) SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error) {
+	f.queries = append(f.queries, param.GroupName+":"+strings.Join(param.Clusters, ","))
+	var out []model.Instance
+	for cluster, instances := range f.clusters {
+		if len(param.Clusters) == 0 || param.Clusters[0] == cluster {
+			out = append(out, instances...)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("instance list is empty!")
+	}
+	return out, nil
+}
+
+func TestClusterResolverPrefersClustersInOrder(t *testing.T) {
+	sel := &fakeSelector{clusters: map[string][]model.Instance{
+		"zone-a": {{Ip: "10.0.0.1", Port: 8888, Weight: 10, Enable: true, ClusterName: "zone-a"}},
+		"zone-b": {{Ip: "10.0.1.1", Port: 8888, Weight: 10, Enable: true, ClusterName: "zone-b"}},
+	}}
+	r := clusterResolver{cli: sel, namespace: "prod", group: "TRAVEL", selection: clusterSelection{Clusters: []string{"zone-c", "zone-a"}}}
+
+	res, err := r.Resolve(context.Background(), "TravelService")
+	if err != nil || len(res.Instances) != 1 || res.Instances[0].Address().String() != "10.0.0.1:8888" {
+		t.Fatalf("Expected the zone-a instance, got %v, %v", res.Instances, err)
+	}
+	if cluster, _ := res.Instances[0].Tag("cluster"); cluster != "zone-a" {
+		t.Fatalf("Expected the cluster tag, got %q", cluster)
+	}
+	if strings.Join(sel.queries, " ") != "TRAVEL:zone-c TRAVEL:zone-a" {
+		t.Fatalf("Unexpected queries %v", sel.queries)
+	}
+
+	// Without failover the listed clusters are all there is.
+	delete(sel.clusters, "zone-a")
+	if _, err := r.Resolve(context.Background(), "TravelService"); err == nil {
+		t.Fatalf("Expected no instances without failover")
+	}
+	r.selection.Failover = true
+	res, err = r.Resolve(context.Background(), "TravelService")
+	if err != nil || res.Instances[0].Address().String() != "10.0.1.1:8888" {
+		t.Fatalf("Expected failover to zone-b, got %v, %v", res.Instances, err)
+	}
+
+	other := r
+	other.namespace = "test"
+	if r.Name() == other.Name() {
+		t.Fatalf("Resolvers for different namespaces must not share a balancer cache")
+	}
+}
+
+func TestNamespaceMiddleware(t *testing.T) {
+	cfg := nacosConfig{Namespace: "prod", Group: "DEFAULT_GROUP", NamespaceHeader: "X-Nacos-Namespace", AllowedNamespaces: []string{"test"}}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(namespaceMiddleware(cfg))
+	engine.GET("/ns", func(ctx context.Context, c *app.RequestContext) {
+		ns, _ := ctx.Value(ctxNamespaceKey).(string)
+		c.String(consts.StatusOK, ns)
+	})
+
+	get := func(ns string) (int, string) {
+		resp := ut.PerformRequest(engine, consts.MethodGet, "/ns", nil, ut.Header{Key: "X-Nacos-Namespace", Value: ns})
+		return resp.Code, resp.Body.String()
+	}
+	if code, body := get("test"); code != consts.StatusOK || body != "test" {
+		t.Fatalf("Expected routing to test, got %d %q", code, body)
+	}
+	if code, body := get(""); code != consts.StatusOK || body != "" {
+		t.Fatalf("Expected no override without the header, got %d %q", code, body)
+	}
+	if code, _ := get("prod-other"); code != consts.StatusBadRequest {
+		t.Fatalf("Expected 400 for a namespace that is not allowed, got %d", code)
+	}
+}
+
+func TestNacosGroupFor(t *testing.T) {
+	cfg := nacosConfig{Group: "DEFAULT_GROUP", Services: map[string]clusterSelection{"ReviewService": {Group: "REVIEWS"}}}
+	if cfg.groupFor("ReviewService") != "REVIEWS" || cfg.groupFor("TravelService") != "DEFAULT_GROUP" {
+		t.Fatalf("Unexpected groups")
+	}
+}
@@ -0,0 +1,53 @@
+package nacostest
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/cloudwego/kitex/pkg/generic"
+	"github.com/cloudwego/kitex/server"
+	"github.com/cloudwego/kitex/server/genericserver"
+)
+
+// Backend is a Kitex JSON generic server listening on a random local port.
+type Backend struct {
+	// Addr is the host:port the backend listens on.
+	Addr string
+	svr  server.Server
+}
+
+// StartBackend serves a service from its IDL with handler answering every method, as the
+// generic servers in RPCBackend do. The listener is open when it returns, so calls made
+// straight away wait in the accept queue until the server is running.
+func StartBackend(idlPath string, handler generic.Service) (*Backend, error) {
+	p, err := generic.NewThriftFileProvider(idlPath)
+	if err != nil {
+		return nil, err
+	}
+	g, err := generic.JSONThriftGeneric(p)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &Backend{Addr: ln.Addr().String()}
+	// Kitex wants a pointer handler, so wrap it for callers passing a plain struct.
+	b.svr = genericserver.NewServer(&serviceHandler{handler}, g, server.WithListener(ln))
+	go func() {
+		if err := b.svr.Run(); err != nil {
+			fmt.Println("Test backend", b.Addr, "stopped:", err)
+		}
+	}()
+	return b, nil
+}
+
+type serviceHandler struct {
+	generic.Service
+}
+
+// Stop shuts the backend down.
+func (b *Backend) Stop() error {
+	return b.svr.Stop()
+}
@@ -0,0 +1,251 @@
+// Package nacostest runs an in-memory stand-in for the Nacos naming API and Kitex generic
+// backends on random ports, so tests can exercise service discovery and whole calls without a
+// live Nacos.
+//
+// The Nacos Go SDK the gateway and the backend use (v1) talks to Nacos over HTTP only, so the
+// stand-in serves the HTTP endpoints it calls: instance list, register, deregister, update and
+// beat. It keeps no health state: an instance is healthy until it is deregistered or
+// re-registered as unhealthy.
+package nacostest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/model"
+)
+
+// Defaults Nacos applies when a request leaves them out.
+const (
+	DefaultNamespace = "public"
+	DefaultGroup     = "DEFAULT_GROUP"
+	DefaultCluster   = "DEFAULT"
+)
+
+type serviceKey struct {
+	namespace string
+	group     string
+	service   string
+}
+
+// Server is a fake Nacos server. The zero value is not usable; create one with NewServer.
+type Server struct {
+	// URL is the base address, e.g. http://127.0.0.1:41234, without the /nacos context path.
+	URL string
+
+	srv       *httptest.Server
+	mu        sync.Mutex
+	instances map[serviceKey][]model.Instance
+}
+
+// NewServer starts a fake Nacos server on a random local port.
+func NewServer() *Server {
+	s := &Server{instances: map[serviceKey][]model.Instance{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nacos/v1/ns/instance/list", s.list)
+	mux.HandleFunc("/nacos/v1/ns/instance/beat", s.beat)
+	mux.HandleFunc("/nacos/v1/ns/instance", s.instance)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// HostPort returns the address the Nacos SDK's constant.ServerConfig needs.
+func (s *Server) HostPort() (string, uint64) {
+	u, _ := url.Parse(s.URL)
+	port, _ := strconv.ParseUint(u.Port(), 10, 64)
+	return u.Hostname(), port
+}
+
+// Instance returns a healthy, enabled instance of weight 10 in the default cluster.
+func Instance(address string) model.Instance {
+	host, port, _ := net.SplitHostPort(address)
+	p, _ := strconv.ParseUint(port, 10, 64)
+	return model.Instance{
+		Ip:          host,
+		Port:        p,
+		Weight:      10,
+		ClusterName: DefaultCluster,
+		Enable:      true,
+		Healthy:     true,
+		Ephemeral:   true,
+		Metadata:    map[string]string{},
+	}
+}
+
+// Register adds an instance to a service, replacing any instance at the same address.
+func (s *Server) Register(namespace string, group string, service string, ins model.Instance) {
+	key := newServiceKey(namespace, group, service)
+	if ins.ClusterName == "" {
+		ins.ClusterName = DefaultCluster
+	}
+	ins.Valid = ins.Healthy
+	ins.ServiceName = key.group + "@@" + key.service
+	ins.InstanceId = fmt.Sprintf("%s#%d#%s#%s", ins.Ip, ins.Port, ins.ClusterName, ins.ServiceName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(key, ins.Ip, ins.Port)
+	s.instances[key] = append(s.instances[key], ins)
+}
+
+// Deregister removes the instance at ip:port from a service.
+//
+// It reports whether the instance was registered.
+func (s *Server) Deregister(namespace string, group string, service string, ip string, port uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeLocked(newServiceKey(namespace, group, service), ip, port)
+}
+
+// Instances returns the registered instances of a service, healthy or not, sorted by address.
+func (s *Server) Instances(namespace string, group string, service string) []model.Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := append([]model.Instance{}, s.instances[newServiceKey(namespace, group, service)]...)
+	sort.Slice(out, func(i, j int) bool {
+		return fmt.Sprintf("%s:%d", out[i].Ip, out[i].Port) < fmt.Sprintf("%s:%d", out[j].Ip, out[j].Port)
+	})
+	return out
+}
+
+func (s *Server) removeLocked(key serviceKey, ip string, port uint64) bool {
+	list := s.instances[key]
+	for i, ins := range list {
+		if ins.Ip == ip && ins.Port == port {
+			s.instances[key] = append(list[:i:i], list[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func newServiceKey(namespace string, group string, service string) serviceKey {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	// The SDK sends "group@@service"; the HTTP API also takes the group on its own.
+	if i := strings.Index(service, "@@"); i >= 0 {
+		group, service = service[:i], service[i+2:]
+	}
+	if group == "" {
+		group = DefaultGroup
+	}
+	return serviceKey{namespace: namespace, group: group, service: service}
+}
+
+// params merges the query string with a form-encoded body, as Nacos accepts either.
+func params(r *http.Request) url.Values {
+	values := r.URL.Query()
+	if r.Body == nil {
+		return values
+	}
+	body, _ := io.ReadAll(r.Body)
+	form, _ := url.ParseQuery(string(body))
+	for k, v := range form {
+		values[k] = v
+	}
+	return values
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := params(r)
+	key := newServiceKey(q.Get("namespaceId"), q.Get("groupName"), q.Get("serviceName"))
+	clusters := map[string]bool{}
+	for _, c := range strings.Split(q.Get("clusters"), ",") {
+		if c != "" {
+			clusters[c] = true
+		}
+	}
+	healthyOnly := q.Get("healthyOnly") == "true"
+
+	hosts := []model.Instance{}
+	for _, ins := range s.Instances(key.namespace, key.group, key.service) {
+		if len(clusters) > 0 && !clusters[ins.ClusterName] {
+			continue
+		}
+		if healthyOnly && !ins.Healthy {
+			continue
+		}
+		hosts = append(hosts, ins)
+	}
+	writeJSON(w, model.Service{
+		Name:        key.group + "@@" + key.service,
+		Dom:         key.group + "@@" + key.service,
+		Clusters:    q.Get("clusters"),
+		CacheMillis: 3000,
+		Hosts:       hosts,
+		LastRefTime: uint64(time.Now().UnixMilli()),
+		Checksum:    strconv.FormatInt(time.Now().UnixNano(), 10),
+	})
+}
+
+// instance handles register (POST), update (PUT) and deregister (DELETE).
+func (s *Server) instance(w http.ResponseWriter, r *http.Request) {
+	q := params(r)
+	key := newServiceKey(q.Get("namespaceId"), q.Get("groupName"), q.Get("serviceName"))
+	port, err := strconv.ParseUint(q.Get("port"), 10, 64)
+	if err != nil || q.Get("ip") == "" {
+		http.Error(w, "ip and port are required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		ins := model.Instance{
+			Ip:          q.Get("ip"),
+			Port:        port,
+			Weight:      1,
+			ClusterName: q.Get("clusterName"),
+			Enable:      q.Get("enable") != "false",
+			Healthy:     q.Get("healthy") != "false",
+			Ephemeral:   q.Get("ephemeral") != "false",
+			Metadata:    map[string]string{},
+		}
+		if weight, err := strconv.ParseFloat(q.Get("weight"), 64); err == nil {
+			ins.Weight = weight
+		}
+		if m := q.Get("metadata"); m != "" {
+			if err := json.Unmarshal([]byte(m), &ins.Metadata); err != nil {
+				http.Error(w, "metadata must be a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+		s.Register(key.namespace, key.group, key.service, ins)
+	case http.MethodDelete:
+		s.Deregister(key.namespace, key.group, key.service, q.Get("ip"), port)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	io.WriteString(w, "ok")
+}
+
+// beat acknowledges client heartbeats; instances never expire in the stand-in.
+func (s *Server) beat(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{"clientBeatInterval": 5000, "code": 10200})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
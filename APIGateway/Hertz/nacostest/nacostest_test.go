@@ -0,0 +1,135 @@
+package nacostest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/kitex/client"
+	"github.com/cloudwego/kitex/client/genericclient"
+	"github.com/cloudwego/kitex/pkg/generic"
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+func newNamingClient(t *testing.T, s *Server, namespace string) naming_client.INamingClient {
+	host, port := s.HostPort()
+	cli, err := clients.NewNamingClient(vo.NacosClientParam{
+		ClientConfig: &constant.ClientConfig{
+			NamespaceId:         namespace,
+			TimeoutMs:           5000,
+			NotLoadCacheAtStart: true,
+			LogDir:              t.TempDir(),
+			CacheDir:            t.TempDir(),
+			LogLevel:            "error",
+		},
+		ServerConfigs: []constant.ServerConfig{*constant.NewServerConfig(host, port)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli
+}
+
+func TestSDKRegistersAndSelectsInstances(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	cli := newNamingClient(t, s, "dev")
+
+	ok, err := cli.RegisterInstance(vo.RegisterInstanceParam{
+		Ip: "10.0.0.1", Port: 8888, Weight: 10, Enable: true, Healthy: true, Ephemeral: true,
+		ServiceName: "TravelService", GroupName: "travel", ClusterName: "zone-a",
+		Metadata: map[string]string{"version": "v1"},
+	})
+	if err != nil || !ok {
+		t.Fatalf("Register failed: %v", err)
+	}
+	s.Register("dev", "travel", "TravelService", Instance("10.0.0.2:8888"))
+
+	all, err := cli.SelectInstances(vo.SelectInstancesParam{ServiceName: "TravelService", GroupName: "travel", HealthyOnly: true})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("Expected both instances, got %v, %v", all, err)
+	}
+	zoneA, err := cli.SelectInstances(vo.SelectInstancesParam{ServiceName: "TravelService", GroupName: "travel", Clusters: []string{"zone-a"}, HealthyOnly: true})
+	if err != nil || len(zoneA) != 1 || zoneA[0].Metadata["version"] != "v1" {
+		t.Fatalf("Expected the zone-a instance with its metadata, got %v, %v", zoneA, err)
+	}
+
+	// Other namespaces and groups do not see the service.
+	if got := s.Instances("public", "travel", "TravelService"); len(got) != 0 {
+		t.Fatalf("Namespaces should be separate, got %v", got)
+	}
+	if got := s.Instances("dev", DefaultGroup, "TravelService"); len(got) != 0 {
+		t.Fatalf("Groups should be separate, got %v", got)
+	}
+}
+
+func TestInstanceListHTTPAPI(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Register("", "", "ReviewService", Instance("127.0.0.1:8887"))
+
+	get := func(query string) map[string]interface{} {
+		resp, err := http.Get(s.URL + "/nacos/v1/ns/instance/list?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if hosts := get("serviceName=ReviewService&namespaceId=public&groupName=DEFAULT_GROUP")["hosts"].([]interface{}); len(hosts) != 1 {
+		t.Fatalf("Expected one host, got %v", hosts)
+	}
+	// Unknown services list no hosts rather than failing, as Nacos does.
+	if hosts, ok := get("serviceName=nothing")["hosts"].([]interface{}); !ok || len(hosts) != 0 {
+		t.Fatalf("Expected an empty host list, got %v", hosts)
+	}
+
+	if !s.Deregister("public", DefaultGroup, "ReviewService", "127.0.0.1", 8887) {
+		t.Fatalf("Expected the instance to be deregistered")
+	}
+	if hosts := get("serviceName=ReviewService")["hosts"].([]interface{}); len(hosts) != 0 {
+		t.Fatalf("Expected no hosts after deregistering, got %v", hosts)
+	}
+}
+
+type echoService struct{}
+
+func (echoService) GenericCall(ctx context.Context, method string, request interface{}) (interface{}, error) {
+	return `{"message": "` + method + `"}`, nil
+}
+
+func TestStartBackend(t *testing.T) {
+	b, err := StartBackend("../thriftFiles/ReviewService.thrift", echoService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	p, err := generic.NewThriftFileProvider("../thriftFiles/ReviewService.thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := generic.JSONThriftGeneric(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := genericclient.NewClient("ReviewService", g, client.WithHostPorts(b.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := cli.GenericCall(context.Background(), "sendReview", `{"Msg": "hi", "userID": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.(string) == "" {
+		t.Fatalf("Expected a response")
+	}
+}
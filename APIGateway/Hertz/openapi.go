@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	swaggerFiles "github.com/swaggo/files/v2"
+
+	"hertz_demo/idl"
+)
+
+// swaggerOverrides replaces files of the stock Swagger UI bundle, pointing it at /openapi.json.
+//
+//go:embed static/swagger
+var swaggerOverrides embed.FS
+
+/**
+ * openAPISchema is the subset of the OpenAPI 3 schema object the Thrift types map onto.
+ */
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	UniqueItems          bool                      `json:"uniqueItems,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Enum                 []int64                   `json:"enum,omitempty"`
+}
+
+/**
+ * Converts a Thrift type into an OpenAPI schema. Structs become references to components.
+ *
+ * @param t The resolved Thrift type.
+ * @param names The component name assigned to each struct.
+ *
+ * @return The matching schema object.
+ */
+func thriftTypeSchema(t *idl.Type, names map[*idl.StructDef]string) *openAPISchema {
+	switch t.Category {
+	case idl.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case idl.Byte, idl.I16, idl.I32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case idl.I64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case idl.Double:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case idl.String:
+		return &openAPISchema{Type: "string"}
+	case idl.Binary:
+		return &openAPISchema{Type: "string", Format: "byte"}
+	case idl.List:
+		return &openAPISchema{Type: "array", Items: thriftTypeSchema(t.Value, names)}
+	case idl.Set:
+		return &openAPISchema{Type: "array", Items: thriftTypeSchema(t.Value, names), UniqueItems: true}
+	case idl.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: thriftTypeSchema(t.Value, names)}
+	case idl.Enum:
+		return &openAPISchema{Type: "integer", Format: "int32", Enum: t.EnumValues}
+	case idl.Struct:
+		return &openAPISchema{Ref: "#/components/schemas/" + names[t.Struct]}
+	}
+	return &openAPISchema{}
+}
+
+/**
+ * Converts a Thrift struct into an object schema; required fields are listed under "required".
+ */
+func thriftStructSchema(st *idl.StructDef, names map[*idl.StructDef]string) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for _, f := range st.Fields {
+		schema.Properties[f.Name] = thriftTypeSchema(f.Type, names)
+		if f.Requiredness == idl.Required {
+			schema.Required = append(schema.Required, f.Name)
+		}
+	}
+	return schema
+}
+
+/**
+ * Generates an OpenAPI 3 document describing the POST /:serviceName/:methodName routes.
+ *
+ * @param docs The parsed IDLs, one per service file.
+ *
+ * @return The OpenAPI document as a JSON-serialisable map.
+ */
+func buildOpenAPI(docs []*idl.Document) map[string]interface{} {
+	schemas := map[string]*openAPISchema{}
+	paths := map[string]interface{}{}
+
+	for _, doc := range docs {
+		// Files such as base.thrift are only reachable through includes.
+		if len(doc.Services) == 0 {
+			continue
+		}
+
+		// Structs shared through includes (base.BaseResp) map to one component. A struct whose
+		// name is already taken by a different definition gets the IDL file name as a prefix.
+		names := map[*idl.StructDef]string{}
+		for _, name := range doc.StructNames() {
+			componentName := name
+			if _, taken := schemas[name]; taken && !sameStructAs(schemas[name], doc.Structs[name]) {
+				componentName = strings.TrimSuffix(path.Base(doc.File), ".thrift") + "." + name
+			}
+			names[doc.Structs[name]] = componentName
+		}
+		for st, name := range names {
+			schemas[name] = thriftStructSchema(st, names)
+		}
+
+		for _, svc := range doc.Services {
+			for _, m := range svc.Methods {
+				op := map[string]interface{}{
+					"operationId": fmt.Sprintf("%s_%s", svc.Name, m.Name),
+					"tags":        []string{svc.Name},
+					"responses": map[string]interface{}{
+						"200": openAPIResponse("Response returned by the backend", m.Response, names),
+						"400": map[string]interface{}{
+							"description": "Malformed request or backend call failure",
+							"content": map[string]interface{}{
+								"text/plain": map[string]interface{}{"schema": &openAPISchema{Type: "string"}},
+							},
+						},
+					},
+				}
+				if req := m.Request(); req != nil {
+					op["requestBody"] = map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": thriftTypeSchema(req, names)},
+						},
+					}
+				}
+				paths[fmt.Sprintf("/%s/%s", svc.Name, m.Name)] = map[string]interface{}{"post": op}
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "API Gateway",
+			"description": "HTTP routes generated from the Thrift IDLs in thriftFiles.",
+			"version":     "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func openAPIResponse(description string, t *idl.Type, names map[*idl.StructDef]string) map[string]interface{} {
+	resp := map[string]interface{}{"description": description}
+	if t != nil {
+		resp["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": thriftTypeSchema(t, names)},
+		}
+	}
+	return resp
+}
+
+// sameStructAs reports whether an already generated schema has the same field names as st.
+func sameStructAs(schema *openAPISchema, st *idl.StructDef) bool {
+	if len(schema.Properties) != len(st.Fields) {
+		return false
+	}
+	for _, f := range st.Fields {
+		if _, ok := schema.Properties[f.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Serves /openapi.json, generated from the IDLs currently in the thriftFiles directory.
+ */
+func openAPIHandler(ctx context.Context, c *app.RequestContext) {
+	docs, err := idl.ParseDir(thriftDirectory)
+	if err != nil {
+		c.JSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	c.JSON(consts.StatusOK, buildOpenAPI(docs))
+}
+
+/**
+ * Serves Swagger UI under /swagger/ from embedded files. Our overrides win over the stock bundle.
+ */
+func swaggerHandler(ctx context.Context, c *app.RequestContext) {
+	name := strings.TrimPrefix(c.Param("filepath"), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	data, err := fs.ReadFile(swaggerOverrides, "static/swagger/"+name)
+	if err != nil {
+		data, err = fs.ReadFile(swaggerFiles.FS, name)
+	}
+	if err != nil {
+		c.String(consts.StatusNotFound, "not found")
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Data(consts.StatusOK, contentType, data)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"hertz_demo/idl"
+)
+
+func TestOpenAPIPathsAndSchemas(t *testing.T) {
+	docs, err := idl.ParseDir(thriftDirectory)
+	if err != nil {
+		t.Fatalf("Should parse thriftFiles: %v", err)
+	}
+	spec := buildOpenAPI(docs)
+
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("Spec should serialise: %v", err)
+	}
+	var decoded struct {
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]openAPISchema
+		}
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("Spec should decode: %v", err)
+	}
+
+	if _, ok := decoded.Paths["/ReviewService/sendReview"]["post"]; !ok {
+		t.Fatalf("Missing POST /ReviewService/sendReview")
+	}
+	if _, ok := decoded.Paths["/TravelService/RetrieveClientData"]["post"]; !ok {
+		t.Fatalf("Missing POST /TravelService/RetrieveClientData")
+	}
+
+	review := decoded.Components.Schemas["ReviewRequest"]
+	if review.Properties["userID"].Format != "int64" {
+		t.Fatalf("ReviewRequest.userID should be int64, got %+v", review.Properties["userID"])
+	}
+	clientResp := decoded.Components.Schemas["ClientResp"]
+	if clientResp.Properties["BaseResp"].Ref != "#/components/schemas/base.BaseResp" {
+		t.Fatalf("ClientResp.BaseResp should reference base.BaseResp, got %+v", clientResp.Properties["BaseResp"])
+	}
+	if len(clientResp.Required) != 1 || clientResp.Required[0] != "Msg" {
+		t.Fatalf("ClientResp should require Msg only, got %v", clientResp.Required)
+	}
+	if _, ok := decoded.Components.Schemas["base.BaseResp"]; !ok {
+		t.Fatalf("Missing base.BaseResp schema")
+	}
+}
+
+func TestOpenAPISkipsIncludeOnlyFiles(t *testing.T) {
+	docs, err := idl.ParseDir(thriftDirectory)
+	if err != nil {
+		t.Fatalf("Should parse thriftFiles: %v", err)
+	}
+	schemas := buildOpenAPI(docs)["components"].(map[string]interface{})["schemas"].(map[string]*openAPISchema)
+	if _, ok := schemas["BaseResp"]; ok {
+		t.Fatalf("base.thrift structs should only appear qualified")
+	}
+}
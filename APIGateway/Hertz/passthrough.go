@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/kitex/pkg/generic"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const thriftContentType = "application/x-thrift"
+
+/**
+ * passthroughConfig enables POST /thrift/:serviceName, which forwards Thrift binary messages
+ * without converting them to JSON. Auth and RateLimit apply per caller, keyed by the token's
+ * sub claim or, without auth, the client IP.
+ */
+type passthroughConfig struct {
+	Enabled   bool            `json:"enabled"`
+	Auth      authConfig      `json:"auth"`
+	RateLimit rateLimitConfig `json:"rateLimit"`
+}
+
+var passthroughRequests = newCounterVec("gateway_passthrough_requests_total",
+	"Binary Thrift passthrough requests by outcome.", "service", "method", "outcome")
+
+// binaryCaller forwards one complete Thrift binary message and returns the reply message.
+type binaryCaller func(ctx context.Context, service string, method string, message []byte) ([]byte, error)
+
+/**
+ * Forwards a Thrift binary message through a binary generic client. The client is built with
+ * initialiseClientIn, so discovery, load balancing and backend TLS match the JSON route.
+ */
+func callBinaryThrift(ctx context.Context, service string, method string, message []byte) ([]byte, error) {
+	_, resolveSpan := startSpan(ctx, "registry.resolve", attribute.String("rpc.service", service))
+	cli, err := initialiseClientIn(generic.BinaryThriftGeneric(), service, requestNamespace(ctx))
+	endSpan(resolveSpan, err)
+	if err != nil {
+		return nil, err
+	}
+
+	// The payload is not decoded, so a consistent_hash policy can only hash on a header here.
+	ctx = context.WithValue(ctx, ctxConsistentKey, gatewayPolicies.get(service).hashKeyOf(ctx, nil))
+	ctx, callSpan := otel.Tracer(tracerName).Start(ctx, "GenericCall",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "kitex"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", method),
+		),
+	)
+	resp, err := cli.GenericCall(injectTraceContext(ctx), method, message)
+	endSpan(callSpan, err)
+	if err != nil {
+		return nil, err
+	}
+	reply, ok := resp.([]byte)
+	if !ok {
+		return nil, errors.New("binary generic call did not return bytes")
+	}
+	return reply, nil
+}
+
+/**
+ * thriftEnvelope is the header of a strict Thrift binary message.
+ */
+type thriftEnvelope struct {
+	method string
+	seqID  int32
+	// framed is true when the message came with a 4-byte length prefix, which the reply then gets too.
+	framed  bool
+	message []byte
+}
+
+/**
+ * Reads the method name and sequence ID of a Thrift binary CALL message, unframed or framed.
+ *
+ * @return The envelope, or an error when the payload is not a strict binary CALL message.
+ */
+func parseThriftEnvelope(payload []byte) (thriftEnvelope, error) {
+	env := thriftEnvelope{message: payload}
+	if len(payload) >= 8 && int(binary.BigEndian.Uint32(payload)) == len(payload)-4 && payload[4] == 0x80 {
+		env.framed = true
+		env.message = payload[4:]
+	}
+	msg := env.message
+	if len(msg) < 12 || msg[0] != 0x80 || msg[1] != 0x01 {
+		return env, errors.New("payload is not a strict Thrift binary message")
+	}
+	switch msg[3] {
+	case 1:
+	case 4:
+		return env, errors.New("oneway messages are not supported")
+	default:
+		return env, fmt.Errorf("message type %d is not a call", msg[3])
+	}
+	nameLen := int(binary.BigEndian.Uint32(msg[4:8]))
+	if nameLen <= 0 || len(msg) < 8+nameLen+4 {
+		return env, errors.New("truncated Thrift message header")
+	}
+	env.method = string(msg[8 : 8+nameLen])
+	env.seqID = int32(binary.BigEndian.Uint32(msg[8+nameLen:]))
+	return env, nil
+}
+
+/**
+ * Picks the HTTP status for a failed passthrough call.
+ */
+func passthroughStatus(err error) int {
+	switch {
+	case errors.Is(err, kerrors.ErrRPCTimeout), errors.Is(err, kerrors.ErrTimeoutByBusiness):
+		return consts.StatusGatewayTimeout
+	case errors.Is(err, errServiceNotFound), errors.Is(err, errRegistryUnavailable),
+		errors.Is(err, kerrors.ErrServiceDiscovery), errors.Is(err, kerrors.ErrGetConnection),
+		errors.Is(err, kerrors.ErrNoMoreInstance), errors.Is(err, kerrors.ErrCircuitBreak):
+		return consts.StatusServiceUnavailable
+	case errors.Is(err, kerrors.ErrOverlimit):
+		return consts.StatusTooManyRequests
+	}
+	return consts.StatusBadGateway
+}
+
+/**
+ * Returns the handler for POST /thrift/:serviceName.
+ *
+ * The body is a Thrift binary CALL message, optionally framed. The IDL is only consulted to check
+ * that the service declares the method; the payload itself is forwarded untouched, and the reply
+ * is returned as application/x-thrift with the caller's sequence ID. Besides its own auth, every
+ * call must pass the live route policy in routes, like the other public entrypoints.
+ */
+func passthroughHandler(cfg passthroughConfig, routes *routeTable, call binaryCaller, methodExists func(string, string) bool) app.HandlerFunc {
+	limiter := newRateLimiter(cfg.RateLimit)
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		service := c.Param("serviceName")
+		if !strings.HasPrefix(string(c.ContentType()), thriftContentType) {
+			c.String(consts.StatusUnsupportedMediaType, "content type must be "+thriftContentType)
+			return
+		}
+
+		claims, err := authenticateRequest(cfg.Auth, c)
+		if err != nil {
+			passthroughRequests.Inc(service, "", "unauthorized")
+			c.String(consts.StatusUnauthorized, err.Error())
+			return
+		}
+		caller, _ := claims["sub"].(string)
+		if caller == "" {
+			caller = "ip:" + c.ClientIP()
+		}
+		if !limiter.Allow(caller, time.Now()) {
+			passthroughRequests.Inc(service, "", "rate_limited")
+			c.Header("Retry-After", "1")
+			c.String(consts.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		env, err := parseThriftEnvelope(c.GetRawData())
+		if err != nil {
+			passthroughRequests.Inc(service, "", "bad_request")
+			c.String(consts.StatusBadRequest, err.Error())
+			return
+		}
+		if err := routes.load().check(service, env.method, requestToken(c)); err != nil {
+			passthroughRequests.Inc(service, env.method, "refused")
+			c.String(routePolicyStatus(err), err.Error())
+			return
+		}
+		if !methodExists(service, env.method) {
+			passthroughRequests.Inc(service, env.method, "not_found")
+			c.String(consts.StatusNotFound, fmt.Sprintf("%s does not declare %s", service, env.method))
+			return
+		}
+
+		reply, err := call(ctx, service, env.method, env.message)
+		if err != nil {
+			passthroughRequests.Inc(service, env.method, "error")
+			c.String(passthroughStatus(err), err.Error())
+			return
+		}
+		// Kitex assigns its own sequence ID on the way out; callers expect theirs back.
+		if err := generic.SetSeqID(env.seqID, reply); err != nil {
+			passthroughRequests.Inc(service, env.method, "error")
+			c.String(consts.StatusBadGateway, err.Error())
+			return
+		}
+		if env.framed {
+			reply = append(binary.BigEndian.AppendUint32(nil, uint32(len(reply))), reply...)
+		}
+		passthroughRequests.Inc(service, env.method, "ok")
+		c.Data(consts.StatusOK, thriftContentType, reply)
+	}
+}
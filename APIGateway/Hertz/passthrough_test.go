@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+)
+
+// thriftMessage builds a strict binary message with an empty-ish struct body.
+func thriftMessage(msgType byte, method string, seqID int32) []byte {
+	msg := []byte{0x80, 0x01, 0x00, msgType}
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(method)))
+	msg = append(msg, method...)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(seqID))
+	return append(msg, 12, 0, 1, 8, 0, 1, 0, 0, 0, 1, 0, 0)
+}
+
+func framed(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+}
+
+func newPassthroughEngine(cfg passthroughConfig, routes *routeTable, seen *[]string) *route.Engine {
+	call := func(ctx context.Context, service string, method string, message []byte) ([]byte, error) {
+		*seen = append(*seen, service+"."+method)
+		if method == "deleteReview" {
+			return nil, kerrors.ErrRPCTimeout.WithCause(fmt.Errorf("3s elapsed"))
+		}
+		// Answer with a REPLY carrying a different sequence ID, as Kitex does.
+		return thriftMessage(2, method, 1), nil
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/thrift/:serviceName", passthroughHandler(cfg, routes, call, idlDeclares))
+	return engine
+}
+
+func postThrift(engine *route.Engine, service string, body []byte, headers ...ut.Header) *ut.ResponseRecorder {
+	headers = append(headers, ut.Header{Key: "Content-Type", Value: thriftContentType})
+	return ut.PerformRequest(engine, consts.MethodPost, "/thrift/"+service,
+		&ut.Body{Body: bytes.NewReader(body), Len: len(body)}, headers...)
+}
+
+func TestParseThriftEnvelope(t *testing.T) {
+	msg := thriftMessage(1, "RetrieveClientData", 99)
+	env, err := parseThriftEnvelope(msg)
+	if err != nil || env.framed || env.method != "RetrieveClientData" || env.seqID != 99 {
+		t.Fatalf("Unexpected envelope %+v, %v", env, err)
+	}
+	env, err = parseThriftEnvelope(framed(msg))
+	if err != nil || !env.framed || !bytes.Equal(env.message, msg) {
+		t.Fatalf("Framed message not unwrapped: %+v, %v", env, err)
+	}
+	for _, bad := range [][]byte{[]byte(`{"userID":1}`), thriftMessage(4, "ping", 1), thriftMessage(2, "ping", 1), msg[:10]} {
+		if _, err := parseThriftEnvelope(bad); err == nil {
+			t.Fatalf("Expected %v to be rejected", bad)
+		}
+	}
+}
+
+func TestPassthroughForwardsAndRestoresSeqID(t *testing.T) {
+	var seen []string
+	engine := newPassthroughEngine(passthroughConfig{Enabled: true}, &routeTable{}, &seen)
+
+	resp := postThrift(engine, "TravelService", thriftMessage(1, "RetrieveClientData", 99))
+	if resp.Code != consts.StatusOK || string(resp.Header().ContentType()) != thriftContentType {
+		t.Fatalf("Expected a Thrift reply, got %d %s", resp.Code, resp.Body.String())
+	}
+	if seq := int32(binary.BigEndian.Uint32(resp.Body.Bytes()[8+len("RetrieveClientData"):])); seq != 99 {
+		t.Fatalf("Expected the caller's seqID 99, got %d", seq)
+	}
+
+	resp = postThrift(engine, "TravelService", framed(thriftMessage(1, "RetrieveClientData", 7)))
+	reply := resp.Body.Bytes()
+	if resp.Code != consts.StatusOK || int(binary.BigEndian.Uint32(reply)) != len(reply)-4 {
+		t.Fatalf("Expected a framed reply, got %d %v", resp.Code, reply)
+	}
+
+	if resp := postThrift(engine, "TravelService", thriftMessage(1, "noSuchMethod", 1)); resp.Code != consts.StatusNotFound {
+		t.Fatalf("Expected 404 for an undeclared method, got %d", resp.Code)
+	}
+	if resp := postThrift(engine, "ReviewService", thriftMessage(1, "deleteReview", 1)); resp.Code != consts.StatusGatewayTimeout {
+		t.Fatalf("Expected 504 for a timeout, got %d", resp.Code)
+	}
+	if resp := postThrift(engine, "TravelService", []byte(`{"userID":1}`)); resp.Code != consts.StatusBadRequest {
+		t.Fatalf("Expected 400 for a non-Thrift body, got %d", resp.Code)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("Expected 3 backend calls, got %v", seen)
+	}
+}
+
+func TestPassthroughAuthAndRateLimit(t *testing.T) {
+	var seen []string
+	cfg := passthroughConfig{Enabled: true, Auth: authConfig{Secret: "s3cret"}, RateLimit: rateLimitConfig{QPS: 1, Burst: 2}}
+	engine := newPassthroughEngine(cfg, &routeTable{}, &seen)
+	msg := thriftMessage(1, "RetrieveClientData", 1)
+
+	if resp := postThrift(engine, "TravelService", msg); resp.Code != consts.StatusUnauthorized {
+		t.Fatalf("Expected 401 without a token, got %d", resp.Code)
+	}
+	alice := ut.Header{Key: "Authorization", Value: "Bearer " + signHS256(t, `{"sub":"alice"}`, "s3cret")}
+	bob := ut.Header{Key: "Authorization", Value: "Bearer " + signHS256(t, `{"sub":"bob"}`, "s3cret")}
+	for i := 0; i < 2; i++ {
+		if resp := postThrift(engine, "TravelService", msg, alice); resp.Code != consts.StatusOK {
+			t.Fatalf("Request %d within the burst got %d", i, resp.Code)
+		}
+	}
+	if resp := postThrift(engine, "TravelService", msg, alice); resp.Code != consts.StatusTooManyRequests {
+		t.Fatalf("Expected 429 over the burst, got %d", resp.Code)
+	}
+	if resp := postThrift(engine, "TravelService", msg, bob); resp.Code != consts.StatusOK {
+		t.Fatalf("Another caller should have its own bucket, got %d", resp.Code)
+	}
+}
+
+func TestPassthroughFollowsTheRoutePolicy(t *testing.T) {
+	var seen []string
+	routes := &routeTable{}
+	routes.store(routePolicy{Routes: []routeRule{
+		{Service: "TravelService", Methods: []string{"RetrieveClientData"}, Disabled: true},
+		{Service: "TravelService", Public: true},
+	}})
+	engine := newPassthroughEngine(passthroughConfig{Enabled: true}, routes, &seen)
+
+	if resp := postThrift(engine, "TravelService", thriftMessage(1, "RetrieveClientData", 1)); resp.Code != consts.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 for a disabled route, got %d", resp.Code)
+	}
+	if resp := postThrift(engine, "ReviewService", thriftMessage(1, "sendReview", 1)); resp.Code != consts.StatusNotFound {
+		t.Fatalf("Expected 404 for an unrouted service, got %d", resp.Code)
+	}
+	if resp := postThrift(engine, "TravelService", thriftMessage(1, "GetAllTravelDestinations", 1)); resp.Code != consts.StatusOK {
+		t.Fatalf("Expected a routed method to go through, got %d", resp.Code)
+	}
+	if len(seen) != 1 {
+		t.Fatalf("Only the routed call should reach the backend, got %v", seen)
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	rl := newRateLimiter(rateLimitConfig{QPS: 2, Burst: 1})
+	now := time.Now()
+	if !rl.Allow("a", now) || rl.Allow("a", now) {
+		t.Fatalf("Expected a burst of exactly one")
+	}
+	if !rl.Allow("a", now.Add(500*time.Millisecond)) {
+		t.Fatalf("Expected a token after half a second at 2 QPS")
+	}
+	if newRateLimiter(rateLimitConfig{}) != nil || !(*rateLimiter)(nil).Allow("a", now) {
+		t.Fatalf("A zero QPS should turn limiting off")
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/cloud/circuitbreaker"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/kitex/pkg/circuitbreak"
+	"github.com/cloudwego/kitex/pkg/discovery"
+	"github.com/cloudwego/kitex/pkg/endpoint"
+	"github.com/cloudwego/kitex/pkg/loadbalance"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+)
+
+// Load balancers a service policy can pick.
+const (
+	lbWeightedRoundRobin = "weighted_round_robin"
+	lbWeightedRandom     = "weighted_random"
+	lbConsistentHash     = "consistent_hash"
+)
+
+/**
+ * servicePolicy is how the gateway calls one backend service. Policies come from the config
+ * file and can be replaced at runtime through the admin API; every generic client picks up the
+ * current policy when it is built.
+ */
+type servicePolicy struct {
+	// Timeout is the RPC timeout, 3s when unset.
+	Timeout duration `json:"timeout"`
+	// LoadBalancer is weighted_round_robin (the default), weighted_random or consistent_hash.
+	LoadBalancer string `json:"loadBalancer"`
+	// HashKey is what consistent_hash hashes on: a top-level request field such as "userID",
+	// or "header:X-User-Id" for a request header. It is required with consistent_hash.
+	HashKey string `json:"hashKey"`
+	// RateLimit caps the calls the gateway makes to the service, across all callers.
+	RateLimit rateLimitConfig `json:"rateLimit"`
+}
+
+/**
+ * Fills in the defaults and checks the load balancer name.
+ *
+ * @return The policy with defaults applied, or an error if a field is invalid.
+ */
+func (p servicePolicy) normalise() (servicePolicy, error) {
+	if p.Timeout < 0 {
+		return p, fmt.Errorf("timeout must not be negative")
+	}
+	if p.Timeout == 0 {
+		p.Timeout = duration(3 * time.Second)
+	}
+	switch p.LoadBalancer {
+	case "":
+		p.LoadBalancer = lbWeightedRoundRobin
+	case lbWeightedRoundRobin, lbWeightedRandom, lbConsistentHash:
+	default:
+		return p, fmt.Errorf("unknown load balancer %q", p.LoadBalancer)
+	}
+	if p.LoadBalancer == lbConsistentHash && strings.TrimPrefix(p.HashKey, hashKeyHeaderPrefix) == "" {
+		return p, fmt.Errorf("consistent_hash needs a hashKey")
+	}
+	if p.RateLimit.QPS < 0 || p.RateLimit.Burst < 0 {
+		return p, fmt.Errorf("rate limit must not be negative")
+	}
+	return p, nil
+}
+
+// balancer builds the Kitex load balancer the policy names.
+func (p servicePolicy) balancer() loadbalance.Loadbalancer {
+	switch p.LoadBalancer {
+	case lbWeightedRandom:
+		return loadbalance.NewWeightedRandomBalancer()
+	case lbConsistentHash:
+		// makeThriftCall and callBinaryThrift put the hash key on the context under
+		// ctxConsistentKey. Kitex fails a call whose key is empty, so calls without one are
+		// spread at random instead.
+		return loadbalance.NewConsistBalancer(loadbalance.NewConsistentHashOption(func(ctx context.Context, request interface{}) string {
+			key, _ := ctx.Value(ctxConsistentKey).(string)
+			if key == "" {
+				return strconv.FormatUint(rand.Uint64(), 36)
+			}
+			return key
+		}))
+	}
+	//NewWeightedBalancer creates a loadbalancer using weighted-round-robin algorithm.
+	return loadbalance.NewWeightedBalancer()
+}
+
+// hashKeyHeaderPrefix marks a hashKey that names a request header rather than a body field.
+const hashKeyHeaderPrefix = "header:"
+
+/**
+ * Finds the consistent hash key of a call from the policy's hashKey.
+ *
+ * @param body The JSON request body; nil for binary passthrough calls, which can only hash on
+ *             a header.
+ *
+ * @return The key, or "" when the call does not carry it.
+ */
+func (p servicePolicy) hashKeyOf(ctx context.Context, body map[string]interface{}) string {
+	if name := strings.TrimPrefix(p.HashKey, hashKeyHeaderPrefix); name != p.HashKey {
+		headers, _ := ctx.Value(ctxHeadersKey).(map[string]string)
+		return headers[strings.ToLower(name)]
+	}
+	switch v := body[p.HashKey].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ctxHeadersKey holds the headers of the request a call is made for, keyed by lower-case name.
+const ctxHeadersKey ctxKey = ctxTokenKey + 1
+
+/**
+ * Returns middleware that keeps the request's headers in the context, so a consistent_hash
+ * policy can hash on one of them for each call made while serving the request.
+ */
+func requestHeadersMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		headers := map[string]string{}
+		c.Request.Header.VisitAll(func(key, value []byte) {
+			headers[strings.ToLower(string(key))] = string(value)
+		})
+		c.Next(context.WithValue(ctx, ctxHeadersKey, headers))
+	}
+}
+
+/**
+ * policyStore holds the current policy of every service and the limiter enforcing it.
+ */
+type policyStore struct {
+	mu       sync.RWMutex
+	policies map[string]servicePolicy
+	limiters map[string]*rateLimiter
+}
+
+/**
+ * Creates a policy store from the policies in the config file.
+ *
+ * @return An error naming the first service whose policy is invalid.
+ */
+func newPolicyStore(initial map[string]servicePolicy) (*policyStore, error) {
+	s := &policyStore{policies: map[string]servicePolicy{}, limiters: map[string]*rateLimiter{}}
+	for service, p := range initial {
+		if _, err := s.set(service, p); err != nil {
+			return nil, fmt.Errorf("policy for %s: %w", service, err)
+		}
+	}
+	return s, nil
+}
+
+// get returns the policy of a service, the defaults when it has none.
+func (s *policyStore) get(service string) servicePolicy {
+	s.mu.RLock()
+	p, ok := s.policies[service]
+	s.mu.RUnlock()
+	if !ok {
+		p, _ = servicePolicy{}.normalise()
+	}
+	return p
+}
+
+/**
+ * Replaces the policy of a service. The rate limiter restarts with a full bucket.
+ *
+ * @return The policy it replaced, and an error if the new one is invalid.
+ */
+func (s *policyStore) set(service string, p servicePolicy) (servicePolicy, error) {
+	p, err := p.normalise()
+	if err != nil {
+		return servicePolicy{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Read under the same lock as the write, so concurrent sets each return the policy they replaced.
+	previous, ok := s.policies[service]
+	if !ok {
+		previous, _ = servicePolicy{}.normalise()
+	}
+	s.policies[service] = p
+	s.limiters[service] = newRateLimiter(p.RateLimit)
+	return previous, nil
+}
+
+// all returns a copy of every explicitly set policy.
+func (s *policyStore) all() map[string]servicePolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]servicePolicy, len(s.policies))
+	for service, p := range s.policies {
+		out[service] = p
+	}
+	return out
+}
+
+// admit takes a token from the service's limiter; services without a limit always pass.
+func (s *policyStore) admit(service string, now time.Time) bool {
+	s.mu.RLock()
+	limiter := s.limiters[service]
+	s.mu.RUnlock()
+	return limiter.Allow(service, now)
+}
+
+// gatewayPolicies is loaded in main from the config file, and later from Nacos when dynamic config is on.
+var gatewayPolicies, _ = newPolicyStore(nil)
+
+/**
+ * drainSet is the backend instances, by host:port, that get no new calls.
+ */
+type drainSet struct {
+	mu        sync.RWMutex
+	addresses map[string]time.Time
+}
+
+func (d *drainSet) add(address string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.addresses[address]; ok {
+		return false
+	}
+	d.addresses[address] = time.Now()
+	return true
+}
+
+func (d *drainSet) remove(address string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.addresses[address]; !ok {
+		return false
+	}
+	delete(d.addresses, address)
+	return true
+}
+
+func (d *drainSet) has(address string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, ok := d.addresses[address]
+	return ok
+}
+
+// list returns the drained addresses with the time each was drained, sorted by address.
+func (d *drainSet) list() []map[string]interface{} {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	out := []map[string]interface{}{}
+	for address, since := range d.addresses {
+		out = append(out, map[string]interface{}{"address": address, "since": since.UTC().Format(time.RFC3339)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i]["address"].(string) < out[j]["address"].(string) })
+	return out
+}
+
+// filter drops drained instances from a resolver result.
+func (d *drainSet) filter(res discovery.Result) discovery.Result {
+	kept := make([]discovery.Instance, 0, len(res.Instances))
+	for _, ins := range res.Instances {
+		if !d.has(ins.Address().String()) {
+			kept = append(kept, ins)
+		}
+	}
+	res.Instances = kept
+	return res
+}
+
+var drainedInstances = &drainSet{addresses: map[string]time.Time{}}
+
+/**
+ * drainingResolver hides drained instances from the load balancer. Generic clients are built
+ * per request with their own balancer, which Kitex does not cache, so every call resolves
+ * afresh and a drain or undrain applies to the next call.
+ */
+type drainingResolver struct {
+	discovery.Resolver
+}
+
+func (r drainingResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	res, err := r.Resolver.Resolve(ctx, desc)
+	if err != nil {
+		return res, err
+	}
+	return drainedInstances.filter(res), nil
+}
+
+/**
+ * Generic clients are built per request, so the service-level circuit breakers live in one
+ * suite shared by all of them, keyed by service and method.
+ */
+var (
+	gatewayBreakers = circuitbreak.NewCBSuite(func(ri rpcinfo.RPCInfo) string {
+		return ri.To().ServiceName() + "/" + ri.To().Method()
+	})
+	serviceBreakerMW endpoint.Middleware = gatewayBreakers.ServiceCBMW()
+)
+
+/**
+ * Reports every service-level breaker the gateway has created, closed ones included.
+ *
+ * @return One entry per service/method key with its state and the last 10s of outcomes.
+ */
+func breakerStates() []map[string]interface{} {
+	out := []map[string]interface{}{}
+	dumper, ok := gatewayBreakers.ServicePanel().(interface {
+		DumpBreakers() map[string]circuitbreaker.Breaker
+	})
+	if !ok {
+		return out
+	}
+	for key, b := range dumper.DumpBreakers() {
+		m := b.Metricer()
+		out = append(out, map[string]interface{}{
+			"key":       key,
+			"state":     b.State().String(),
+			"successes": m.Successes(),
+			"failures":  m.Failures(),
+			"timeouts":  m.Timeouts(),
+			"errorRate": m.ErrorRate(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i]["key"].(string) < out[j]["key"].(string) })
+	return out
+}
+
+/**
+ * Replaces every policy at once, as when a new config arrives from Nacos. Nothing changes
+ * unless all the new policies are valid.
+ *
+ * @return An error naming the first service whose policy is invalid.
+ */
+func (s *policyStore) replace(policies map[string]servicePolicy) error {
+	next := map[string]servicePolicy{}
+	limiters := map[string]*rateLimiter{}
+	for service, p := range policies {
+		p, err := p.normalise()
+		if err != nil {
+			return fmt.Errorf("policy for %s: %w", service, err)
+		}
+		next[service] = p
+		limiters[service] = newRateLimiter(p.RateLimit)
+	}
+	s.mu.Lock()
+	s.policies = next
+	s.limiters = limiters
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+ * rateLimitConfig allows each caller QPS requests per second on average, with bursts of up to
+ * Burst requests. A QPS of 0 turns limiting off.
+ */
+type rateLimitConfig struct {
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+/**
+ * rateLimiter keeps one token bucket per caller key.
+ */
+type rateLimiter struct {
+	qps   float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+/**
+ * Creates a rate limiter.
+ *
+ * @return nil when the config turns limiting off; a nil limiter allows everything.
+ */
+func newRateLimiter(cfg rateLimitConfig) *rateLimiter {
+	if cfg.QPS <= 0 {
+		return nil
+	}
+	burst := float64(cfg.Burst)
+	if burst < 1 {
+		burst = cfg.QPS
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return &rateLimiter{qps: cfg.QPS, burst: burst, buckets: map[string]*tokenBucket{}}
+}
+
+/**
+ * Takes a token from the caller's bucket.
+ *
+ * @return false when the caller is over its limit.
+ */
+func (rl *rateLimiter) Allow(key string, now time.Time) bool {
+	if rl == nil {
+		return true
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Forget callers whose buckets have refilled, so the map does not grow with every client seen.
+	if now.Sub(rl.swept) > time.Minute {
+		for k, b := range rl.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*rl.qps >= rl.burst {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.swept = now
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rl.qps
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
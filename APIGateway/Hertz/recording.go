@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"hertz_demo/traffic"
+)
+
+/**
+ * recordingConfig enables recording of POST /{service}/{method} calls for cmd/replay.
+ *
+ * SampleRate is the share of calls, between 0 and 1, that are recorded. Files in Dir rotate
+ * after MaxFileBytes, and only the newest MaxFiles are kept. Methods, as "Service/method", limits
+ * recording to those methods; every method is recorded when it is empty. Redact names request
+ * and response fields, at any depth, whose values are never written.
+ */
+type recordingConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Dir          string   `json:"dir"`
+	SampleRate   float64  `json:"sampleRate"`
+	MaxFileBytes int64    `json:"maxFileBytes"`
+	MaxFiles     int      `json:"maxFiles"`
+	Methods      []string `json:"methods"`
+	Redact       []string `json:"redact"`
+}
+
+var recordedCalls = newCounterVec("gateway_recorded_calls_total",
+	"Calls written to the traffic recording, and calls that could not be written.", "service", "method", "outcome")
+
+/**
+ * recorder samples calls and writes them, redacted, to rotating files.
+ */
+type recorder struct {
+	cfg     recordingConfig
+	writer  *traffic.Writer
+	methods map[string]bool
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+/**
+ * Creates the recorder for the config.
+ *
+ * @return nil when recording is off.
+ * @return An error if the sample rate is out of range or the directory cannot be created.
+ */
+func newRecorder(cfg recordingConfig) (*recorder, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("recording.sampleRate must be between 0 and 1, got %v", cfg.SampleRate)
+	}
+	w, err := traffic.NewWriter(cfg.Dir, cfg.MaxFileBytes, cfg.MaxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("error creating recording directory %s: %w", cfg.Dir, err)
+	}
+	r := &recorder{cfg: cfg, writer: w, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	if len(cfg.Methods) > 0 {
+		r.methods = map[string]bool{}
+		for _, m := range cfg.Methods {
+			r.methods[m] = true
+		}
+	}
+	return r, nil
+}
+
+/**
+ * Records one call if it is sampled. Failures to write are counted and logged, never returned,
+ * so recording cannot fail a call.
+ *
+ * @param service The service called.
+ * @param method The Thrift method called.
+ * @param body The JSON request body.
+ * @param status The HTTP status the gateway answered with.
+ * @param response The response returned to the client, for 200s.
+ * @param callErr The error returned to the client otherwise.
+ * @param elapsed How long the call took.
+ */
+func (r *recorder) record(service string, method string, body map[string]interface{}, status int, response interface{}, callErr error, elapsed time.Duration) {
+	if r == nil || (r.methods != nil && !r.methods[service+"/"+method]) {
+		return
+	}
+	r.mu.Lock()
+	sampled := r.rnd.Float64() < r.cfg.SampleRate
+	r.mu.Unlock()
+	if !sampled {
+		return
+	}
+
+	rec := traffic.Record{
+		Time:       time.Now().UTC(),
+		Service:    service,
+		Method:     method,
+		Request:    traffic.Redact(requestFields(body), r.cfg.Redact).(map[string]interface{}),
+		Status:     status,
+		Response:   traffic.Redact(response, r.cfg.Redact),
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+	}
+	if callErr != nil {
+		rec.Error = callErr.Error()
+	}
+	if err := r.writer.Write(rec); err != nil {
+		fmt.Println("Error recording call:", err)
+		recordedCalls.Inc(service, method, "error")
+		return
+	}
+	recordedCalls.Inc(service, method, "recorded")
+}
+
+// gatewayRecorder is built from the config, nil when recording is off.
+var gatewayRecorder *recorder
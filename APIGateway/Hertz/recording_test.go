@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+
+	"hertz_demo/traffic"
+)
+
+func TestRecordedCallsReplayAgainstTheGateway(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := newRecorder(recordingConfig{Enabled: true, Dir: dir, SampleRate: 1, Redact: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { gatewayRecorder = nil }()
+	gatewayRecorder = rec
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/:serviceName/:methodName", genericPostHandler)
+	post := func(path string, body string) (int, string) {
+		w := ut.PerformRequest(engine, consts.MethodPost, path, &ut.Body{Body: strings.NewReader(body), Len: len(body)})
+		return w.Code, w.Body.String()
+	}
+	post("/TravelService/RetrieveClientData", `{"userID": 7, "password": "hunter2"}`)
+	post("/ReviewService/sendReview", `{"data": "great trip", "userID": 1}`)
+	post("/ReviewService/sendReview", `{"userID": 1}`)
+	rec.writer.Close()
+
+	records, err := traffic.Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if records[0].Request["password"] != traffic.Redacted || records[0].Method != "RetrieveClientData" || records[0].Status != consts.StatusOK {
+		t.Fatalf("Unexpected first record %+v", records[0])
+	}
+	if records[1].Request["Msg"] != "great trip" {
+		t.Fatalf("Expected the request under its IDL names, got %v", records[1].Request)
+	}
+	if records[2].Status != consts.StatusBadRequest || !strings.HasPrefix(records[2].Error, errInvalidInputs.Error()) {
+		t.Fatalf("Expected the rejected call, got %+v", records[2])
+	}
+
+	gatewayRecorder = nil
+	send := func(ctx context.Context, r traffic.Record) (int, interface{}, error) {
+		body, _ := json.Marshal(r.Request)
+		code, resp := post("/"+r.Service+"/"+r.Method, string(body))
+		var decoded interface{}
+		if code == consts.StatusOK {
+			if err := json.Unmarshal([]byte(resp), &decoded); err != nil {
+				return 0, nil, err
+			}
+		}
+		return code, decoded, nil
+	}
+	report := traffic.Replay(context.Background(), records, send, nil)
+	if report.Differed() {
+		t.Fatalf("Expected the replay to match, got %+v", report.Results)
+	}
+	if got := report.ByMethod["TravelService.RetrieveClientData"]; got.Skipped != 1 {
+		t.Fatalf("Expected the call with a redacted password to be skipped, got %+v", got)
+	}
+
+	records[1].Response.(map[string]interface{})["extra"] = "unexpected"
+	report = traffic.Replay(context.Background(), records, send, nil)
+	if got := report.ByMethod["ReviewService.sendReview"]; got.Differed != 1 {
+		t.Fatalf("Expected the changed response to differ, got %+v", got)
+	}
+	if diffs := report.Results[1].Diffs; len(diffs) != 1 || diffs[0] != `extra: "unexpected" != <absent>` {
+		t.Fatalf("Unexpected diffs %v", diffs)
+	}
+}
+
+func TestRecorderSamplesAndFiltersMethods(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := newRecorder(recordingConfig{Enabled: true, Dir: dir, SampleRate: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.record("TravelService", "RetrieveClientData", map[string]interface{}{"userID": 1.0}, consts.StatusOK, nil, nil, 0)
+
+	only, err := newRecorder(recordingConfig{Enabled: true, Dir: dir, SampleRate: 1, Methods: []string{"ReviewService/sendReview"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	only.record("TravelService", "RetrieveClientData", map[string]interface{}{"userID": 1.0}, consts.StatusOK, nil, nil, 0)
+	only.record("ReviewService", "sendReview", map[string]interface{}{"Msg": "hi", "userID": 1.0}, consts.StatusOK, nil, nil, 0)
+	only.writer.Close()
+
+	records, err := traffic.Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Method != "sendReview" {
+		t.Fatalf("Expected only the sampled, listed method, got %+v", records)
+	}
+
+	if _, err := newRecorder(recordingConfig{Enabled: true, Dir: dir, SampleRate: 2}); err == nil {
+		t.Fatal("Expected a sample rate above 1 to be rejected")
+	}
+	if r, err := newRecorder(recordingConfig{}); r != nil || err != nil {
+		t.Fatalf("Expected no recorder when disabled, got %v %v", r, err)
+	}
+}
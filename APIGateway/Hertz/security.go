@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+/**
+ * corsRule is the CORS policy for requests whose path starts with Path. When several rules
+ * match, the one with the longest Path wins.
+ */
+type corsRule struct {
+	Path             string   `json:"path"`
+	AllowOrigins     []string `json:"allowOrigins"`
+	AllowMethods     []string `json:"allowMethods"`
+	AllowHeaders     []string `json:"allowHeaders"`
+	ExposeHeaders    []string `json:"exposeHeaders"`
+	AllowCredentials bool     `json:"allowCredentials"`
+	MaxAge           duration `json:"maxAge"`
+}
+
+/**
+ * securityConfig holds the browser-facing and request hardening settings.
+ *
+ * Headers are added to every response; set a header to "" to drop one of the defaults.
+ * MaxBodyBytes bounds request bodies (413 beyond it) and MaxJSONDepth bounds the nesting of
+ * JSON bodies (400 beyond it). Zero disables a limit.
+ */
+type securityConfig struct {
+	CORS         []corsRule        `json:"cors"`
+	Headers      map[string]string `json:"headers"`
+	MaxBodyBytes int               `json:"maxBodyBytes"`
+	MaxJSONDepth int               `json:"maxJSONDepth"`
+}
+
+/**
+ * Returns the security headers sent when the config does not override them.
+ */
+func defaultSecurityHeaders() map[string]string {
+	return map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "no-referrer",
+	}
+}
+
+/**
+ * Finds the CORS rule for a request path.
+ *
+ * @return The rule with the longest matching Path, or nil when no rule matches.
+ */
+func matchCORSRule(rules []corsRule, path string) *corsRule {
+	var best *corsRule
+	for i := range rules {
+		if strings.HasPrefix(path, rules[i].Path) && (best == nil || len(rules[i].Path) > len(best.Path)) {
+			best = &rules[i]
+		}
+	}
+	return best
+}
+
+// allowedOrigin returns the value for Access-Control-Allow-Origin, or "" when origin is not allowed.
+func (r *corsRule) allowedOrigin(origin string) string {
+	for _, allowed := range r.AllowOrigins {
+		if allowed == origin {
+			return origin
+		}
+		if allowed == "*" {
+			// Credentialed requests may not use the wildcard, so echo the origin instead.
+			if r.AllowCredentials {
+				return origin
+			}
+			return "*"
+		}
+	}
+	return ""
+}
+
+/**
+ * Hertz middleware applying CORS rules, answering preflight requests itself.
+ *
+ * Requests without an Origin header, or from an origin the matching rule does not allow, pass
+ * through without CORS headers, so browsers block the response while other clients are unaffected.
+ */
+func corsMiddleware(rules []corsRule) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		origin := string(c.GetHeader("Origin"))
+		rule := matchCORSRule(rules, string(c.Path()))
+		if origin == "" || rule == nil {
+			c.Next(ctx)
+			return
+		}
+		allowOrigin := rule.allowedOrigin(origin)
+		if allowOrigin == "" {
+			c.Next(ctx)
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", allowOrigin)
+		c.Response.Header.Add("Vary", "Origin")
+		if rule.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		preflight := string(c.Method()) == consts.MethodOptions && len(c.GetHeader("Access-Control-Request-Method")) > 0
+		if !preflight {
+			if len(rule.ExposeHeaders) > 0 {
+				c.Header("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+			}
+			c.Next(ctx)
+			return
+		}
+
+		methods := rule.AllowMethods
+		if len(methods) == 0 {
+			methods = []string{consts.MethodGet, consts.MethodPost}
+		}
+		c.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(rule.AllowHeaders) > 0 {
+			c.Header("Access-Control-Allow-Headers", strings.Join(rule.AllowHeaders, ", "))
+		} else if requested := c.GetHeader("Access-Control-Request-Headers"); len(requested) > 0 {
+			c.Header("Access-Control-Allow-Headers", string(requested))
+		}
+		if rule.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", strconv.Itoa(int(time.Duration(rule.MaxAge).Seconds())))
+		}
+		c.AbortWithStatus(consts.StatusNoContent)
+	}
+}
+
+/**
+ * Hertz middleware adding the configured security headers to every response.
+ */
+func securityHeadersMiddleware(headers map[string]string) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		for name, value := range headers {
+			if value != "" {
+				c.Header(name, value)
+			}
+		}
+		c.Next(ctx)
+	}
+}
+
+// looksLikeJSON reports whether a body starts like a JSON object or array, ignoring leading space.
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+/**
+ * Checks that a JSON document does not nest objects and arrays deeper than maxDepth. The scan
+ * only tracks brackets outside strings, so it is cheap and runs before any decoding.
+ *
+ * @return An error naming the limit when the document is too deep.
+ */
+func checkJSONDepth(data []byte, maxDepth int) error {
+	depth := 0
+	inString, escaped := false, false
+	for _, b := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("JSON body nests deeper than %d levels", maxDepth)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+/**
+ * Hertz middleware enforcing the body size and JSON depth limits before any handler reads the body.
+ */
+func requestLimitsMiddleware(cfg securityConfig) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if cfg.MaxBodyBytes > 0 && (c.Request.Header.ContentLength() > cfg.MaxBodyBytes || len(c.Request.Body()) > cfg.MaxBodyBytes) {
+			c.AbortWithStatusJSON(consts.StatusRequestEntityTooLarge, utils.H{
+				"error": fmt.Sprintf("request body exceeds %d bytes", cfg.MaxBodyBytes),
+			})
+			return
+		}
+		if cfg.MaxJSONDepth > 0 && looksLikeJSON(c.Request.Body()) {
+			if err := checkJSONDepth(c.Request.Body(), cfg.MaxJSONDepth); err != nil {
+				c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+				return
+			}
+		}
+		c.Next(ctx)
+	}
+}
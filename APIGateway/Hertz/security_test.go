@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+func newSecurityEngine(cfg securityConfig) *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(securityHeadersMiddleware(cfg.Headers), corsMiddleware(cfg.CORS), requestLimitsMiddleware(cfg))
+	engine.POST("/:serviceName/:methodName", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(consts.StatusOK, map[string]interface{}{"ok": true})
+	})
+	return engine
+}
+
+func TestCORSPreflightAndSimpleRequests(t *testing.T) {
+	engine := newSecurityEngine(securityConfig{CORS: []corsRule{
+		{Path: "/", AllowOrigins: []string{"http://app.example"}},
+		{Path: "/ReviewService/", AllowOrigins: []string{"http://reviews.example"}, AllowMethods: []string{"POST"}, AllowCredentials: true},
+	}})
+
+	preflight := ut.PerformRequest(engine, consts.MethodOptions, "/ReviewService/sendReview", nil,
+		ut.Header{Key: "Origin", Value: "http://reviews.example"},
+		ut.Header{Key: "Access-Control-Request-Method", Value: "POST"},
+		ut.Header{Key: "Access-Control-Request-Headers", Value: "Content-Type"})
+	if preflight.Code != consts.StatusNoContent {
+		t.Fatalf("Preflight should be answered with 204, got %d", preflight.Code)
+	}
+	if got := preflight.Header().Get("Access-Control-Allow-Origin"); got != "http://reviews.example" {
+		t.Fatalf("The longest matching rule should apply, got origin %q", got)
+	}
+	if got := preflight.Header().Get("Access-Control-Allow-Methods"); got != "POST" {
+		t.Fatalf("Unexpected allowed methods %q", got)
+	}
+	if preflight.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal("Credentials should be allowed by the ReviewService rule")
+	}
+
+	other := ut.PerformRequest(engine, consts.MethodPost, "/ReviewService/sendReview", nil,
+		ut.Header{Key: "Origin", Value: "http://app.example"})
+	if other.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("An origin not allowed by the matching rule should get no CORS headers")
+	}
+
+	simple := ut.PerformRequest(engine, consts.MethodPost, "/TravelService/RetrieveClientData", nil,
+		ut.Header{Key: "Origin", Value: "http://app.example"})
+	if simple.Code != consts.StatusOK || simple.Header().Get("Access-Control-Allow-Origin") != "http://app.example" {
+		t.Fatalf("Simple request should pass with CORS headers, got %d %q", simple.Code, simple.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestSecurityHeadersAndRequestLimits(t *testing.T) {
+	engine := newSecurityEngine(securityConfig{Headers: defaultSecurityHeaders(), MaxBodyBytes: 64, MaxJSONDepth: 3})
+
+	ok := ut.PerformRequest(engine, consts.MethodPost, "/TravelService/RetrieveClientData", &ut.Body{Body: strings.NewReader(`{"a":{"b":[1]}}`), Len: 15})
+	if ok.Code != consts.StatusOK {
+		t.Fatalf("A body within the limits should pass, got %d", ok.Code)
+	}
+	if ok.Header().Get("X-Content-Type-Options") != "nosniff" || ok.Header().Get("X-Frame-Options") != "DENY" {
+		t.Fatal("Security headers should be added to responses")
+	}
+
+	large := strings.Repeat("x", 100)
+	if resp := ut.PerformRequest(engine, consts.MethodPost, "/TravelService/RetrieveClientData", &ut.Body{Body: strings.NewReader(large), Len: len(large)}); resp.Code != consts.StatusRequestEntityTooLarge {
+		t.Fatalf("An oversized body should give 413, got %d", resp.Code)
+	}
+
+	deep := `{"a":{"b":{"c":[1]}}}`
+	if resp := ut.PerformRequest(engine, consts.MethodPost, "/TravelService/RetrieveClientData", &ut.Body{Body: strings.NewReader(deep), Len: len(deep)}); resp.Code != consts.StatusBadRequest {
+		t.Fatalf("A body nested too deeply should give 400, got %d", resp.Code)
+	}
+}
+
+func TestCheckJSONDepthIgnoresBracketsInStrings(t *testing.T) {
+	if err := checkJSONDepth([]byte(`{"text":"[[[[{{{{ \" ]]"}`), 1); err != nil {
+		t.Fatalf("Brackets inside strings should not count, got %v", err)
+	}
+	if err := checkJSONDepth([]byte(`[[1]]`), 1); err == nil {
+		t.Fatal("Expected a depth error")
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/kitex/pkg/discovery"
+
+	"hertz_demo/traffic"
+)
+
+// ctxShadowKey marks the context of a call mirrored to shadow instances.
+const ctxShadowKey ctxKey = ctxNamespaceKey + 1
+
+// metaShadow is the instance metadata key that marks a candidate build; "true" makes it a shadow.
+const metaShadow = "shadow"
+
+/**
+ * shadowPolicy mirrors Percent (0 to 100) of the calls to Methods to a service's shadow
+ * instances. Methods must be listed, as mirroring a write such as sendReview repeats it on the
+ * shadow build; only the listed methods are mirrored.
+ */
+type shadowPolicy struct {
+	Percent float64  `json:"percent"`
+	Methods []string `json:"methods"`
+	Timeout duration `json:"timeout"`
+}
+
+/**
+ * shadowConfig mirrors live calls to instances registered with shadow=true, and compares
+ * their responses with the primary ones. Shadow responses are never returned to clients.
+ *
+ * DiffSampleRate is the share of differing calls, between 0 and 1, whose diffs are kept; the
+ * newest MaxDiffs are kept. Ignore names response fields left out of the comparison.
+ * At most MaxInFlight shadow calls run at once; calls beyond that are not mirrored.
+ */
+type shadowConfig struct {
+	Services       map[string]shadowPolicy `json:"services"`
+	DiffSampleRate float64                 `json:"diffSampleRate"`
+	MaxDiffs       int                     `json:"maxDiffs"`
+	Ignore         []string                `json:"ignore"`
+	MaxInFlight    int                     `json:"maxInFlight"`
+}
+
+// Shadow comparison outcomes, the outcome label of gateway_shadow_calls_total.
+const (
+	shadowMatch        = "match"
+	shadowDiff         = "diff"
+	shadowError        = "shadow_error"
+	shadowPrimaryError = "primary_error"
+	shadowDropped      = "dropped"
+)
+
+var shadowCalls = newCounterVec("gateway_shadow_calls_total",
+	"Calls mirrored to shadow instances, by how the shadow response compared with the primary one.", "service", "method", "outcome")
+
+/**
+ * shadowDiffEntry is one sampled difference between a shadow and a primary response.
+ */
+type shadowDiffEntry struct {
+	Time    string                 `json:"time"`
+	Service string                 `json:"service"`
+	Method  string                 `json:"method"`
+	Request map[string]interface{} `json:"request"`
+	// Diffs lists the differing response fields, written primary != shadow.
+	Diffs        []string `json:"diffs,omitempty"`
+	PrimaryError string   `json:"primaryError,omitempty"`
+	ShadowError  string   `json:"shadowError,omitempty"`
+}
+
+/**
+ * shadowMirror sends a share of calls again to shadow instances, after the primary call, and
+ * compares the answers.
+ */
+type shadowMirror struct {
+	cfg shadowConfig
+	// call reaches the shadow instances.
+	call     genericCaller
+	methods  map[string]map[string]bool
+	inFlight chan struct{}
+
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	diffs []shadowDiffEntry
+}
+
+/**
+ * Creates the mirror for the config.
+ *
+ * @param cfg The shadow section of the config.
+ * @param call The caller that reaches a service's shadow instances.
+ *
+ * @return nil when no service is mirrored.
+ * @return An error if a percentage or the sample rate is out of range, or a service lists no
+ *         methods.
+ */
+func newShadowMirror(cfg shadowConfig, call genericCaller) (*shadowMirror, error) {
+	if len(cfg.Services) == 0 {
+		return nil, nil
+	}
+	if cfg.DiffSampleRate < 0 || cfg.DiffSampleRate > 1 {
+		return nil, fmt.Errorf("shadow.diffSampleRate must be between 0 and 1, got %v", cfg.DiffSampleRate)
+	}
+	if cfg.MaxInFlight <= 0 {
+		return nil, fmt.Errorf("shadow.maxInFlight must be positive, got %d", cfg.MaxInFlight)
+	}
+	s := &shadowMirror{
+		cfg:      cfg,
+		call:     call,
+		methods:  map[string]map[string]bool{},
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for service, p := range cfg.Services {
+		if p.Percent < 0 || p.Percent > 100 {
+			return nil, fmt.Errorf("shadow.services.%s.percent must be between 0 and 100, got %v", service, p.Percent)
+		}
+		if len(p.Methods) == 0 {
+			return nil, fmt.Errorf("shadow.services.%s.methods must list the methods to mirror", service)
+		}
+		s.methods[service] = map[string]bool{}
+		for _, m := range p.Methods {
+			s.methods[service][m] = true
+		}
+	}
+	return s, nil
+}
+
+// sampled reports whether a call to service.method is mirrored.
+func (s *shadowMirror) sampled(service string, method string) bool {
+	p, ok := s.cfg.Services[service]
+	if !ok || !s.methods[service][method] {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64()*100 < p.Percent
+}
+
+/**
+ * Wraps a caller so sampled calls are mirrored once the primary call has answered. The
+ * caller's response and error are returned unchanged and never wait for the shadow.
+ *
+ * @param fetch The caller that reaches the primary instances.
+ */
+func (s *shadowMirror) Wrap(fetch genericCaller) genericCaller {
+	if s == nil {
+		return fetch
+	}
+	return func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		resp, err := fetch(ctx, service, method, body)
+		if !s.sampled(service, method) {
+			return resp, err
+		}
+		select {
+		case s.inFlight <- struct{}{}:
+		default:
+			shadowCalls.Inc(service, method, shadowDropped)
+			return resp, err
+		}
+
+		// The shadow call must outlive the request, so it gets a fresh context that only keeps
+		// the namespace the request was routed to.
+		shadowCtx := context.Background()
+		if routedByHeader(ctx) {
+			shadowCtx = context.WithValue(shadowCtx, ctxNamespaceKey, requestNamespace(ctx))
+		}
+		request := requestFields(body)
+		go func() {
+			defer func() { <-s.inFlight }()
+			timeout := time.Duration(s.cfg.Services[service].Timeout)
+			if timeout <= 0 {
+				timeout = time.Duration(gatewayPolicies.get(service).Timeout)
+			}
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				shadowCtx, cancel = context.WithTimeout(shadowCtx, timeout)
+				defer cancel()
+			}
+			shadowResp, shadowErr := s.call(shadowCtx, service, method, request)
+			s.compare(service, method, request, resp, err, shadowResp, shadowErr)
+		}()
+		return resp, err
+	}
+}
+
+// compare counts the outcome of a mirrored call and samples its diff.
+func (s *shadowMirror) compare(service string, method string, request map[string]interface{}, primary interface{}, primaryErr error, shadow interface{}, shadowErr error) {
+	entry := shadowDiffEntry{Service: service, Method: method, Request: request}
+	outcome := shadowMatch
+	switch {
+	case primaryErr != nil && shadowErr != nil:
+		// Both rejected the call; the messages carry addresses, so they are not compared.
+	case primaryErr != nil:
+		outcome, entry.PrimaryError = shadowPrimaryError, primaryErr.Error()
+	case shadowErr != nil:
+		outcome, entry.ShadowError = shadowError, shadowErr.Error()
+	default:
+		if entry.Diffs = traffic.Diff(primary, shadow, s.cfg.Ignore); len(entry.Diffs) > 0 {
+			outcome = shadowDiff
+		}
+	}
+	shadowCalls.Inc(service, method, outcome)
+	if outcome == shadowMatch {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rnd.Float64() >= s.cfg.DiffSampleRate {
+		return
+	}
+	entry.Time = time.Now().UTC().Format(time.RFC3339)
+	fmt.Printf("Shadow %s of %s.%s: %v %s %s\n", outcome, service, method, entry.Diffs, entry.PrimaryError, entry.ShadowError)
+	s.diffs = append(s.diffs, entry)
+	if over := len(s.diffs) - s.cfg.MaxDiffs; over > 0 {
+		s.diffs = append([]shadowDiffEntry(nil), s.diffs[over:]...)
+	}
+}
+
+/**
+ * Returns the sampled diffs, oldest first.
+ *
+ * @param service Only diffs of this service, or every service when empty.
+ * @param method Only diffs of this method, or every method when empty.
+ */
+func (s *shadowMirror) sampledDiffs(service string, method string) []shadowDiffEntry {
+	out := []shadowDiffEntry{}
+	if s == nil {
+		return out
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.diffs {
+		if (service == "" || d.Service == service) && (method == "" || d.Method == method) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// isShadowCall reports whether a call is a mirror meant for shadow instances.
+func isShadowCall(ctx context.Context) bool {
+	shadow, _ := ctx.Value(ctxShadowKey).(bool)
+	return shadow
+}
+
+/**
+ * shadowResolver splits a service's instances into shadow and primary ones. Shadow clients see
+ * only instances with shadow=true, and every other client sees only the rest, so shadow builds
+ * never answer clients.
+ */
+type shadowResolver struct {
+	discovery.Resolver
+	shadow bool
+}
+
+func (r shadowResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	res, err := r.Resolver.Resolve(ctx, desc)
+	if err != nil {
+		return res, err
+	}
+	kept := make([]discovery.Instance, 0, len(res.Instances))
+	for _, ins := range res.Instances {
+		tag, _ := ins.Tag(metaShadow)
+		if (tag == "true") == r.shadow {
+			kept = append(kept, ins)
+		}
+	}
+	if len(kept) == 0 && len(res.Instances) > 0 {
+		if r.shadow {
+			return discovery.Result{}, fmt.Errorf("no shadow instance of %s is registered", desc)
+		}
+		return discovery.Result{}, fmt.Errorf("every instance of %s is a shadow instance", desc)
+	}
+	res.Instances = kept
+	if r.shadow {
+		res.CacheKey += "#shadow"
+	}
+	return res, nil
+}
+
+// Name keeps Kitex's balancer cache for shadow instances apart from the primary one.
+func (r shadowResolver) Name() string {
+	if r.shadow {
+		return r.Resolver.Name() + ":shadow"
+	}
+	return r.Resolver.Name()
+}
+
+// gatewayShadow is built from the config, nil when no service is mirrored.
+var gatewayShadow *shadowMirror
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hertz_demo/nacostest"
+)
+
+// waitForCount waits for a counter to reach want, as shadow calls finish in the background.
+func waitForCount(t *testing.T, c *counterVec, want float64, labels ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Value(labels...) < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s%v to reach %v, got %v", c.name, labels, want, c.Value(labels...))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShadowMirrorComparesWithoutChangingTheAnswer(t *testing.T) {
+	var shadowed int32
+	mirror, err := newShadowMirror(shadowConfig{
+		Services:       map[string]shadowPolicy{"ReviewService": {Percent: 100, Methods: []string{"sendReview"}}},
+		DiffSampleRate: 1,
+		MaxDiffs:       1,
+		Ignore:         []string{"BaseResp"},
+		MaxInFlight:    4,
+	}, func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		atomic.AddInt32(&shadowed, 1)
+		if body["userID"] == 2.0 {
+			return nil, errors.New("shadow failed")
+		}
+		return map[string]interface{}{"action": fmt.Sprint("shadow ", body["Msg"]), "BaseResp": "ignored"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := mirror.Wrap(func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"action": fmt.Sprint("primary ", body["Msg"])}, nil
+	})
+
+	before := shadowCalls.Value("ReviewService", "sendReview", shadowDiff)
+	resp, err := call(context.Background(), "ReviewService", "sendReview", map[string]interface{}{"Msg": "hi", "userID": 1.0})
+	if err != nil || resp.(map[string]interface{})["action"] != "primary hi" {
+		t.Fatalf("Expected the primary answer, got %v %v", resp, err)
+	}
+	waitForCount(t, shadowCalls, before+1, "ReviewService", "sendReview", shadowDiff)
+
+	beforeErr := shadowCalls.Value("ReviewService", "sendReview", shadowError)
+	call(context.Background(), "ReviewService", "sendReview", map[string]interface{}{"Msg": "hi", "userID": 2.0})
+	waitForCount(t, shadowCalls, beforeErr+1, "ReviewService", "sendReview", shadowError)
+
+	call(context.Background(), "ReviewService", "deleteReview", map[string]interface{}{"reviewID": 1.0})
+	if n := atomic.LoadInt32(&shadowed); n != 2 {
+		t.Fatalf("Expected only sendReview to be mirrored, got %d shadow calls", n)
+	}
+
+	diffs := mirror.sampledDiffs("ReviewService", "sendReview")
+	if len(diffs) != 1 || diffs[0].ShadowError != "shadow failed" {
+		t.Fatalf("Expected only the newest diff to be kept, got %+v", diffs)
+	}
+	if got := mirror.sampledDiffs("TravelService", ""); len(got) != 0 {
+		t.Fatalf("Expected no TravelService diffs, got %+v", got)
+	}
+	if _, err := newShadowMirror(shadowConfig{Services: map[string]shadowPolicy{"ReviewService": {Percent: 150, Methods: []string{"sendReview"}}}, MaxInFlight: 1}, nil); err == nil {
+		t.Fatal("Expected a percentage above 100 to be rejected")
+	}
+	if _, err := newShadowMirror(shadowConfig{Services: map[string]shadowPolicy{"ReviewService": {Percent: 10}}, MaxInFlight: 1}, nil); err == nil {
+		t.Fatal("Expected a service without methods to be rejected")
+	}
+	if mirror.sampled("ReviewService", "deleteReview") {
+		t.Fatal("Expected an unlisted method not to be mirrored")
+	}
+}
+
+func TestShadowInstancesOnlyAnswerMirroredCalls(t *testing.T) {
+	if testRegistry == nil {
+		t.Skip("Runs against the fake registry only")
+	}
+	var shadowCallsSeen int32
+	b, err := nacostest.StartBackend(idlPathFor("ReviewService"), testBackend(func(method string, request map[string]interface{}) string {
+		atomic.AddInt32(&shadowCallsSeen, 1)
+		return fmt.Sprintf(`{"action": "%s was uploaded by the candidate",%s}`, method, testBaseResp)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+	ins := nacostest.Instance(b.Addr)
+	ins.Metadata[metaShadow] = "true"
+	testRegistry.Register(nacostest.DefaultNamespace, nacostest.DefaultGroup, "ReviewService", ins)
+	defer testRegistry.Deregister(nacostest.DefaultNamespace, nacostest.DefaultGroup, "ReviewService", ins.Ip, ins.Port)
+
+	mirror, err := newShadowMirror(shadowConfig{
+		Services:       map[string]shadowPolicy{"ReviewService": {Percent: 100, Methods: []string{"deleteReview"}}},
+		DiffSampleRate: 1,
+		MaxDiffs:       10,
+		MaxInFlight:    4,
+	}, callShadow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { gatewayShadow = nil }()
+	gatewayShadow = mirror
+
+	before := shadowCalls.Value("ReviewService", "deleteReview", shadowDiff)
+	for i := 0; i < 4; i++ {
+		resp, err := callGeneric(context.Background(), "ReviewService", "deleteReview", map[string]interface{}{"reviewID": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action := resp.(map[string]interface{})["action"]; action != "deleteReview was successfully uploaded" {
+			t.Fatalf("A client got a shadow answer: %v", action)
+		}
+	}
+	waitForCount(t, shadowCalls, before+4, "ReviewService", "deleteReview", shadowDiff)
+	if n := atomic.LoadInt32(&shadowCallsSeen); n != 4 {
+		t.Fatalf("Expected the shadow instance to get exactly the 4 mirrored calls, got %d", n)
+	}
+	diffs := mirror.sampledDiffs("ReviewService", "deleteReview")
+	want := `action: "deleteReview was successfully uploaded" != "deleteReview was uploaded by the candidate"`
+	if len(diffs) != 4 || len(diffs[0].Diffs) != 1 || diffs[0].Diffs[0] != want {
+		t.Fatalf("Unexpected diffs %+v", diffs)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/protocol/http1/resp"
+)
+
+/**
+ * subscription is a stream clients can follow at /stream/{name}.
+ *
+ * Thrift generic calls have no server streaming, so events come from polling Service.Method
+ * every Interval and publishing the response whenever it changes. Body is a template: ${request.x}
+ * takes query parameter x and ${auth.x} takes claim x of the caller's token.
+ */
+type subscription struct {
+	Name     string                 `json:"name"`
+	Service  string                 `json:"service"`
+	Method   string                 `json:"method"`
+	Body     map[string]interface{} `json:"body"`
+	Interval duration               `json:"interval"`
+}
+
+/**
+ * streamConfig configures the WebSocket and Server-Sent Events bridge.
+ *
+ * BufferSize bounds the events queued for one connection; when a client falls behind, the oldest
+ * queued events are dropped so the latest state always gets through. MaxConnections bounds the
+ * open streams, and Heartbeat is how often idle streams are pinged.
+ */
+type streamConfig struct {
+	Subscriptions []subscription `json:"subscriptions"`
+	// Auth is checked once per connection, which is closed when the caller's token expires.
+	Auth           authConfig `json:"auth"`
+	BufferSize     int        `json:"bufferSize"`
+	MaxConnections int        `json:"maxConnections"`
+	Heartbeat      duration   `json:"heartbeat"`
+}
+
+var (
+	streamConnections = newCounterVec("gateway_stream_connections_total",
+		"Stream connections accepted.", "subscription", "transport")
+	streamEventsDropped = newCounterVec("gateway_stream_events_dropped_total",
+		"Events dropped because a stream client fell behind.", "subscription")
+	streamPolls = newCounterVec("gateway_stream_polls_total",
+		"Backend calls made to feed streams.", "subscription")
+)
+
+/**
+ * streamEvent is one message relayed to stream clients.
+ */
+type streamEvent struct {
+	ID   uint64          `json:"id"`
+	Type string          `json:"event"`
+	Data json.RawMessage `json:"data"`
+}
+
+/**
+ * streamSubscriber is one open connection's queue of events.
+ */
+type streamSubscriber struct {
+	name   string
+	events chan streamEvent
+}
+
+// deliver queues an event without blocking, dropping the oldest queued event when the queue is full.
+func (s *streamSubscriber) deliver(ev streamEvent) {
+	for {
+		select {
+		case s.events <- ev:
+			return
+		default:
+		}
+		select {
+		case <-s.events:
+			streamEventsDropped.Inc(s.name)
+		default:
+		}
+	}
+}
+
+/**
+ * streamTopic is one polled call shared by every subscriber asking for the same body.
+ */
+type streamTopic struct {
+	subscribers map[*streamSubscriber]bool
+	cancel      context.CancelFunc
+	last        *streamEvent
+	seq         uint64
+}
+
+/**
+ * streamHub tracks topics and their subscribers, polling each topic only while it has subscribers.
+ */
+type streamHub struct {
+	mu         sync.Mutex
+	topics     map[string]*streamTopic
+	call       genericCaller
+	bufferSize int
+	open       int64
+}
+
+func newStreamHub(call genericCaller, bufferSize int) *streamHub {
+	if bufferSize <= 0 {
+		bufferSize = 16
+	}
+	return &streamHub{topics: map[string]*streamTopic{}, call: call, bufferSize: bufferSize}
+}
+
+/**
+ * Subscribes to a subscription with an expanded request body.
+ *
+ * The subscriber first receives the topic's latest event, if any, then every later one.
+ *
+ * @return The subscriber and a function that unsubscribes it, stopping the poller with the last one.
+ */
+func (h *streamHub) subscribe(sub subscription, body map[string]interface{}) (*streamSubscriber, func()) {
+	canonical, _ := json.Marshal(body)
+	key := sub.Name + ":" + string(canonical)
+	s := &streamSubscriber{name: sub.Name, events: make(chan streamEvent, h.bufferSize)}
+
+	h.mu.Lock()
+	topic, ok := h.topics[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		topic = &streamTopic{subscribers: map[*streamSubscriber]bool{}, cancel: cancel}
+		h.topics[key] = topic
+		go h.poll(ctx, topic, sub, body)
+	}
+	topic.subscribers[s] = true
+	if topic.last != nil {
+		s.deliver(*topic.last)
+	}
+	h.mu.Unlock()
+
+	return s, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(topic.subscribers, s)
+		if len(topic.subscribers) == 0 && h.topics[key] == topic {
+			topic.cancel()
+			delete(h.topics, key)
+		}
+	}
+}
+
+// publish sends an event to every subscriber of a topic.
+func (h *streamHub) publish(topic *streamTopic, eventType string, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topic.seq++
+	ev := streamEvent{ID: topic.seq, Type: eventType, Data: data}
+	topic.last = &ev
+	for s := range topic.subscribers {
+		s.deliver(ev)
+	}
+}
+
+// poll calls the backend until ctx is cancelled, publishing responses and errors when they change.
+func (h *streamHub) poll(ctx context.Context, topic *streamTopic, sub subscription, body map[string]interface{}) {
+	interval := time.Duration(sub.Interval)
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		streamPolls.Inc(sub.Name)
+		resp, err := h.call(ctx, sub.Service, sub.Method, body)
+		eventType, data := "update", []byte(nil)
+		if err != nil {
+			eventType = "error"
+			data, _ = json.Marshal(utils.H{"error": err.Error()})
+		} else {
+			data, _ = json.Marshal(resp)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if string(data) != string(last) {
+			last = data
+			h.publish(topic, eventType, data)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/**
+ * Parses a query parameter as JSON when possible so ${request.userID} can fill an i32 field.
+ */
+func queryValue(raw string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err == nil {
+		return v
+	}
+	return raw
+}
+
+/**
+ * Registers GET /stream/:subscription, serving each configured subscription over WebSocket
+ * (on an Upgrade request) or Server-Sent Events.
+ *
+ * @return An error if two subscriptions share a name.
+ */
+func registerStreamRoutes(h *server.Hertz, cfg streamConfig, routes *routeTable, call genericCaller) error {
+	subs := map[string]subscription{}
+	for _, sub := range cfg.Subscriptions {
+		if _, dup := subs[sub.Name]; dup {
+			return fmt.Errorf("duplicate stream subscription %q", sub.Name)
+		}
+		subs[sub.Name] = sub
+	}
+	hub := newStreamHub(call, cfg.BufferSize)
+	h.GET("/stream/:subscription", streamHandler(cfg, subs, routes, hub))
+	return nil
+}
+
+func streamHandler(cfg streamConfig, subs map[string]subscription, routes *routeTable, hub *streamHub) app.HandlerFunc {
+	heartbeat := time.Duration(cfg.Heartbeat)
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		sub, ok := subs[c.Param("subscription")]
+		if !ok {
+			c.JSON(consts.StatusNotFound, utils.H{"error": "unknown subscription"})
+			return
+		}
+
+		// The hub polls on behalf of every connection, so the route policy is checked for the
+		// subscription's method when a connection opens.
+		token := streamToken(c)
+		if err := routes.load().check(sub.Service, sub.Method, token); err != nil {
+			c.JSON(routePolicyStatus(err), utils.H{"error": err.Error()})
+			return
+		}
+		claims, err := authenticateToken(cfg.Auth, token)
+		if err != nil {
+			c.JSON(consts.StatusUnauthorized, utils.H{"error": err.Error()})
+			return
+		}
+
+		request := map[string]interface{}{}
+		c.QueryArgs().VisitAll(func(key, value []byte) {
+			if string(key) != "access_token" {
+				request[string(key)] = queryValue(string(value))
+			}
+		})
+		expanded, err := expandTemplate(sub.Body, map[string]interface{}{"request": request, "auth": claims})
+		if err != nil {
+			c.JSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+			return
+		}
+		body, _ := expanded.(map[string]interface{})
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+
+		if open := atomic.AddInt64(&hub.open, 1); cfg.MaxConnections > 0 && open > int64(cfg.MaxConnections) {
+			atomic.AddInt64(&hub.open, -1)
+			c.JSON(consts.StatusServiceUnavailable, utils.H{"error": "too many open streams"})
+			return
+		}
+
+		// The connection ends when its token does; a nil channel never fires.
+		var expired <-chan time.Time
+		if exp, ok := claimsExpiry(claims); ok {
+			expired = time.After(time.Until(exp))
+		}
+
+		header := func(name string) string { return string(c.GetHeader(name)) }
+		if isWebSocketUpgrade(header) {
+			key := header("Sec-WebSocket-Key")
+			if key == "" || header("Sec-WebSocket-Version") != "13" {
+				atomic.AddInt64(&hub.open, -1)
+				c.JSON(consts.StatusBadRequest, utils.H{"error": "unsupported WebSocket handshake"})
+				return
+			}
+			streamConnections.Inc(sub.Name, "websocket")
+			c.SetStatusCode(consts.StatusSwitchingProtocols)
+			c.Header("Upgrade", "websocket")
+			c.Header("Connection", "Upgrade")
+			c.Header("Sec-WebSocket-Accept", webSocketAccept(key))
+			c.Hijack(func(conn network.Conn) {
+				defer atomic.AddInt64(&hub.open, -1)
+				s, unsubscribe := hub.subscribe(sub, body)
+				defer unsubscribe()
+				serveWebSocket(&wsConn{rw: conn}, s, heartbeat, expired)
+			})
+			return
+		}
+
+		streamConnections.Inc(sub.Name, "sse")
+		defer atomic.AddInt64(&hub.open, -1)
+		s, unsubscribe := hub.subscribe(sub, body)
+		defer unsubscribe()
+		serveSSE(c, s, heartbeat, expired)
+	}
+}
+
+/**
+ * Relays events to a WebSocket client as JSON text frames until either side closes.
+ */
+func serveWebSocket(ws *wsConn, s *streamSubscriber, heartbeat time.Duration, expired <-chan time.Time) {
+	closed := make(chan struct{})
+	go func() {
+		ws.readLoop()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-s.events:
+			frame, _ := json.Marshal(ev)
+			if err := ws.writeFrame(wsText, frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := ws.writeFrame(wsPing, nil); err != nil {
+				return
+			}
+		case <-expired:
+			ws.writeClose(wsClosePolicy, "token expired")
+			return
+		case <-closed:
+			return
+		}
+	}
+}
+
+/**
+ * Relays events as a text/event-stream response until the client goes away. A failed write of
+ * an event or heartbeat comment is how a disconnect is noticed.
+ */
+func serveSSE(c *app.RequestContext, s *streamSubscriber, heartbeat time.Duration, expired <-chan time.Time) {
+	c.SetStatusCode(consts.StatusOK)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	w := resp.NewChunkedBodyWriter(&c.Response, c.GetWriter())
+	c.Response.HijackWriter(w)
+
+	send := func(chunk string) bool {
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return false
+		}
+		return w.Flush() == nil
+	}
+
+	if !send(": connected\n\n") {
+		return
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-s.events:
+			if !send(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)) {
+				return
+			}
+		case <-ticker.C:
+			if !send(": keepalive\n\n") {
+				return
+			}
+		case <-expired:
+			send("event: close\ndata: {\"error\":\"token expired\"}\n\n")
+			return
+		}
+	}
+}
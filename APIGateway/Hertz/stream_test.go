@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+func signHS256(t *testing.T, payload string, secret string) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestStreamHubSharesOnePollerPerTopic(t *testing.T) {
+	var polls int32
+	hub := newStreamHub(func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		n := atomic.AddInt32(&polls, 1)
+		return map[string]interface{}{"userID": body["userID"], "poll": n}, nil
+	}, 4)
+	sub := subscription{Name: "clientData", Service: "TravelService", Method: "RetrieveClientData", Interval: duration(time.Hour)}
+
+	first, unsubscribeFirst := hub.subscribe(sub, map[string]interface{}{"userID": 1.0})
+	ev := <-first.events
+	if ev.Type != "update" || ev.ID != 1 {
+		t.Fatalf("Expected the first update, got %+v", ev)
+	}
+
+	second, unsubscribeSecond := hub.subscribe(sub, map[string]interface{}{"userID": 1.0})
+	if got := <-second.events; string(got.Data) != string(ev.Data) {
+		t.Fatalf("A late subscriber should get the latest event, got %s", got.Data)
+	}
+	if polls != 1 {
+		t.Fatalf("Subscribers with the same body should share one poller, got %d polls", polls)
+	}
+
+	unsubscribeFirst()
+	unsubscribeSecond()
+	hub.mu.Lock()
+	topics := len(hub.topics)
+	hub.mu.Unlock()
+	if topics != 0 {
+		t.Fatal("The topic should be removed with its last subscriber")
+	}
+}
+
+func TestStreamSubscriberDropsOldestWhenBehind(t *testing.T) {
+	s := &streamSubscriber{name: "slow", events: make(chan streamEvent, 2)}
+	before := streamEventsDropped.Value("slow")
+	for i := uint64(1); i <= 5; i++ {
+		s.deliver(streamEvent{ID: i})
+	}
+	if a, b := (<-s.events).ID, (<-s.events).ID; a != 4 || b != 5 {
+		t.Fatalf("The newest events should be kept, got %d and %d", a, b)
+	}
+	if got := streamEventsDropped.Value("slow") - before; got != 3 {
+		t.Fatalf("3 events should be reported as dropped, got %v", got)
+	}
+}
+
+func TestVerifyHS256(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	valid := signHS256(t, `{"sub":"user-1","exp":1700000100}`, "secret")
+	claims, err := verifyHS256(valid, []byte("secret"), now)
+	if err != nil || claims["sub"] != "user-1" {
+		t.Fatalf("Expected the claims of a valid token, got %v %v", claims, err)
+	}
+	if _, err := verifyHS256(valid, []byte("other"), now); err == nil {
+		t.Fatal("A token signed with another secret should be rejected")
+	}
+	if _, err := verifyHS256(valid, []byte("secret"), now.Add(time.Hour)); err == nil {
+		t.Fatal("An expired token should be rejected")
+	}
+}
+
+func TestWebSocketAcceptMatchesRFC6455(t *testing.T) {
+	if got := webSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected accept key %q", got)
+	}
+}
+
+// readServerFrame reads one unmasked frame the gateway sent.
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("Server frames must not be masked")
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+// maskedFrame builds a client frame, which RFC 6455 requires to be masked.
+func maskedFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestWebSocketUpgradeRelaysEvents(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := streamConfig{
+		Subscriptions: []subscription{{Name: "clientData", Service: "TravelService", Method: "RetrieveClientData", Body: map[string]interface{}{"userID": "${request.userID}"}}},
+		Auth:          authConfig{Secret: "secret"},
+	}
+	hub := newStreamHub(func(ctx context.Context, service string, method string, body map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"userID": body["userID"], "Name": "Ada"}, nil
+	}, 0)
+	h := server.New(server.WithHostPorts(addr), server.WithExitWaitTime(0))
+	h.GET("/stream/:subscription", streamHandler(cfg, map[string]subscription{"clientData": cfg.Subscriptions[0]}, &routeTable{}, hub))
+	go h.Run()
+	defer h.Shutdown(context.Background())
+
+	var conn net.Conn
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The gateway did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	token := signHS256(t, `{"sub":"user-1"}`, "secret")
+	if _, err := io.WriteString(conn, "GET /stream/clientData?userID=7&access_token="+token+" HTTP/1.1\r\n"+
+		"Host: "+addr+"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected 101 with the RFC 6455 accept key, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	opcode, payload := readServerFrame(t, br)
+	var ev struct {
+		ID    uint64                 `json:"id"`
+		Event string                 `json:"event"`
+		Data  map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil || opcode != wsText {
+		t.Fatalf("Expected a JSON text frame, got opcode %d: %s", opcode, payload)
+	}
+	if ev.ID != 1 || ev.Event != "update" || ev.Data["Name"] != "Ada" || ev.Data["userID"] != 7.0 {
+		t.Fatalf("Unexpected event %+v", ev)
+	}
+
+	conn.Write(maskedFrame(wsPing, []byte("hi")))
+	if opcode, payload := readServerFrame(t, br); opcode != wsPong || string(payload) != "hi" {
+		t.Fatalf("Expected a pong echoing the ping, got opcode %d: %q", opcode, payload)
+	}
+	conn.Write(maskedFrame(wsClose, nil))
+	if opcode, _ := readServerFrame(t, br); opcode != wsClose {
+		t.Fatalf("Expected a close frame in reply, got opcode %d", opcode)
+	}
+}
+
+func TestOnlyStreamsTakeTheTokenFromTheQuery(t *testing.T) {
+	table := &routeTable{}
+	table.store(routePolicy{Auth: authConfig{Secret: "secret"}})
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/:serviceName/:methodName", routePolicyMiddleware(table), func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, "ok")
+	})
+	token := signHS256(t, `{"sub":"user-1"}`, "secret")
+	if resp := ut.PerformRequest(engine, consts.MethodPost, "/TravelService/RetrieveClientData?access_token="+token, nil); resp.Code != consts.StatusUnauthorized {
+		t.Fatalf("A token in the query of a POST route should not be accepted, got %d", resp.Code)
+	}
+
+	c := app.NewContext(0)
+	c.Request.SetRequestURI("/stream/clientData?userID=7&access_token=" + token)
+	if got := redactedTarget(c); got != "/stream/clientData?userID=7&access_token=REDACTED" {
+		t.Fatalf("Expected the token to be left out of the span target, got %s", got)
+	}
+}
+
+func TestStreamHandlerRejectsUnknownSubscriptionsAndMissingTokens(t *testing.T) {
+	cfg := streamConfig{
+		Subscriptions: []subscription{{Name: "clientData", Service: "TravelService", Method: "RetrieveClientData"}},
+		Auth:          authConfig{Secret: "secret"},
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	hub := newStreamHub(nil, 0)
+	engine.GET("/stream/:subscription", streamHandler(cfg, map[string]subscription{"clientData": cfg.Subscriptions[0]}, &routeTable{}, hub))
+
+	if resp := ut.PerformRequest(engine, consts.MethodGet, "/stream/unknown", nil); resp.Code != consts.StatusNotFound {
+		t.Fatalf("Unknown subscriptions should give 404, got %d", resp.Code)
+	}
+	if resp := ut.PerformRequest(engine, consts.MethodGet, "/stream/clientData", nil); resp.Code != consts.StatusUnauthorized {
+		t.Fatalf("A missing token should give 401, got %d", resp.Code)
+	}
+	expired := signHS256(t, `{"sub":"user-1","exp":1}`, "secret")
+	if resp := ut.PerformRequest(engine, consts.MethodGet, "/stream/clientData?access_token="+expired, nil); resp.Code != consts.StatusUnauthorized {
+		t.Fatalf("An expired token should give 401, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/kitex/client"
+	"github.com/cloudwego/kitex/pkg/remote/trans/gonet"
+)
+
+/**
+ * tlsConfig enables HTTPS on the gateway. CertFile and KeyFile are checked every ReloadInterval
+ * and a changed pair is picked up without a restart.
+ */
+type tlsConfig struct {
+	CertFile       string   `json:"certFile"`
+	KeyFile        string   `json:"keyFile"`
+	ReloadInterval duration `json:"reloadInterval"`
+}
+
+/**
+ * backendTLSConfig enables mutual TLS from the gateway to the RPC backends. The gateway presents
+ * CertFile/KeyFile as its identity and verifies the backend against CAFile. ServerName is the
+ * name expected in backend certificates, since instances are discovered by IP.
+ */
+type backendTLSConfig struct {
+	Enabled    bool   `json:"enabled"`
+	CertFile   string `json:"certFile"`
+	KeyFile    string `json:"keyFile"`
+	CAFile     string `json:"caFile"`
+	ServerName string `json:"serverName"`
+}
+
+/**
+ * certReloader serves a certificate pair from disk, reloading it when either file changes.
+ */
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+/**
+ * Loads a certificate pair and starts watching it.
+ *
+ * @param certFile The PEM certificate (chain) file.
+ * @param keyFile The PEM private key file.
+ * @param interval How often the files are checked for changes; 0 uses 30 seconds.
+ *
+ * @return The reloader, or an error if the initial pair cannot be loaded.
+ */
+func newCertReloader(certFile string, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	go func() {
+		for range time.Tick(interval) {
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				// Keep serving the previous pair; a half-written update will be retried next tick.
+				log.Println("certificate reload failed:", err)
+				continue
+			}
+			log.Println("reloaded certificate", certFile)
+		}
+	}()
+	return r, nil
+}
+
+// latestModTime returns the newer modification time of the two files.
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) changed() bool {
+	latest, err := r.latestModTime()
+	if err != nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return latest.After(r.modTime)
+}
+
+func (r *certReloader) reload() error {
+	latest, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, latest
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+/**
+ * Builds the TLS config for the HTTPS listener.
+ *
+ * @return nil when no certificate is configured, meaning plain HTTP.
+ */
+func newServerTLS(cfg tlsConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil, nil
+	}
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, time.Duration(cfg.ReloadInterval))
+	if err != nil {
+		return nil, fmt.Errorf("error loading gateway certificate: %w", err)
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+/**
+ * Builds the client TLS config the gateway uses towards the backends.
+ *
+ * @return nil when backend TLS is disabled.
+ */
+func newBackendTLS(cfg backendTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.CAFile == "" {
+		return nil, errors.New("backend TLS needs caFile to verify the backends")
+	}
+	pem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backend CA: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+	}
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots, ServerName: cfg.ServerName}
+	if cfg.CertFile != "" {
+		reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error loading gateway client certificate: %w", err)
+		}
+		tlsCfg.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return tlsCfg, nil
+}
+
+/**
+ * tlsDialer is a Kitex dialer opening TLS connections to backend instances.
+ */
+type tlsDialer struct {
+	config *tls.Config
+}
+
+func (d tlsDialer) DialTimeout(network string, address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, d.config)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// backendTLS is the client TLS config for generic calls, nil when backend TLS is off.
+var backendTLS *tls.Config
+
+/**
+ * Returns the generic client options that switch the Kitex transport to TLS.
+ *
+ * Netpoll cannot carry TLS, so the client uses Kitex's go net transport with a TLS dialer.
+ *
+ * @return No options when backend TLS is off.
+ */
+func backendTLSOptions() []client.Option {
+	if backendTLS == nil {
+		return nil
+	}
+	return []client.Option{
+		client.WithDialer(tlsDialer{config: backendTLS}),
+		client.WithTransHandlerFactory(gonet.NewCliTransHandlerFactory()),
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate for commonName to certFile and keyFile.
+func writeSelfSigned(t *testing.T, certFile string, keyFile string, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedCommonName(t *testing.T, r *certReloader) string {
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloaderPicksUpChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "gateway.pem"), filepath.Join(dir, "gateway.key")
+	writeSelfSigned(t, certFile, keyFile, "first")
+
+	r, err := newCertReloader(certFile, keyFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedCommonName(t, r); got != "first" {
+		t.Fatalf("Expected the initial certificate, got %q", got)
+	}
+
+	writeSelfSigned(t, certFile, keyFile, "second")
+	// Make the change visible regardless of the file system's timestamp resolution.
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for servedCommonName(t, r) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("The rewritten certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTLSIsOffByDefault(t *testing.T) {
+	serverTLS, err := newServerTLS(tlsConfig{})
+	if err != nil || serverTLS != nil {
+		t.Fatalf("No certificate should mean plain HTTP, got %v %v", serverTLS, err)
+	}
+	clientTLS, err := newBackendTLS(backendTLSConfig{})
+	if err != nil || clientTLS != nil {
+		t.Fatalf("Backend TLS should be off unless enabled, got %v %v", clientTLS, err)
+	}
+	if _, err := newBackendTLS(backendTLSConfig{Enabled: true}); err == nil {
+		t.Fatal("Enabling backend TLS without a CA should fail")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/kitex/pkg/discovery"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "hertz_demo"
+
+/**
+ * tracingConfig selects where the gateway sends its spans.
+ *
+ * Exporter is one of "none", "otlp", "stdout" or "file". Endpoint is the OTLP/HTTP collector
+ * address (host:port) and File is the path spans are appended to for the "file" exporter;
+ * both the stdout and file exporters work without a collector.
+ */
+type tracingConfig struct {
+	Exporter    string  `json:"exporter"`
+	Endpoint    string  `json:"endpoint"`
+	Insecure    bool    `json:"insecure"`
+	File        string  `json:"file"`
+	ServiceName string  `json:"serviceName"`
+	SampleRatio float64 `json:"sampleRatio"`
+}
+
+/**
+ * Installs the global OpenTelemetry tracer provider and the W3C trace context propagator.
+ *
+ * @param ctx The context used while creating the exporter.
+ * @param cfg The tracing section of the gateway config.
+ *
+ * @return A shutdown function that flushes pending spans; it is safe to call when tracing is off.
+ * @return An error if the exporter could not be created.
+ */
+func initTracing(ctx context.Context, cfg tracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	var err error
+
+	switch cfg.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		f, ferr := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if ferr != nil {
+			return nil, fmt.Errorf("error opening trace file: %w", ferr)
+		}
+		closer = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+/**
+ * Starts a span with the gateway's tracer.
+ *
+ * @param ctx The parent context.
+ * @param name The span name.
+ * @param attrs Attributes recorded on the span.
+ *
+ * @return The context carrying the new span, and the span itself.
+ */
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+/**
+ * Marks a span as failed when err is not nil and ends it.
+ */
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// redactedTarget is the request URI with the value of an access_token query parameter hidden.
+func redactedTarget(c *app.RequestContext) string {
+	if !c.QueryArgs().Has("access_token") {
+		return string(c.Request.RequestURI())
+	}
+	args := &protocol.Args{}
+	c.QueryArgs().CopyTo(args)
+	args.Set("access_token", "REDACTED")
+	return string(c.Request.URI().Path()) + "?" + args.String()
+}
+
+/**
+ * Hertz middleware that opens a server span for every request. Incoming W3C traceparent headers
+ * are honoured so the gateway joins a trace started by the caller.
+ */
+func tracingMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		carrier := propagation.MapCarrier{}
+		c.Request.Header.VisitAll(func(k, v []byte) {
+			carrier[string(k)] = string(v)
+		})
+		ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+		route := c.FullPath()
+		if route == "" {
+			route = string(c.Path())
+		}
+		ctx, span := otel.Tracer(tracerName).Start(ctx,
+			fmt.Sprintf("%s %s", c.Method(), route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(string(c.Method())),
+				semconv.HTTPRoute(route),
+				semconv.HTTPTarget(redactedTarget(c)),
+			),
+		)
+		defer span.End()
+
+		c.Next(ctx)
+
+		status := c.Response.StatusCode()
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	}
+}
+
+/**
+ * metainfoCarrier adapts Kitex metainfo to an OpenTelemetry TextMapCarrier. Values set on it
+ * are sent to the backend as transient TTHeader entries.
+ */
+type metainfoCarrier struct {
+	ctx context.Context
+}
+
+func (m *metainfoCarrier) Get(key string) string {
+	v, _ := metainfo.GetValue(m.ctx, key)
+	return v
+}
+
+func (m *metainfoCarrier) Set(key, value string) {
+	m.ctx = metainfo.WithValue(m.ctx, key, value)
+}
+
+func (m *metainfoCarrier) Keys() []string {
+	keys := []string{}
+	for k := range metainfo.GetAllValues(m.ctx) {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+/**
+ * Copies the span context in ctx into Kitex metainfo so it crosses the Thrift transport.
+ *
+ * @param ctx The context holding the current span.
+ *
+ * @return A context whose metainfo carries the traceparent and tracestate values.
+ */
+func injectTraceContext(ctx context.Context) context.Context {
+	carrier := &metainfoCarrier{ctx: ctx}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.ctx
+}
+
+/**
+ * tracingResolver wraps a Kitex resolver so each instance lookup shows up as a span.
+ * Name is passed through untouched so Kitex's balancer cache keys stay the same.
+ */
+type tracingResolver struct {
+	discovery.Resolver
+}
+
+func (r tracingResolver) Resolve(ctx context.Context, desc string) (discovery.Result, error) {
+	ctx, span := startSpan(ctx, "resolver.Resolve", attribute.String("rpc.service", desc))
+	res, err := r.Resolver.Resolve(ctx, desc)
+	span.SetAttributes(attribute.Int("registry.instances", len(res.Instances)))
+	endSpan(span, err)
+	return res, err
+}
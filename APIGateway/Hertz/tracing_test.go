@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestInjectTraceContextIntoMetainfo(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+
+	ctx = injectTraceContext(ctx)
+
+	traceparent, ok := metainfo.GetValue(ctx, "traceparent")
+	if !ok {
+		t.Fatalf("traceparent was not set in metainfo")
+	}
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Fatalf("traceparent %s does not carry trace id %s", traceparent, span.SpanContext().TraceID())
+	}
+}
+
+func TestInitTracingRejectsUnknownExporter(t *testing.T) {
+	_, err := initTracing(context.Background(), tracingConfig{Exporter: "carrier-pigeon"})
+	if err == nil {
+		t.Fatalf("Should return an error for an unknown exporter")
+	}
+}
@@ -0,0 +1,232 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the values of redacted fields.
+const Redacted = "[REDACTED]"
+
+// Redact returns a copy of v, a decoded JSON value, with the value of every object field
+// whose name is in fields, compared case-insensitively, replaced by Redacted.
+func Redact(v interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return v
+	}
+	names := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		names[strings.ToLower(f)] = true
+	}
+	return redact(v, names)
+}
+
+func redact(v interface{}, names map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			if names[strings.ToLower(k)] {
+				out[k] = Redacted
+			} else {
+				out[k] = redact(value, names)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = redact(value, names)
+		}
+		return out
+	}
+	return v
+}
+
+// HasRedacted reports whether any value in v, a decoded JSON value, was redacted.
+func HasRedacted(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return v == Redacted
+	case map[string]interface{}:
+		for _, value := range v {
+			if HasRedacted(value) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if HasRedacted(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Diff lists where two decoded JSON values differ, one line per difference, such as
+// `BaseResp.StatusCode: 0 != 1`. Object fields named in ignore are skipped at any depth, as
+// are fields either side has redacted.
+func Diff(want interface{}, got interface{}, ignore []string) []string {
+	names := make(map[string]bool, len(ignore))
+	for _, f := range ignore {
+		names[f] = true
+	}
+	diffs := []string{}
+	diff(want, got, "", names, &diffs)
+	return diffs
+}
+
+func diff(want interface{}, got interface{}, path string, ignore map[string]bool, diffs *[]string) {
+	name := path
+	if name == "" {
+		name = "response"
+	}
+	if want == Redacted || got == Redacted {
+		return
+	}
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ignore[k] {
+				continue
+			}
+			fieldPath := k
+			if path != "" {
+				fieldPath = path + "." + k
+			}
+			diff(w[k], g[k], fieldPath, ignore, diffs)
+		}
+		return
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		if len(w) != len(g) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %d items != %d items", name, len(w), len(g)))
+			return
+		}
+		for i := range w {
+			diff(w[i], g[i], fmt.Sprintf("%s[%d]", name, i), ignore, diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", name, show(want), show(got)))
+	}
+}
+
+func show(v interface{}) string {
+	if v == nil {
+		return "<absent>"
+	}
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// Sender sends a recorded request again and returns the status and response it got. An error
+// the target answered with counts as a response: it is reported as a non-200 status with the
+// error as response, and err is kept for failures to reach the target at all.
+type Sender func(ctx context.Context, r Record) (status int, response interface{}, err error)
+
+// Result is the outcome of replaying one record.
+type Result struct {
+	Record Record
+	Status int
+	// Diffs lists the differences from the recorded answer, empty when they match.
+	Diffs []string
+	// Err is set when the target could not be reached.
+	Err error
+	// Skipped is set when the record was not sent because its request has redacted fields.
+	Skipped  bool
+	Duration time.Duration
+}
+
+// MethodReport counts the outcomes for one "Service.method".
+type MethodReport struct {
+	Total, Matched, Differed, Failed, Skipped int
+}
+
+// Report summarises a replay.
+type Report struct {
+	Results  []Result
+	ByMethod map[string]*MethodReport
+}
+
+// Differed reports whether any record differed or failed.
+func (r *Report) Differed() bool {
+	for _, m := range r.ByMethod {
+		if m.Differed > 0 || m.Failed > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay sends every record with send, in order, and compares each answer with the recorded
+// one: the status must be the same, and for 200s the response must match apart from the
+// ignored fields. Only the status is compared for errors, as their messages carry details
+// such as addresses. Records whose request has redacted fields are skipped, as sending the
+// placeholder instead of the real value would not get the recorded answer.
+func Replay(ctx context.Context, records []Record, send Sender, ignore []string) *Report {
+	report := &Report{ByMethod: map[string]*MethodReport{}}
+	for _, rec := range records {
+		if ctx.Err() != nil {
+			break
+		}
+		key := rec.Service + "." + rec.Method
+		m, ok := report.ByMethod[key]
+		if !ok {
+			m = &MethodReport{}
+			report.ByMethod[key] = m
+		}
+		m.Total++
+		if HasRedacted(rec.Request) {
+			m.Skipped++
+			report.Results = append(report.Results, Result{Record: rec, Skipped: true})
+			continue
+		}
+
+		start := time.Now()
+		status, response, err := send(ctx, rec)
+		res := Result{Record: rec, Status: status, Err: err, Duration: time.Since(start)}
+		switch {
+		case err != nil:
+			m.Failed++
+		case status != rec.Status:
+			res.Diffs = []string{fmt.Sprintf("status: %d != %d", rec.Status, status)}
+		case status == 200:
+			res.Diffs = Diff(rec.Response, response, ignore)
+		}
+		if err == nil {
+			if len(res.Diffs) == 0 {
+				m.Matched++
+			} else {
+				m.Differed++
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
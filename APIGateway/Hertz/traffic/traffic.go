@@ -0,0 +1,187 @@
+// Package traffic records calls made through the gateway and reads them back for replay.
+//
+// Each call is one Record, written as a line of JSON to files that rotate by size. The same
+// files are read by cmd/replay, which sends the calls again to a gateway or a backend and
+// compares the answers with the recorded ones.
+package traffic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one recorded call.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	// Method is the Thrift method that was called.
+	Method string `json:"method"`
+	// Request is the JSON body sent to the backend, with the IDL's field names.
+	Request map[string]interface{} `json:"request"`
+	// Status is the HTTP status the gateway answered with.
+	Status   int         `json:"status"`
+	Response interface{} `json:"response,omitempty"`
+	// Error is the error returned instead of a response, if any.
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+const (
+	filePrefix = "traffic-"
+	fileSuffix = ".jsonl"
+)
+
+// Writer appends records to files in a directory, starting a new file once the current one
+// reaches maxBytes and removing the oldest files beyond maxFiles. It is safe for concurrent use.
+type Writer struct {
+	dir      string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	seq  int
+	now  func() time.Time
+}
+
+// NewWriter creates dir if needed and returns a writer into it. A maxBytes or maxFiles of 0
+// means no limit.
+func NewWriter(dir string, maxBytes int64, maxFiles int) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{dir: dir, maxBytes: maxBytes, maxFiles: maxFiles, now: time.Now}, nil
+}
+
+// Write appends one record.
+func (w *Writer) Write(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil || (w.maxBytes > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxBytes) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// rotate closes the current file, opens a new one and prunes old files. w.mu is held.
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	// The timestamp sorts the files by age; the sequence number keeps names unique when the
+	// clock has not moved on.
+	w.seq++
+	name := fmt.Sprintf("%s%s-%04d%s", filePrefix, w.now().UTC().Format("20060102T150405.000000000"), w.seq, fileSuffix)
+	// Records hold request and response bodies, so only the gateway's user may read them.
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	w.file, w.size = f, 0
+
+	if w.maxFiles <= 0 {
+		return nil
+	}
+	files, err := Files(w.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > w.maxFiles {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Files returns the recording files in dir, oldest first.
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), filePrefix) && strings.HasSuffix(e.Name(), fileSuffix) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Read returns the records in the given files and directories, in the order they were
+// written. Directories are read as every recording file in them, oldest first.
+func Read(paths ...string) ([]Record, error) {
+	records := []Record{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			if files, err = Files(path); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			if records, err = readFile(file, records); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return records, nil
+}
+
+func readFile(path string, records []Record) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
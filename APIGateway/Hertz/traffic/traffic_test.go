@@ -0,0 +1,145 @@
+package traffic
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWriterRotatesAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if err := w.Write(Record{Service: "ReviewService", Method: "sendReview", Request: map[string]interface{}{"userID": float64(i)}, Status: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected the 2 newest files to be kept, got %v", files)
+	}
+	for _, f := range files {
+		if info, err := os.Stat(f); err != nil || info.Mode().Perm() != 0o600 {
+			t.Fatalf("Recording %s should only be readable by its owner, got %v, %v", f, info.Mode(), err)
+		}
+	}
+	records, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || records[len(records)-1].Request["userID"] != float64(5) {
+		t.Fatalf("Expected the newest records, got %+v", records)
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i].Request["userID"].(float64) != records[i-1].Request["userID"].(float64)+1 {
+			t.Fatalf("Records out of order: %+v", records)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	v := map[string]interface{}{
+		"Msg":      "hi",
+		"Password": "secret",
+		"users":    []interface{}{map[string]interface{}{"token": "abc", "name": "ryan"}},
+	}
+	got := Redact(v, []string{"password", "TOKEN"})
+	want := map[string]interface{}{
+		"Msg":      "hi",
+		"Password": Redacted,
+		"users":    []interface{}{map[string]interface{}{"token": Redacted, "name": "ryan"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	if v["Password"] != "secret" {
+		t.Fatal("Redact changed its input")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want := map[string]interface{}{
+		"Name":     "Ryan",
+		"userID":   float64(7),
+		"Visited":  []interface{}{"Taiwan", "Singapore"},
+		"BaseResp": map[string]interface{}{"StatusCode": float64(200)},
+		"token":    Redacted,
+	}
+	got := map[string]interface{}{
+		"Name":     "Ryan",
+		"userID":   float64(8),
+		"Visited":  []interface{}{"Taiwan"},
+		"BaseResp": map[string]interface{}{"StatusCode": float64(500)},
+		"token":    "abc",
+		"extra":    true,
+	}
+	diffs := Diff(want, got, nil)
+	expected := []string{
+		"BaseResp.StatusCode: 200 != 500",
+		"Visited: 2 items != 1 items",
+		"extra: <absent> != true",
+		"userID: 7 != 8",
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("Expected %q, got %q", expected, diffs)
+	}
+	if diffs := Diff(want, got, []string{"BaseResp", "Visited", "extra", "userID"}); len(diffs) != 0 {
+		t.Fatalf("Expected ignored fields to be skipped, got %q", diffs)
+	}
+}
+
+func TestReplayCountsOutcomesPerMethod(t *testing.T) {
+	records := []Record{
+		{Service: "TravelService", Method: "RetrieveClientData", Status: 200, Response: map[string]interface{}{"Name": "Ryan"}},
+		{Service: "TravelService", Method: "RetrieveClientData", Status: 200, Response: map[string]interface{}{"Name": "Ryan"}},
+		{Service: "ReviewService", Method: "sendReview", Status: 400, Error: "invalid inputs"},
+		{Service: "ReviewService", Method: "deleteReview", Status: 200, Response: map[string]interface{}{}},
+		{Service: "ReviewService", Method: "sendReview", Status: 200, Request: map[string]interface{}{"token": Redacted}},
+	}
+	calls := 0
+	send := func(ctx context.Context, r Record) (int, interface{}, error) {
+		calls++
+		switch {
+		case r.Method == "deleteReview":
+			return 0, nil, errors.New("connection refused")
+		case r.Method == "sendReview":
+			return 400, "invalid inputs: a different message", nil
+		case calls == 2:
+			return 500, "boom", nil
+		}
+		return 200, map[string]interface{}{"Name": "Ryan"}, nil
+	}
+
+	report := Replay(context.Background(), records, send, nil)
+	got := map[string]MethodReport{}
+	for k, v := range report.ByMethod {
+		got[k] = *v
+	}
+	want := map[string]MethodReport{
+		"TravelService.RetrieveClientData": {Total: 2, Matched: 1, Differed: 1},
+		"ReviewService.sendReview":         {Total: 2, Matched: 1, Skipped: 1},
+		"ReviewService.deleteReview":       {Total: 1, Failed: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+	if !report.Differed() {
+		t.Fatal("Expected the report to show differences")
+	}
+	if d := report.Results[1].Diffs; len(d) != 1 || d[0] != "status: 200 != 500" {
+		t.Fatalf("Unexpected diffs %q", d)
+	}
+	if calls != 4 || !report.Results[4].Skipped {
+		t.Fatalf("The record with a redacted request should be skipped, got %d calls", calls)
+	}
+}
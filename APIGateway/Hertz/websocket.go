@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// WebSocket opcodes from RFC 6455 section 5.2.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// The close codes the gateway sends.
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+	wsClosePolicy        = 1008
+)
+
+// Frames from clients larger than this are refused; clients only send control frames and small messages.
+const wsMaxClientPayload = 4096
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+/**
+ * Reports whether the request headers ask for a WebSocket upgrade.
+ */
+func isWebSocketUpgrade(header func(string) string) bool {
+	return strings.EqualFold(header("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(header("Connection")), "upgrade")
+}
+
+/**
+ * Computes Sec-WebSocket-Accept for a client's Sec-WebSocket-Key.
+ */
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+/**
+ * wsConn frames messages over a hijacked connection. Writes are serialised so the event loop
+ * and the control frame replies from the read loop can share the connection.
+ */
+type wsConn struct {
+	rw      io.ReadWriter
+	writeMu sync.Mutex
+}
+
+// writeFrame sends one unfragmented, unmasked frame, as servers must.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if _, err := ws.rw.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	if f, ok := ws.rw.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// writeClose sends a close frame with a status code and reason.
+func (ws *wsConn) writeClose(code uint16, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	return ws.writeFrame(wsClose, append(payload, reason...))
+}
+
+var (
+	errWSUnmasked = errors.New("client frame is not masked")
+	errWSTooBig   = errors.New("client frame is too large")
+)
+
+/**
+ * Reads one frame from the client.
+ *
+ * @return The opcode and unmasked payload, or an error for a broken or oversized frame.
+ */
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return 0, nil, errWSUnmasked
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxClientPayload {
+		return 0, nil, errWSTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+/**
+ * Reads client frames until the connection closes, answering pings and close frames.
+ * Data frames are discarded: the stream only flows from the gateway to the client.
+ *
+ * @return The reason the loop stopped; io.EOF after a clean close.
+ */
+func (ws *wsConn) readLoop() error {
+	for {
+		opcode, payload, err := ws.readFrame()
+		switch {
+		case errors.Is(err, errWSTooBig):
+			ws.writeClose(wsCloseTooBig, "frame too large")
+			return err
+		case errors.Is(err, errWSUnmasked):
+			ws.writeClose(wsCloseProtocolError, "frames must be masked")
+			return err
+		case err != nil:
+			return err
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := ws.writeFrame(wsPong, payload); err != nil {
+				return err
+			}
+		case wsClose:
+			ws.writeClose(wsCloseNormal, "")
+			return io.EOF
+		case wsPong, wsText, wsBinary, wsContinuation:
+		default:
+			ws.writeClose(wsCloseProtocolError, "unknown opcode")
+			return errors.New("unknown websocket opcode")
+		}
+	}
+}
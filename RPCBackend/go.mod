@@ -12,16 +12,21 @@ require (
 	github.com/kitex-contrib/tracer-opentracing v0.0.3
 	github.com/opentracing/opentracing-go v1.2.0
 	github.com/uber/jaeger-client-go v2.29.1+incompatible
-	go.opentelemetry.io/otel v1.13.0
+	go.opentelemetry.io/otel v1.14.0
 	golang.org/x/sync v0.1.0
 	google.golang.org/protobuf v1.28.1
 )
 
 require (
 	github.com/HdrHistogram/hdrhistogram-go v1.1.2 // indirect
-	github.com/kitex-contrib/registry-nacos v0.1.0 // indirect
-	github.com/nacos-group/nacos-sdk-go v1.1.4 // indirect
+	github.com/cloudwego/thriftgo v0.2.8
+	github.com/kitex-contrib/registry-nacos v0.1.0
+	github.com/nacos-group/nacos-sdk-go v1.1.4
 	github.com/uber/jaeger-lib v2.4.1+incompatible // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.14.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.14.0
+	go.opentelemetry.io/otel/sdk v1.14.0
+	go.opentelemetry.io/otel/trace v1.14.0
 )
 
 replace github.com/apache/thrift => github.com/apache/thrift v0.13.0
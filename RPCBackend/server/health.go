@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/**
+ * @brief The state of one generic server as reported by the health endpoint.
+ */
+type genericServerStatus struct {
+	Name      string `json:"name"`
+	Service   string `json:"service"`
+	Addr      string `json:"addr"`
+	State     string `json:"state"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+/**
+ * @brief Tracks every generic server started by this process and serves their status over HTTP.
+ */
+type healthRegistry struct {
+	mu      sync.Mutex
+	servers []*genericServerStatus
+}
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{}
+}
+
+/**
+ * @brief Records a generic server that is about to start.
+ * @param[in] name The name used for the server in logs.
+ * @param[in] service The service name the server registers under.
+ * @param[in] port The local port the server listens on.
+ *
+ * @return The status entry to hand back to stopped once the server exits.
+ */
+func (h *healthRegistry) add(name string, service string, port int) *genericServerStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := &genericServerStatus{
+		Name:    name,
+		Service: service,
+		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		State:   "running",
+	}
+	h.servers = append(h.servers, status)
+	return status
+}
+
+/**
+ * @brief Marks a generic server as stopped, keeping the error it exited with.
+ */
+func (h *healthRegistry) stopped(status *genericServerStatus, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status.State = "stopped"
+	if err != nil {
+		status.Error = err.Error()
+	}
+}
+
+/**
+ * @brief Returns a copy of every server's status with a fresh reachability probe.
+ *
+ * A server counts as reachable when its port accepts a TCP connection.
+ */
+func (h *healthRegistry) snapshot() []genericServerStatus {
+	h.mu.Lock()
+	statuses := make([]genericServerStatus, 0, len(h.servers))
+	for _, s := range h.servers {
+		statuses = append(statuses, *s)
+	}
+	h.mu.Unlock()
+
+	for i := range statuses {
+		conn, err := net.DialTimeout("tcp", statuses[i].Addr, time.Second)
+		if err == nil {
+			conn.Close()
+			statuses[i].Reachable = true
+		}
+	}
+	return statuses
+}
+
+/**
+ * @brief Serves /healthz with the status of each generic server.
+ *
+ * Responds 200 when every server is running and accepting connections, 503 otherwise.
+ */
+func (h *healthRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/healthz" {
+		http.NotFound(w, r)
+		return
+	}
+
+	statuses := h.snapshot()
+	healthy := true
+	for _, s := range statuses {
+		if s.State != "running" || !s.Reachable {
+			healthy = false
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  status,
+		"servers": statuses,
+	})
+}
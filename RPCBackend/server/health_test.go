@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// listenLocal opens a port for a fake generic server, closed when the test ends.
+func listenLocal(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func getHealth(t *testing.T, h *healthRegistry, path string) (int, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	out := map[string]interface{}{}
+	if rec.Code != http.StatusNotFound {
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("Health response is not JSON: %s", rec.Body.String())
+		}
+	}
+	return rec.Code, out
+}
+
+func TestHealthRegistryAddAndStopped(t *testing.T) {
+	h := newHealthRegistry()
+	status := h.add("travel-1", "TravelService", 8888)
+	if status.State != "running" || status.Addr != "127.0.0.1:8888" || status.Service != "TravelService" {
+		t.Fatalf("Unexpected new status %+v", status)
+	}
+
+	h.stopped(status, errors.New("address already in use"))
+	snapshot := h.snapshot()
+	if len(snapshot) != 1 || snapshot[0].State != "stopped" || snapshot[0].Error != "address already in use" {
+		t.Fatalf("Expected the server to be stopped with its error, got %+v", snapshot)
+	}
+
+	clean := h.add("review-1", "ReviewService", 8887)
+	h.stopped(clean, nil)
+	if snapshot := h.snapshot(); snapshot[1].State != "stopped" || snapshot[1].Error != "" {
+		t.Fatalf("A server that exits cleanly should be stopped without an error, got %+v", snapshot[1])
+	}
+}
+
+func TestHealthzReportsStoppedServers(t *testing.T) {
+	h := newHealthRegistry()
+	travel := h.add("travel-1", "TravelService", listenLocal(t))
+	h.add("review-1", "ReviewService", listenLocal(t))
+
+	if code, out := getHealth(t, h, "/healthz"); code != http.StatusOK || out["status"] != "ok" {
+		t.Fatalf("Expected 200 while every server runs, got %d %v", code, out)
+	}
+
+	h.stopped(travel, errors.New("listener closed"))
+	code, out := getHealth(t, h, "/healthz")
+	if code != http.StatusServiceUnavailable || out["status"] != "unavailable" {
+		t.Fatalf("Expected 503 once a server stopped, got %d %v", code, out)
+	}
+	servers := out["servers"].([]interface{})
+	stopped := servers[0].(map[string]interface{})
+	if stopped["state"] != "stopped" || stopped["error"] != "listener closed" {
+		t.Fatalf("Expected the stopped server and its error in the body, got %v", stopped)
+	}
+
+	if code, _ := getHealth(t, h, "/other"); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 off /healthz, got %d", code)
+	}
+}
+
+func TestHealthzReportsUnreachableServers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	h := newHealthRegistry()
+	h.add("travel-1", "TravelService", port)
+	code, out := getHealth(t, h, "/healthz")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 when a running server's port refuses connections, got %d", code)
+	}
+	if server := out["servers"].([]interface{})[0].(map[string]interface{}); server["reachable"] != false || server["state"] != "running" {
+		t.Fatalf("Expected a running but unreachable server, got %v", server)
+	}
+}
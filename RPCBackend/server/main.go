@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"strconv"
 	"sync"
 	"encoding/json"
 	"github.com/cloudwego/kitex/pkg/generic"
 	"github.com/cloudwego/kitex/pkg/limit"
+	kitexregistry "github.com/cloudwego/kitex/pkg/registry"
 	"github.com/cloudwego/kitex/pkg/rpcinfo"
 	"github.com/cloudwego/kitex/server"
 	"github.com/cloudwego/kitex/server/genericserver"
@@ -43,15 +48,80 @@ func initialiseThriftGeneric(thriftName string)(generic.Generic,error){
 	return g,nil
 }
 
+/**
+ * @brief Returns the options shared by every generic server.
+ * @param[in] reg The nacos registry the server registers with.
+ * @param[in] serviceName The service name registered in nacos.
+ * @param[in] port The port the server listens on.
+ * @param[in] tlsConfig The mutual TLS config, or nil for plain TCP.
+ * @param[in] meta The metadata the instance publishes to nacos.
+ *
+ * @return The server options. Panics if the TLS listener cannot be created or the IDL cannot be hashed.
+ */
+func serverOptions(reg kitexregistry.Registry, serviceName string, port int, tlsConfig *tls.Config, meta instanceMeta) []server.Option {
+	info, err := registryInfo(serviceName, meta)
+	if err != nil {
+		panic(err)
+	}
+	opts := []server.Option{
+		server.WithServiceAddr(&net.TCPAddr{Port: port}),
+		server.WithRegistry(reg),
+		server.WithRegistryInfo(info),
+		server.WithServerBasicInfo(&rpcinfo.EndpointBasicInfo{ServiceName: serviceName}),
+		server.WithLimit(&limit.Option{MaxConnections: 10000, MaxQPS: 1000}),
+		server.WithMiddleware(tracingMiddleware),
+	}
+	tlsOpts, err := tlsServerOptions(tlsConfig, port)
+	if err != nil {
+		panic(err)
+	}
+	return append(opts, tlsOpts...)
+}
 
 func main() {
 
+	traceExporter := flag.String("trace-exporter", "none", "span exporter: none, otlp, stdout or file")
+	traceEndpoint := flag.String("trace-endpoint", "", "OTLP/HTTP collector address for the otlp exporter")
+	traceFile := flag.String("trace-file", "./traces.json", "output file for the file exporter")
+	healthAddr := flag.String("health-addr", ":8870", "listen address of the HTTP health endpoint")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate of the generic servers; enables mutual TLS")
+	tlsKey := flag.String("tls-key", "", "PEM private key of the generic servers")
+	tlsClientCA := flag.String("tls-client-ca", "", "PEM CA bundle used to verify the gateway's client certificate")
+	tlsClientIdentity := flag.String("tls-client-identity", "api-gateway", "identity the caller's certificate must carry")
+	nacosAddr := flag.String("nacos-addr", "127.0.0.1:8848", "host:port of the nacos server")
+	nacosNamespace := flag.String("nacos-namespace", "public", "nacos namespace the servers register in, e.g. dev, staging or prod")
+	nacosGroup := flag.String("nacos-group", "DEFAULT_GROUP", "nacos group the servers register in")
+	nacosCluster := flag.String("nacos-cluster", "DEFAULT", "nacos cluster the servers register in, e.g. the zone they run in")
+	zone := flag.String("zone", "", "zone published in each instance's metadata")
+	weight := flag.Int("weight", 10, "load balancing weight of each instance")
+	mock := flag.Bool("mock", false, "serve generated responses from the IDLs instead of the real handlers")
+	mockFixtures := flag.String("mock-fixtures", "./mockFixtures", "directory of <Service>.json per-method overrides for -mock")
+	mockLatency := flag.Duration("mock-latency", 0, "latency added to every mocked call")
+	mockErrorRate := flag.Float64("mock-error-rate", 0, "share of mocked calls, between 0 and 1, that fail")
+	shadow := flag.Bool("shadow", false, "register as a shadow instance, which only gets calls the gateway mirrors")
+	portOffset := flag.Int("port-offset", 0, "added to every server's port, to run a second build on the same host")
+	flag.Parse()
+
+	shutdownTracing, err := initTracing(context.Background(), *traceExporter, *traceEndpoint, *traceFile)
+	if err != nil {
+		panic(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	nacosHost, nacosPort, err := net.SplitHostPort(*nacosAddr)
+	if err != nil {
+		panic(err)
+	}
+	port, err := strconv.ParseUint(nacosPort, 10, 64)
+	if err != nil {
+		panic(err)
+	}
 	sc := []constant.ServerConfig{
-		*constant.NewServerConfig("127.0.0.1", 8848),
+		*constant.NewServerConfig(nacosHost, port),
 	}
 	// the nacos client config
 	cc := constant.ClientConfig{
-		NamespaceId:         "public",
+		NamespaceId:         *nacosNamespace,
 		TimeoutMs:           5000,
 		NotLoadCacheAtStart: true,
 		LogDir:              "/tmp/nacos/log",
@@ -69,6 +139,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	reg := registry.NewNacosRegistry(cli, registry.WithGroup(*nacosGroup), registry.WithCluster(*nacosCluster))
 
 	g_one, err := initialiseThriftGeneric("TravelService")
 	if err != nil {
@@ -80,80 +151,72 @@ func main() {
 		panic(err)
 	}
 	
-	svr0 := genericserver.NewServer(
-		new(GenericServiceImpl),
-		g_one,
-		server.WithServiceAddr(&net.TCPAddr{Port: 8888}),
-		server.WithRegistry(registry.NewNacosRegistry(cli)),
-		server.WithServerBasicInfo(&rpcinfo.EndpointBasicInfo{ServiceName: "TravelService"}),
-		server.WithLimit(&limit.Option{MaxConnections: 10000, MaxQPS: 1000}),
-	)
+	tlsConfig, err := newServerTLS(*tlsCert, *tlsKey, *tlsClientCA, *tlsClientIdentity)
+	if err != nil {
+		panic(err)
+	}
 
-	svr1 := genericserver.NewServer(
-		new(GenericServiceImpl),
-		g_one,
-		server.WithServiceAddr(&net.TCPAddr{Port: 8889}),
-		server.WithRegistry(registry.NewNacosRegistry(cli)),
-		server.WithServerBasicInfo(&rpcinfo.EndpointBasicInfo{ServiceName: "TravelService"}),
-		server.WithLimit(&limit.Option{MaxConnections: 10000, MaxQPS: 1000}),
-	)
+	meta := instanceMeta{Version: buildVersion, Zone: *zone, Weight: *weight, Mock: *mock, Shadow: *shadow}
+	log.Println("registering instances with", meta.describe())
 
+	var travelHandler, reviewHandler generic.Service = new(GenericServiceImpl), new(GenericServiceImpl2)
+	if *mock {
+		if *mockErrorRate < 0 || *mockErrorRate > 1 {
+			panic("-mock-error-rate must be between 0 and 1")
+		}
+		mockCfg := mockConfig{FixturesDir: *mockFixtures, Latency: *mockLatency, ErrorRate: *mockErrorRate}
+		if travelHandler, err = newMockService("TravelService", mockCfg); err != nil {
+			panic(err)
+		}
+		if reviewHandler, err = newMockService("ReviewService", mockCfg); err != nil {
+			panic(err)
+		}
+	}
 
-	svr2 := genericserver.NewServer(
-		new(GenericServiceImpl2),
-		g_two,
-		server.WithServiceAddr(&net.TCPAddr{Port: 8887}),
-		server.WithRegistry(registry.NewNacosRegistry(cli)),
-		server.WithServerBasicInfo(&rpcinfo.EndpointBasicInfo{ServiceName: "ReviewService"}),
-		server.WithLimit(&limit.Option{MaxConnections: 10000, MaxQPS: 1000}),
-	)
+	serverPort := func(base int) int { return base + *portOffset }
 
-	svr3 := genericserver.NewServer(
-		new(GenericServiceImpl2),
-		g_two,
-		server.WithServiceAddr(&net.TCPAddr{Port: 8886}),
-		server.WithRegistry(registry.NewNacosRegistry(cli)),
-		server.WithServerBasicInfo(&rpcinfo.EndpointBasicInfo{ServiceName: "ReviewService"}),
-		server.WithLimit(&limit.Option{MaxConnections: 10000, MaxQPS: 1000}),
-	)
+	svr0 := genericserver.NewServer(travelHandler, g_one, serverOptions(reg, "TravelService", serverPort(8888), tlsConfig, meta)...)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		if err := svr0.Run(); err != nil {
-			log.Println("server0 stopped with error:", err)
-		} else {
-			log.Println("server0 stopped")
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		if err := svr1.Run(); err != nil {
-			log.Println("server1 stopped with error:", err)
-		} else {
-			log.Println("server1 stopped")
-		}
-	}()
+	svr1 := genericserver.NewServer(travelHandler, g_one, serverOptions(reg, "TravelService", serverPort(8889), tlsConfig, meta)...)
 
-	go func() {
-		defer wg.Done()
-		if err := svr2.Run(); err != nil {
-			log.Println("server1 stopped with error:", err)
-		} else {
-			log.Println("server1 stopped")
-		}
-	}()
+	svr2 := genericserver.NewServer(reviewHandler, g_two, serverOptions(reg, "ReviewService", serverPort(8887), tlsConfig, meta)...)
+
+	svr3 := genericserver.NewServer(reviewHandler, g_two, serverOptions(reg, "ReviewService", serverPort(8886), tlsConfig, meta)...)
 
+	servers := []struct {
+		name    string
+		service string
+		port    int
+		svr     server.Server
+	}{
+		{"server0", "TravelService", serverPort(8888), svr0},
+		{"server1", "TravelService", serverPort(8889), svr1},
+		{"server2", "ReviewService", serverPort(8887), svr2},
+		{"server3", "ReviewService", serverPort(8886), svr3},
+	}
+
+	health := newHealthRegistry()
 	go func() {
-		defer wg.Done()
-		if err := svr3.Run(); err != nil {
-			log.Println("server1 stopped with error:", err)
-		} else {
-			log.Println("server1 stopped")
+		if err := http.ListenAndServe(*healthAddr, health); err != nil {
+			log.Println("health endpoint stopped with error:", err)
 		}
 	}()
 
+	var wg sync.WaitGroup
+	wg.Add(len(servers))
+	for _, s := range servers {
+		status := health.add(s.name, s.service, s.port)
+		go func(name string, svr server.Server) {
+			defer wg.Done()
+			err := svr.Run()
+			health.stopped(status, err)
+			if err != nil {
+				log.Println(name+" stopped with error:", err)
+			} else {
+				log.Println(name + " stopped")
+			}
+		}(s.name, s.svr)
+	}
 
 	wg.Wait()
 
@@ -167,6 +230,9 @@ type GenericServiceImpl struct {
 }
 
 func (g *GenericServiceImpl) GenericCall(ctx context.Context, method string, request interface{}) (response interface{}, err error) {
+	_, span := startSpan(ctx, "GenericServiceImpl.GenericCall")
+	defer span.End()
+
 	// use jsoniter or other json parse sdk to assert request
 	m := request.(string)
 	fmt.Printf("Recv in server 2: %v\n", m)
@@ -202,6 +268,9 @@ type GenericServiceImpl2 struct {
 }
 
 func (g *GenericServiceImpl2) GenericCall(ctx context.Context, method string, request interface{}) (response interface{}, err error) {
+	_, span := startSpan(ctx, "GenericServiceImpl2.GenericCall")
+	defer span.End()
+
 	// use jsoniter or other json parse sdk to assert request
 	m := request.(string)
 	fmt.Printf("Recv in echo server : %v\n", m)
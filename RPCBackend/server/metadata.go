@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/cloudwego/kitex/pkg/registry"
+	"github.com/cloudwego/thriftgo/parser"
+)
+
+// buildVersion is set at build time with -ldflags "-X main.buildVersion=v1.2.3".
+var buildVersion = "dev"
+
+// Metadata keys every instance publishes to the registry. The gateway reads the same keys.
+const (
+	metaVersion = "version"
+	metaIDLHash = "idl_hash"
+	metaZone    = "zone"
+	metaMock    = "mock"
+	metaShadow  = "shadow"
+)
+
+/**
+ * @brief What an instance tells the registry about itself besides its address.
+ */
+type instanceMeta struct {
+	Version string
+	Zone    string
+	Weight  int
+	// Mock marks instances serving generated data, so callers can tell them from real ones.
+	Mock bool
+	// Shadow marks a candidate build; the gateway only sends it calls it mirrors.
+	Shadow bool
+}
+
+/**
+ * @brief Hashes what a Thrift IDL file declares, together with every file it includes.
+ *
+ * Each file contributes its base name, typedefs, enums with their values, structs, unions and
+ * exceptions with their fields in ID order, and services with their functions, arguments and
+ * throws; comments and formatting do not change the hash. The root file comes first and
+ * includes follow depth first in the order they appear. The gateway hashes its own copy the
+ * same way, in its idl package, so equal hashes mean both sides declare the same API; the two
+ * must stay byte for byte identical.
+ *
+ * @param[in] path The path of the service's IDL file.
+ *
+ * @return The hex-encoded SHA-256 hash.
+ * @return An error if the file or one of its includes cannot be read or parsed.
+ */
+func idlHash(path string) (string, error) {
+	ast, err := parser.ParseFile(path, nil, true)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	seen := map[*parser.Thrift]bool{}
+	var visit func(t *parser.Thrift)
+	visit = func(t *parser.Thrift) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		fmt.Fprintf(h, "file %s\n", filepath.Base(t.Filename))
+		for _, td := range t.Typedefs {
+			fmt.Fprintf(h, "typedef %s %s\n", idlTypeName(td.Type), td.Alias)
+		}
+		for _, e := range t.Enums {
+			fmt.Fprintf(h, "enum %s\n", e.Name)
+			for _, v := range e.Values {
+				fmt.Fprintf(h, "  %s = %d\n", v.Name, v.Value)
+			}
+		}
+		for _, group := range [][]*parser.StructLike{t.Structs, t.Unions, t.Exceptions} {
+			for _, s := range group {
+				fmt.Fprintf(h, "%s %s\n", s.Category, s.Name)
+				hashIDLFields(h, s.Fields)
+			}
+		}
+		for _, svc := range t.Services {
+			fmt.Fprintf(h, "service %s extends %s\n", svc.Name, svc.Extends)
+			for _, fn := range svc.Functions {
+				response := "void"
+				if !fn.Void {
+					response = idlTypeName(fn.FunctionType)
+				}
+				fmt.Fprintf(h, "  function %s %s oneway=%t\n", response, fn.Name, fn.Oneway)
+				hashIDLFields(h, fn.Arguments)
+				fmt.Fprintf(h, "  throws\n")
+				hashIDLFields(h, fn.Throws)
+			}
+		}
+		for _, inc := range t.Includes {
+			if inc.Reference != nil {
+				visit(inc.Reference)
+			}
+		}
+	}
+	visit(ast)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashIDLFields writes fields to the IDL hash in ID order.
+func hashIDLFields(w io.Writer, fields []*parser.Field) {
+	sorted := append([]*parser.Field(nil), fields...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, f := range sorted {
+		fmt.Fprintf(w, "    %d: %d %s %s\n", f.ID, int64(f.Requiredness), idlTypeName(f.Type), f.Name)
+	}
+}
+
+// idlTypeName spells a type as written in the IDL, e.g. "map<string,list<base.BaseResp>>".
+func idlTypeName(t *parser.Type) string {
+	switch {
+	case t == nil:
+		return ""
+	case t.KeyType != nil:
+		return fmt.Sprintf("%s<%s,%s>", t.Name, idlTypeName(t.KeyType), idlTypeName(t.ValueType))
+	case t.ValueType != nil:
+		return fmt.Sprintf("%s<%s>", t.Name, idlTypeName(t.ValueType))
+	}
+	return t.Name
+}
+
+/**
+ * @brief Builds the registry entry for one generic server.
+ * @param[in] serviceName The service name registered in nacos.
+ * @param[in] meta The build version, zone, weight and mock and shadow markers to publish.
+ *
+ * @return The registry info, with the version, IDL hash and zone as nacos metadata.
+ * @return An error if the service's IDL cannot be hashed.
+ */
+func registryInfo(serviceName string, meta instanceMeta) (*registry.Info, error) {
+	hash, err := idlHash(fmt.Sprintf("./thriftFiles/%s.thrift", serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("error hashing the IDL of %s: %w", serviceName, err)
+	}
+	tags := map[string]string{
+		metaVersion: meta.Version,
+		metaIDLHash: hash,
+	}
+	if meta.Zone != "" {
+		tags[metaZone] = meta.Zone
+	}
+	if meta.Mock {
+		tags[metaMock] = "true"
+	}
+	if meta.Shadow {
+		tags[metaShadow] = "true"
+	}
+	return &registry.Info{
+		ServiceName: serviceName,
+		Weight:      meta.Weight,
+		Tags:        tags,
+	}, nil
+}
+
+// describe formats instance metadata for the startup log.
+func (m instanceMeta) describe() string {
+	return "version=" + m.Version + " zone=" + m.Zone + " weight=" + strconv.Itoa(m.Weight) + " mock=" + strconv.FormatBool(m.Mock) + " shadow=" + strconv.FormatBool(m.Shadow)
+}
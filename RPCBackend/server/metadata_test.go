@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// goldenIDL and goldenIDLHash are shared with the gateway's TestIDLHashGolden; a change to how
+// either side hashes an IDL must change both.
+const (
+	goldenIDL = `enum Kind { A = 1, B }
+exception Failed { 1: string why }
+union Choice { 1: string text, 2: i32 number }
+struct Item {
+    2: optional map<string, list<Kind>> tags,
+    1: required i64 id = 7,
+}
+service Golden {
+    Item get(1: Item req, 2: Choice choice) throws (1: Failed failed),
+    oneway void ping(),
+}
+`
+	goldenIDLHash = "4f0deb3256f10152d4d1b564fa7b1c1519eed0fb25cd9eaedaa67ab3f904af03"
+)
+
+func TestIDLHashGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Golden.thrift")
+	if err := ioutil.WriteFile(path, []byte(goldenIDL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := idlHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != goldenIDLHash {
+		t.Fatalf("Expected %s, got %s", goldenIDLHash, got)
+	}
+}
+
+func TestIDLHashIgnoresComments(t *testing.T) {
+	dir := t.TempDir()
+	write := func(idl string) string {
+		path := filepath.Join(dir, "Echo.thrift")
+		if err := ioutil.WriteFile(path, []byte(idl), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := idlHash(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	before := write("service Echo { string ping(1: string msg) }")
+	if after := write("// Echoes.\nservice Echo {\n    string ping(1: string msg) # the message\n}\n"); after != before {
+		t.Fatal("Comments and formatting changed the hash")
+	}
+	if changed := write("service Echo { string ping(1: i64 msg) }"); changed == before {
+		t.Fatal("A changed argument type did not change the hash")
+	}
+}
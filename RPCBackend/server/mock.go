@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/thriftgo/parser"
+)
+
+/**
+ * @brief Behaviour of one mocked method, read from <fixtures dir>/<service>.json, which maps
+ *        method names to fixtures.
+ */
+type mockFixture struct {
+	// Response is merged over the generated response; nested objects are merged too.
+	Response map[string]interface{} `json:"response"`
+	// Latency such as "250ms" is added before answering; it overrides -mock-latency.
+	Latency string `json:"latency"`
+	// ErrorRate is the share of calls, between 0 and 1, that fail; it overrides -mock-error-rate.
+	ErrorRate *float64 `json:"errorRate"`
+	// Error is the message of injected failures.
+	Error string `json:"error"`
+}
+
+/**
+ * @brief Settings shared by every mocked service.
+ */
+type mockConfig struct {
+	FixturesDir string
+	Latency     time.Duration
+	ErrorRate   float64
+}
+
+/**
+ * @brief A generic service that answers every method of an IDL with generated data.
+ */
+type mockService struct {
+	service   string
+	responses map[string]map[string]interface{}
+	latency   map[string]time.Duration
+	errorRate map[string]float64
+	errors    map[string]string
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+/**
+ * @brief Builds the mock of a service from its IDL and fixtures.
+ * @param[in] serviceName The service, whose IDL is ./thriftFiles/<serviceName>.thrift.
+ * @param[in] cfg The fixtures directory and the default latency and error rate.
+ *
+ * @return The mock service.
+ * @return An error if the IDL cannot be parsed, or a fixture is invalid or names a method the
+ *         IDL does not declare.
+ */
+func newMockService(serviceName string, cfg mockConfig) (*mockService, error) {
+	ast, err := parser.ParseFile(fmt.Sprintf("./thriftFiles/%s.thrift", serviceName), nil, true)
+	if err != nil {
+		return nil, err
+	}
+	svc, ok := ast.GetService(serviceName)
+	if !ok {
+		return nil, fmt.Errorf("%s is not declared in its IDL", serviceName)
+	}
+
+	m := &mockService{
+		service:   serviceName,
+		responses: map[string]map[string]interface{}{},
+		latency:   map[string]time.Duration{},
+		errorRate: map[string]float64{},
+		errors:    map[string]string{},
+		rnd:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, fn := range svc.Functions {
+		response := map[string]interface{}{}
+		if !fn.Void {
+			if value, ok := mockValue(ast, fn.FunctionType, fn.Name, 1, map[*parser.StructLike]bool{}).(map[string]interface{}); ok {
+				response = value
+			}
+		}
+		m.responses[fn.Name] = response
+		m.latency[fn.Name] = cfg.Latency
+		m.errorRate[fn.Name] = cfg.ErrorRate
+		m.errors[fn.Name] = "mock error injected"
+	}
+
+	fixtures, err := loadMockFixtures(cfg.FixturesDir, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	for method, fixture := range fixtures {
+		if _, ok := m.responses[method]; !ok {
+			return nil, fmt.Errorf("fixture for %s.%s: the IDL declares no such method", serviceName, method)
+		}
+		mergeMockResponse(m.responses[method], fixture.Response)
+		if fixture.Latency != "" {
+			d, err := time.ParseDuration(fixture.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("fixture for %s.%s: %w", serviceName, method, err)
+			}
+			m.latency[method] = d
+		}
+		if fixture.ErrorRate != nil {
+			if *fixture.ErrorRate < 0 || *fixture.ErrorRate > 1 {
+				return nil, fmt.Errorf("fixture for %s.%s: errorRate must be between 0 and 1", serviceName, method)
+			}
+			m.errorRate[method] = *fixture.ErrorRate
+		}
+		if fixture.Error != "" {
+			m.errors[method] = fixture.Error
+		}
+	}
+	return m, nil
+}
+
+/**
+ * @brief Reads the fixtures of a service.
+ * @param[in] dir The fixtures directory.
+ * @param[in] serviceName The service whose <serviceName>.json to read.
+ *
+ * @return The fixtures by method name; none when the file does not exist.
+ * @return An error if the file cannot be read or parsed.
+ */
+func loadMockFixtures(dir string, serviceName string) (map[string]mockFixture, error) {
+	fixtures := map[string]mockFixture{}
+	if dir == "" {
+		return fixtures, nil
+	}
+	path := filepath.Join(dir, serviceName+".json")
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fixtures, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return fixtures, nil
+}
+
+// mergeMockResponse copies the fixture over the generated response, merging nested objects.
+func mergeMockResponse(generated map[string]interface{}, fixture map[string]interface{}) {
+	for k, v := range fixture {
+		inner, isObject := v.(map[string]interface{})
+		target, hasObject := generated[k].(map[string]interface{})
+		if isObject && hasObject {
+			mergeMockResponse(target, inner)
+			continue
+		}
+		generated[k] = v
+	}
+}
+
+/**
+ * @brief Converts the default value of a field to its JSON form.
+ * @param[in] v The default written in the IDL.
+ * @param[in] t The field's type, to tell bools written as 0 or 1 apart from numbers.
+ *
+ * @return The value, or nil for defaults that name a constant or an enum value, which are
+ *         generated instead.
+ */
+func mockDefault(v *parser.ConstValue, t *parser.Type) interface{} {
+	if v == nil || v.TypedValue == nil {
+		return nil
+	}
+	tv := v.TypedValue
+	switch {
+	case tv.Literal != nil:
+		return *tv.Literal
+	case tv.Int != nil:
+		if t.Name == "bool" {
+			return *tv.Int != 0
+		}
+		return *tv.Int
+	case tv.Double != nil:
+		return *tv.Double
+	case tv.Identifier != nil:
+		switch *tv.Identifier {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		return nil
+	case tv.List != nil:
+		list := []interface{}{}
+		for _, item := range tv.List {
+			if value := mockDefault(item, t.ValueType); value != nil {
+				list = append(list, value)
+			}
+		}
+		return list
+	case tv.Map != nil:
+		m := map[string]interface{}{}
+		for _, entry := range tv.Map {
+			key, value := mockDefault(entry.Key, t.KeyType), mockDefault(entry.Value, t.ValueType)
+			if key != nil && value != nil {
+				m[fmt.Sprint(key)] = value
+			}
+		}
+		return m
+	}
+	return nil
+}
+
+/**
+ * @brief Generates the nth value of an IDL type, counting from 1. Strings are "<name> <n>",
+ *        numbers n, lists and sets hold values 2n-1 and 2n so set elements stay distinct,
+ *        and structs have every field set, to its IDL default when it has one, except a struct
+ *        already being generated. A union has only its first field set. A base.BaseResp comes
+ *        out as StatusCode 0, a success, because that is its IDL default.
+ * @param[in] file The IDL file the type is written in, for resolving includes.
+ * @param[in] t The type.
+ * @param[in] name The field or method name, used in generated strings.
+ * @param[in] n Which value to generate, so that siblings in a list or set differ.
+ * @param[in] open The structs being generated, to stop at recursive types.
+ *
+ * @return The value, as it would be decoded from JSON.
+ */
+func mockValue(file *parser.Thrift, t *parser.Type, name string, n int, open map[*parser.StructLike]bool) interface{} {
+	switch t.Name {
+	case "bool":
+		return n%2 == 1
+	case "byte", "i8", "i16", "i32", "i64":
+		return n
+	case "double":
+		return float64(n) + 0.5
+	case "string", "binary":
+		return fmt.Sprintf("%s %d", name, n)
+	case "list":
+		return []interface{}{mockValue(file, t.ValueType, name, 2*n-1, open), mockValue(file, t.ValueType, name, 2*n, open)}
+	case "set":
+		// A type with a single value, such as an enum of one, can only fill a set of one.
+		first, second := mockValue(file, t.ValueType, name, 2*n-1, open), mockValue(file, t.ValueType, name, 2*n, open)
+		if reflect.DeepEqual(first, second) {
+			return []interface{}{first}
+		}
+		return []interface{}{first, second}
+	case "map":
+		key := fmt.Sprint(mockValue(file, t.KeyType, "key", n, open))
+		return map[string]interface{}{key: mockValue(file, t.ValueType, name, n, open)}
+	}
+
+	// A named type, either local or "prefix.Name" from an include.
+	scope, typeName := file, t.Name
+	if idx := strings.LastIndex(t.Name, "."); idx >= 0 {
+		included, ok := file.GetReference(t.Name[:idx])
+		if !ok {
+			return nil
+		}
+		scope, typeName = included, t.Name[idx+1:]
+	}
+	if td, ok := scope.GetTypedef(typeName); ok {
+		return mockValue(scope, td.Type, name, n, open)
+	}
+	if en, ok := scope.GetEnum(typeName); ok {
+		if len(en.Values) > 0 {
+			return en.Values[(n-1)%len(en.Values)].Value
+		}
+		return 0
+	}
+	st, ok := scope.GetStruct(typeName)
+	union := false
+	if !ok {
+		if st, ok = scope.GetUnion(typeName); ok {
+			union = true
+		} else {
+			st, ok = scope.GetException(typeName)
+		}
+	}
+	if !ok || open[st] {
+		return nil
+	}
+	open[st] = true
+	defer delete(open, st)
+	fields := map[string]interface{}{}
+	for _, f := range st.Fields {
+		value := mockDefault(f.Default, f.Type)
+		if value == nil {
+			value = mockValue(scope, f.Type, f.Name, n, open)
+		}
+		if value != nil {
+			fields[f.Name] = value
+			if union {
+				break
+			}
+		}
+	}
+	return fields
+}
+
+/**
+ * @brief Answers a call with the method's generated response, after the configured latency,
+ *        or fails it at the configured error rate.
+ */
+func (m *mockService) GenericCall(ctx context.Context, method string, request interface{}) (response interface{}, err error) {
+	_, span := startSpan(ctx, "mockService.GenericCall")
+	defer span.End()
+
+	resp, ok := m.responses[method]
+	if !ok {
+		return nil, fmt.Errorf("%s has no method %s", m.service, method)
+	}
+	if d := m.latency[method]; d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	m.mu.Lock()
+	fail := m.rnd.Float64() < m.errorRate[method]
+	m.mu.Unlock()
+	if fail {
+		return nil, errors.New(m.errors[method])
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
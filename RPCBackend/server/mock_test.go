@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/thriftgo/parser"
+)
+
+func callMock(t *testing.T, m *mockService, method string) map[string]interface{} {
+	resp, err := m.GenericCall(context.Background(), method, "{}")
+	if err != nil {
+		t.Fatalf("%s failed: %v", method, err)
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(resp.(string)), &out); err != nil {
+		t.Fatalf("%s answered with invalid JSON: %v", method, err)
+	}
+	return out
+}
+
+func TestMockServiceAnswersWithSuccessfulBaseResp(t *testing.T) {
+	m, err := newMockService("TravelService", mockConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := callMock(t, m, "RetrieveClientData")
+	baseResp, ok := out["BaseResp"].(map[string]interface{})
+	if !ok || baseResp["StatusCode"] != 0.0 || baseResp["StatusMessage"] != "" {
+		t.Fatalf("BaseResp should take its IDL defaults, got %v", out["BaseResp"])
+	}
+	if out["Name"] != "Name 1" || len(out["VisitedCountries"].([]interface{})) != 2 {
+		t.Fatalf("Unexpected generated response %v", out)
+	}
+}
+
+func TestMockValueHonoursDefaultsAndSetsOneUnionField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Mock.thrift")
+	idl := `
+union Choice {
+    1: string text,
+    2: i32 number,
+}
+
+struct Settings {
+    1: i32 retries = 3,
+    2: bool enabled = false,
+    3: string mode = "fast",
+    4: list<string> tags = ["a", "b"],
+    5: Choice choice,
+    6: i64 count,
+}
+`
+	if err := ioutil.WriteFile(path, []byte(idl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.ParseFile(path, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := mockValue(ast, &parser.Type{Name: "Settings"}, "settings", 1, map[*parser.StructLike]bool{})
+	data, _ := json.Marshal(value)
+	out := map[string]interface{}{}
+	json.Unmarshal(data, &out)
+
+	if out["retries"] != 3.0 || out["enabled"] != false || out["mode"] != "fast" || out["count"] != 1.0 {
+		t.Fatalf("Fields should take their defaults, or be generated without one, got %v", out)
+	}
+	if tags := out["tags"].([]interface{}); len(tags) != 2 || tags[0] != "a" {
+		t.Fatalf("A list default should be kept, got %v", tags)
+	}
+	if choice := out["choice"].(map[string]interface{}); len(choice) != 1 || choice["text"] != "text 1" {
+		t.Fatalf("A union should have exactly one field set, got %v", choice)
+	}
+}
+
+func TestMockFixturesMergeAndInjectErrors(t *testing.T) {
+	dir := t.TempDir()
+	fixtures := `{
+  "RetrieveClientData": {"response": {"Name": "Ada", "BaseResp": {"StatusMessage": "ok"}}},
+  "GetAllTravelDestinations": {"errorRate": 1, "error": "no destinations"}
+}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "TravelService.json"), []byte(fixtures), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMockService("TravelService", mockConfig{FixturesDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := callMock(t, m, "RetrieveClientData")
+	baseResp := out["BaseResp"].(map[string]interface{})
+	if out["Name"] != "Ada" || out["userID"] != 1.0 || baseResp["StatusMessage"] != "ok" || baseResp["StatusCode"] != 0.0 {
+		t.Fatalf("The fixture should be merged over the generated response, got %v", out)
+	}
+	if _, err := m.GenericCall(context.Background(), "GetAllTravelDestinations", "{}"); err == nil || err.Error() != "no destinations" {
+		t.Fatalf("Expected the injected error, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "TravelService.json"), []byte(`{"missing": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newMockService("TravelService", mockConfig{FixturesDir: dir}); err == nil {
+		t.Fatal("A fixture for an undeclared method should be rejected")
+	}
+	if err := os.Remove(filepath.Join(dir, "TravelService.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newMockService("TravelService", mockConfig{FixturesDir: dir}); err != nil {
+		t.Fatalf("A missing fixtures file should be fine: %v", err)
+	}
+}
+
+func TestMockValueFillsSetsWithDistinctElements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Mock.thrift")
+	idl := `
+enum Colour {
+    RED = 1,
+    GREEN = 2,
+}
+
+enum Only {
+    ONE = 1,
+}
+
+struct Tag {
+    1: string label,
+    2: i32 weight,
+}
+
+struct Sets {
+    1: set<string> names,
+    2: set<i64> ids,
+    3: set<bool> flags,
+    4: set<Colour> colours,
+    5: set<Tag> tags,
+    6: set<set<i32>> nested,
+    7: set<Only> only,
+}
+`
+	if err := ioutil.WriteFile(path, []byte(idl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.ParseFile(path, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := mockValue(ast, &parser.Type{Name: "Sets"}, "sets", 1, map[*parser.StructLike]bool{})
+	data, _ := json.Marshal(value)
+	out := map[string]interface{}{}
+	json.Unmarshal(data, &out)
+
+	for _, field := range []string{"names", "ids", "flags", "colours", "tags", "nested"} {
+		elements := out[field].([]interface{})
+		if len(elements) != 2 {
+			t.Fatalf("%s should hold two elements, got %v", field, elements)
+		}
+		first, _ := json.Marshal(elements[0])
+		second, _ := json.Marshal(elements[1])
+		if string(first) == string(second) {
+			t.Fatalf("%s should hold distinct elements, got %v", field, elements)
+		}
+	}
+	if only := out["only"].([]interface{}); len(only) != 1 {
+		t.Fatalf("A set of a one-valued enum can only hold one element, got %v", only)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"github.com/cloudwego/kitex/pkg/remote/trans/gonet"
+	"github.com/cloudwego/kitex/server"
+)
+
+/**
+ * @brief Builds the TLS config of the generic servers, requiring a client certificate from the gateway.
+ * @param[in] certFile The PEM certificate (chain) of this backend.
+ * @param[in] keyFile The PEM private key of this backend.
+ * @param[in] clientCAFile The PEM CA bundle that signs gateway certificates.
+ * @param[in] clientIdentity The identity the caller's certificate must carry, as its common name
+ *                           or one of its DNS or URI subject alternative names.
+ *
+ * @return nil when certFile is empty, meaning plain TCP.
+ * @return An error if a file cannot be loaded.
+ */
+func newServerTLS(certFile string, keyFile string, clientCAFile string, clientIdentity string) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading backend certificate: %w", err)
+	}
+	if clientCAFile == "" {
+		return nil, errors.New("mutual TLS needs -tls-client-ca to verify the gateway")
+	}
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		// The chain is already verified against ClientCAs; this only checks who the caller is.
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 || !hasIdentity(cs.PeerCertificates[0], clientIdentity) {
+				return fmt.Errorf("client certificate is not issued to %q", clientIdentity)
+			}
+			return nil
+		},
+	}, nil
+}
+
+/**
+ * @brief Reports whether a certificate names the given identity.
+ */
+func hasIdentity(cert *x509.Certificate, identity string) bool {
+	if cert.Subject.CommonName == identity {
+		return true
+	}
+	for _, name := range cert.DNSNames {
+		if name == identity {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == identity {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @brief Returns the server options that serve a generic server over TLS on the given port.
+ *
+ * Netpoll cannot carry TLS, so the server switches to Kitex's go net transport and accepts
+ * connections from a TLS listener.
+ *
+ * @return No options when tlsConfig is nil.
+ * @return An error if the port cannot be bound.
+ */
+func tlsServerOptions(tlsConfig *tls.Config, port int) ([]server.Option, error) {
+	if tlsConfig == nil {
+		return nil, nil
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	return []server.Option{
+		server.WithListener(tls.NewListener(ln, tlsConfig)),
+		server.WithTransServerFactory(gonet.NewTransServerFactory()),
+		server.WithTransHandlerFactory(gonet.NewSvrTransHandlerFactory()),
+	}, nil
+}
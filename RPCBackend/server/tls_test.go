@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs the certificates of a test.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a leaf certificate for commonName, with uri as a subject alternative name when set.
+func (ca *testCA) issue(t *testing.T, commonName string, uri string, usage x509.ExtKeyUsage) (certPEM []byte, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if uri != "" {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// handshake connects to a TLS listener using serverConfig with the client certificate given,
+// and returns the error the server saw.
+func handshake(t *testing.T, serverConfig *tls.Config, ca *testCA, certPEM []byte, keyPEM []byte) error {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	result := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			result <- err
+			return
+		}
+		defer conn.Close()
+		result <- conn.(*tls.Conn).Handshake()
+	}()
+
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		RootCAs:      roots,
+		ServerName:   "backend",
+		Certificates: []tls.Certificate{clientCert},
+	})
+	if err == nil {
+		defer conn.Close()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("The handshake did not finish")
+		return nil
+	}
+}
+
+func TestServerTLSChecksTheClientIdentity(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "backend", "", x509.ExtKeyUsageServerAuth)
+	certFile, keyFile, caFile := write("backend.pem", serverCert), write("backend.key", serverKey), write("ca.pem", ca.pem)
+
+	cfg, err := newServerTLS(certFile, keyFile, caFile, "api-gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gatewayCert, gatewayKey := ca.issue(t, "api-gateway", "", x509.ExtKeyUsageClientAuth)
+	if err := handshake(t, cfg, ca, gatewayCert, gatewayKey); err != nil {
+		t.Fatalf("The gateway's certificate should be accepted, got %v", err)
+	}
+	otherCert, otherKey := ca.issue(t, "reporting-job", "", x509.ExtKeyUsageClientAuth)
+	if err := handshake(t, cfg, ca, otherCert, otherKey); err == nil {
+		t.Fatal("A certificate from the same CA for another identity should be rejected")
+	}
+	strangerCert, strangerKey := newTestCA(t).issue(t, "api-gateway", "", x509.ExtKeyUsageClientAuth)
+	if err := handshake(t, cfg, ca, strangerCert, strangerKey); err == nil {
+		t.Fatal("A certificate for the right identity from another CA should be rejected")
+	}
+
+	byURI, err := newServerTLS(certFile, keyFile, caFile, "spiffe://cluster.local/gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uriCert, uriKey := ca.issue(t, "gateway-pod", "spiffe://cluster.local/gateway", x509.ExtKeyUsageClientAuth)
+	if err := handshake(t, byURI, ca, uriCert, uriKey); err != nil {
+		t.Fatalf("An identity in a URI subject alternative name should be accepted, got %v", err)
+	}
+}
+
+func TestServerTLSConfigErrors(t *testing.T) {
+	if cfg, err := newServerTLS("", "", "", ""); cfg != nil || err != nil {
+		t.Fatalf("No certificate should mean plain TCP, got %v, %v", cfg, err)
+	}
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, "backend", "", x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "backend.pem"), filepath.Join(dir, "backend.key")
+	if err := ioutil.WriteFile(certFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newServerTLS(certFile, keyFile, "", "api-gateway"); err == nil {
+		t.Fatal("Mutual TLS without a client CA should be refused")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/cloudwego/kitex/pkg/endpoint"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "rpc_backend"
+
+/**
+ * @brief Installs the global OpenTelemetry tracer provider and the W3C trace context propagator.
+ * @param[in] exporter One of "none", "otlp", "stdout" or "file".
+ * @param[in] endpoint The OTLP/HTTP collector address used by the "otlp" exporter.
+ * @param[in] file The path spans are appended to by the "file" exporter.
+ *
+ * @return A shutdown function that flushes pending spans; it is safe to call when tracing is off.
+ * @return An error if the exporter could not be created.
+ */
+func initTracing(ctx context.Context, exporter string, endpoint string, file string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exp sdktrace.SpanExporter
+	var closer io.Closer
+	var err error
+
+	switch exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithInsecure()}
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+		exp, err = otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		f, ferr := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if ferr != nil {
+			return nil, fmt.Errorf("error opening trace file: %w", ferr)
+		}
+		closer = f
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(tracerName))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+/**
+ * metainfoCarrier reads the trace context the gateway sent as TTHeader metainfo.
+ */
+type metainfoCarrier struct {
+	ctx context.Context
+}
+
+func (m *metainfoCarrier) Get(key string) string {
+	v, _ := metainfo.GetValue(m.ctx, key)
+	return v
+}
+
+func (m *metainfoCarrier) Set(key, value string) {
+	m.ctx = metainfo.WithValue(m.ctx, key, value)
+}
+
+func (m *metainfoCarrier) Keys() []string {
+	keys := []string{}
+	for k := range metainfo.GetAllValues(m.ctx) {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+/**
+ * @brief Kitex server middleware that continues the caller's trace.
+ *
+ * The W3C trace context is extracted from the request metainfo and a server span is opened
+ * around the handler, so the ctx handed to GenericServiceImpl carries the gateway's trace.
+ */
+func tracingMiddleware(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, req, resp interface{}) error {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, &metainfoCarrier{ctx: ctx})
+
+		service, method := "", ""
+		if ri := rpcinfo.GetRPCInfo(ctx); ri != nil {
+			service = ri.To().ServiceName()
+			method = ri.Invocation().MethodName()
+		}
+
+		ctx, span := otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("%s/%s", service, method),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("rpc.system", "kitex"),
+				attribute.String("rpc.service", service),
+				attribute.String("rpc.method", method),
+			),
+		)
+		defer span.End()
+
+		err := next(ctx, req, resp)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+/**
+ * @brief Starts a span with the backend's tracer.
+ */
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name)
+}